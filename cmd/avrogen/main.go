@@ -0,0 +1,54 @@
+// Command avrogen generates Go structs with Avro decode/encode functions from .avsc record schemas.
+//
+//	avrogen -pkg events -out internal/events internal/events/schemas
+//
+// Every argument is a schema file or a directory of them; each record gets a <record>.gen.go file.
+// With -check nothing is written: avrogen fails if a generated file is missing or stale, or if a
+// schema is not one the schema registry would accept.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"project-service/internal/lib/avrogen"
+	"strings"
+)
+
+func main() {
+	pkg := flag.String("pkg", "", "package of the generated files")
+	out := flag.String("out", ".", "directory of the generated files")
+	check := flag.Bool("check", false, "verify that generated files are up to date instead of writing them")
+	flag.Parse()
+
+	if *pkg == "" || flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: avrogen -pkg <package> [-out <dir>] [-check] <schema.avsc|dir>...")
+		os.Exit(2)
+	}
+
+	if *check {
+		stale, err := avrogen.Stale(*pkg, *out, flag.Args())
+		if err != nil {
+			fail(err)
+		}
+		if len(stale) > 0 {
+			fail(fmt.Errorf("generated files are out of date with their schemas, run go generate: %s", strings.Join(stale, ", ")))
+		}
+		return
+	}
+
+	generated, err := avrogen.Generate(*pkg, *out, flag.Args())
+	if err != nil {
+		fail(err)
+	}
+	for target, code := range generated {
+		if err := os.WriteFile(target, code, 0o644); err != nil {
+			fail(err)
+		}
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "avrogen:", err)
+	os.Exit(1)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"project-service/internal/app"
@@ -14,14 +15,14 @@ func main() {
 	log := logger.MustSetupLogger(cfg.Env)
 
 	application := app.NewApp(log, cfg)
-	application.GrpcApp.MustRun()
 
-	stopWait(application)
-}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	if err := application.Run(ctx); err != nil {
+		log.Error("application stopped with error", "error", err)
+		os.Exit(1)
+	}
 
-func stopWait(application *app.App) {
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
-	<-stop
-	application.GrpcApp.Stop()
+	log.Info("application stopped")
 }
@@ -2,8 +2,12 @@ module project-service
 
 go 1.23.4
 
+// The generated service code is vendored until a protos release tag contains its RPCs; then drop the
+// replace and third_party/protos, require the tag and run go mod tidy.
+replace github.com/SmartAPIForge/protos => ./third_party/protos
+
 require (
-	github.com/SmartAPIForge/protos v1.7.0
+	github.com/SmartAPIForge/protos v1.14.0
 	github.com/confluentinc/confluent-kafka-go v1.9.2
 	github.com/grpc-ecosystem/go-grpc-middleware/v2 v2.3.1
 	github.com/joho/godotenv v1.5.1
@@ -13,6 +17,7 @@ require (
 )
 
 require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/golang/snappy v0.0.4 // indirect
 	github.com/klauspost/compress v1.16.7 // indirect
 	github.com/montanaflynn/stats v0.7.1 // indirect
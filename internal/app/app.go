@@ -2,20 +2,37 @@ package app
 
 import (
 	"context"
+	"expvar"
+	"fmt"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"log/slog"
+	"net/http"
 	grpcapp "project-service/internal/app/grpc"
+	httpapp "project-service/internal/app/http"
+	"project-service/internal/app/lifecycle"
 	"project-service/internal/config"
+	grpcauth "project-service/internal/grpc/auth"
+	"project-service/internal/http/download"
+	"project-service/internal/http/health"
 	"project-service/internal/kafka"
+	"project-service/internal/lib/jwt"
+	"project-service/internal/lib/schemaregistry"
+	"project-service/internal/lib/urlsigner"
+	"project-service/internal/repository"
+	"project-service/internal/repository/outbox"
+	"project-service/internal/repository/processedevent"
 	"project-service/internal/repository/project"
+	outboxservice "project-service/internal/services/outbox"
 	projectservice "project-service/internal/services/project"
-	"runtime/debug"
-	"time"
+	reconcilerservice "project-service/internal/services/reconciler"
 )
 
 type App struct {
-	GrpcApp *grpcapp.GrpcApp
+	GrpcApp         *grpcapp.GrpcApp
+	HttpApp         *httpapp.HttpApp
+	InternalHttpApp *httpapp.HttpApp
+	components      *lifecycle.Manager
 }
 
 func NewApp(
@@ -28,40 +45,197 @@ func NewApp(
 		log.Error(err.Error())
 	}
 
+	schemaRegistry, err := schemaregistry.New(schemaregistry.Options{
+		URL:            cfg.SchemaRegistry.URL,
+		Timeout:        cfg.SchemaRegistry.Timeout,
+		MaxAttempts:    cfg.SchemaRegistry.MaxAttempts,
+		InitialBackoff: cfg.SchemaRegistry.InitialBackoff,
+		MaxBackoff:     cfg.SchemaRegistry.MaxBackoff,
+		Username:       cfg.SchemaRegistry.Username,
+		Password:       cfg.SchemaRegistry.Password,
+		CAFile:         cfg.SchemaRegistry.CAFile,
+		CertFile:       cfg.SchemaRegistry.CertFile,
+		KeyFile:        cfg.SchemaRegistry.KeyFile,
+		BundleFile:     cfg.SchemaRegistry.BundleFile,
+	})
+	if err != nil {
+		panic(err)
+	}
+	schemaManager := kafka.NewSchemaManager(log, cfg, schemaRegistry)
+	eventProducer := kafka.NewKafkaProducer(log, cfg)
+
+	transactor := repository.NewTransactor(mongoClient)
 	projectRepository := project.NewProjectRepository(mongoClient, cfg.MongoDB, "project")
+	if err := projectRepository.MigrateLegacyFields(context.Background()); err != nil {
+		log.Error("failed to migrate legacy project fields", "error", err)
+	}
+	outboxRepository := outbox.NewOutboxRepository(mongoClient, cfg.MongoDB, "outbox")
+	if err := outboxRepository.EnsureIndexes(context.Background()); err != nil {
+		log.Error("failed to create outbox indexes", "error", err)
+	}
+
+	signingKeys, err := urlsigner.ParseKeys(cfg.Download.SigningKeys)
+	if err != nil {
+		panic(err)
+	}
+	urlSigner, err := urlsigner.New(cfg.Download.BaseURL, cfg.Download.URLTTL, signingKeys)
+	if err != nil {
+		panic(err)
+	}
+
+	processedEventRepository := processedevent.NewProcessedEventRepository(mongoClient, cfg.MongoDB, "processed_events")
+	if err := processedEventRepository.EnsureIndexes(context.Background()); err != nil {
+		log.Error("failed to create processed events indexes", "error", err)
+	}
+
 	projectUpdater := projectservice.NewProjectUpdater()
-	projectService := projectservice.NewProjectService(log, projectRepository, projectUpdater)
-
-	schemaManager := kafka.NewSchemaManager(cfg)
-	for topic, codec := range schemaManager.Schemas {
-		consumer := kafka.NewKafkaConsumer(log, cfg, topic, codec, projectService)
-		consumer.Sub()
-		go func(consumer *kafka.KafkaConsumer) {
-			for {
-				func() {
-					defer func() {
-						if r := recover(); r != nil {
-							log.Error("Panic in consumer, restarting...",
-								"panic", r,
-								"stack", string(debug.Stack()))
-						}
-					}()
-					consumer.Consume()
-				}()
-				time.Sleep(5 * time.Second)
-				consumer.Sub()
-			}
-		}(consumer)
+	projectService := projectservice.NewProjectService(
+		log,
+		projectRepository,
+		outboxRepository,
+		processedEventRepository,
+		transactor,
+		schemaManager,
+		urlSigner,
+		projectUpdater,
+	)
+
+	outboxRelay := outboxservice.NewRelay(log, cfg.Outbox, outboxRepository, eventProducer)
+	reconciler, err := reconcilerservice.NewReconciler(log, cfg.Reconciler, projectService)
+	if err != nil {
+		panic(err)
 	}
 
+	dlq := kafka.NewDeadLetterQueue(log, eventProducer,
+		kafka.ConfluentPartitionReaders(cfg.KafkaHost, cfg.Consumer.GroupID+"-dlq-browser"))
+
+	handlerRegistry := kafka.NewHandlerRegistry(
+		kafka.RecoveryMiddleware(log),
+		kafka.TracingMiddleware(),
+		kafka.LoggingMiddleware(log),
+		kafka.MetricsMiddleware(),
+	)
+	kafka.RegisterProjectHandlers(handlerRegistry, projectService)
+	schemaManager.LoadSchemas(handlerRegistry.ReaderSchemas())
+
+	consumer := kafka.NewKafkaConsumer(log, cfg, kafka.NewConfluentConsumerClient(cfg), handlerRegistry, schemaManager, dlq)
+	replayer := kafka.NewReplayer(log, cfg, handlerRegistry, schemaManager,
+		kafka.ConfluentPartitionReaders(cfg.KafkaHost, cfg.Consumer.GroupID+"-replay"))
+
+	tokenVerifier, err := newTokenVerifier(cfg.Auth)
+	if err != nil {
+		panic(err)
+	}
+	authenticator := grpcauth.NewAuthenticator(log, tokenVerifier, cfg.Auth.RolesClaim)
+	authorizer := grpcauth.NewAuthorizer(cfg.Auth.AdminRole)
+
 	grpcApp := grpcapp.NewGrpcApp(
 		log,
+		authenticator,
+		authorizer,
 		projectService,
 		cfg.GRPC.Port,
+		cfg.GRPC.AdminEnabled,
 		projectUpdater,
+		dlq,
+		replayer,
+		reconciler,
 	)
 
+	publicRoutes := map[string]http.Handler{}
+	if urlSigner.Enabled() {
+		publicRoutes["/downloads"] = download.NewHandler(log, urlSigner, projectService, cfg.Download.Root)
+	} else {
+		log.Warn("download links are disabled, set DOWNLOAD_SIGNING_KEYS to issue them")
+	}
+	httpApp := httpapp.NewHttpApp(log, cfg.HTTP.Port, publicRoutes)
+	// health checks and metrics stay off the public port
+	internalHttpApp := httpapp.NewHttpApp(log, cfg.HTTP.InternalPort, map[string]http.Handler{
+		"/debug/vars": expvar.Handler(),
+		"/health": health.NewHandler(map[string]health.Checker{
+			"schemas":        schemaManager,
+			"kafka consumer": consumer,
+		}),
+	})
+
+	// components are stopped in reverse order
+	components := lifecycle.New(log, cfg.ShutdownTimeout)
+	components.Add("mongo", nil, func(ctx context.Context) error {
+		return mongoClient.Disconnect(ctx)
+	})
+	components.Add("kafka producer", nil, func(context.Context) error {
+		eventProducer.Close()
+		return nil
+	})
+	components.Add("schema refresh", func(ctx context.Context) error {
+		schemaManager.Run(ctx)
+		return nil
+	}, nil)
+	components.Add("outbox relay", func(ctx context.Context) error {
+		outboxRelay.Run(ctx)
+		return nil
+	}, nil)
+	components.Add("reconciler", func(ctx context.Context) error {
+		reconciler.Run(ctx)
+		return nil
+	}, nil)
+	components.Add("kafka consumer", consumer.Run, nil)
+	components.Add("http server", func(context.Context) error {
+		return httpApp.Run()
+	}, httpApp.Stop)
+	components.Add("internal http server", func(context.Context) error {
+		return internalHttpApp.Run()
+	}, internalHttpApp.Stop)
+	components.Add("grpc server", func(context.Context) error {
+		return grpcApp.Run()
+	}, grpcApp.Stop)
+	// closed before the gRPC server stops, so that open update streams end instead of holding up GracefulStop
+	components.Add("project updates", nil, func(context.Context) error {
+		projectUpdater.Close()
+		return nil
+	})
+
 	return &App{
-		GrpcApp: grpcApp,
+		GrpcApp:         grpcApp,
+		HttpApp:         httpApp,
+		InternalHttpApp: internalHttpApp,
+		components:      components,
 	}
 }
+
+// newTokenVerifier verifies tokens with the one key source the configuration names.
+func newTokenVerifier(cfg config.AuthConfig) (*jwt.Verifier, error) {
+	var sources []jwt.KeySource
+	if cfg.JWKSURL != "" {
+		sources = append(sources, jwt.NewRemoteJWKS(cfg.JWKSURL, cfg.JWKSRefreshInterval, cfg.JWKSTimeout))
+	}
+	if cfg.JWKSFile != "" {
+		keys, err := jwt.LoadJWKSFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, keys)
+	}
+	if cfg.HMACKeys != "" {
+		keys, err := jwt.ParseHMACKeys(cfg.HMACKeys)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, keys)
+	}
+	if len(sources) != 1 {
+		return nil, fmt.Errorf("exactly one of AUTH_JWKS_URL, AUTH_JWKS_FILE and AUTH_HMAC_KEYS has to be set, got %d", len(sources))
+	}
+
+	return jwt.NewVerifier(jwt.Options{
+		Keys:     sources[0],
+		Issuer:   cfg.Issuer,
+		Audience: cfg.Audience,
+		Leeway:   cfg.Leeway,
+	})
+}
+
+// Run starts all components and blocks until ctx is done or one of them fails, then shuts them down.
+func (a *App) Run(ctx context.Context) error {
+	return a.components.Run(ctx)
+}
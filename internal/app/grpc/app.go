@@ -3,6 +3,7 @@ package grpcapp
 import (
 	"context"
 	"fmt"
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/auth"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
 	"google.golang.org/grpc"
@@ -10,6 +11,8 @@ import (
 	"google.golang.org/grpc/status"
 	"log/slog"
 	"net"
+	adminserver "project-service/internal/grpc/admin"
+	grpcauth "project-service/internal/grpc/auth"
 	projectserver "project-service/internal/grpc/project"
 	projectservice "project-service/internal/services/project"
 )
@@ -20,10 +23,24 @@ type GrpcApp struct {
 	port       int
 }
 
-func NewGrpcApp(log *slog.Logger, projectService projectserver.ProjectService, port int, projectUpdater *projectservice.ProjectUpdater) *GrpcApp {
+// NewGrpcApp serves the project service, and the admin service with adminEnabled, to callers
+// authenticator accepts, limited to what authorizer allows them.
+func NewGrpcApp(
+	log *slog.Logger,
+	authenticator *grpcauth.Authenticator,
+	authorizer *grpcauth.Authorizer,
+	projectService projectserver.ProjectService,
+	port int,
+	adminEnabled bool,
+	projectUpdater *projectservice.ProjectUpdater,
+	dlq adminserver.DeadLetterQueue,
+	replayer adminserver.TopicReplayer,
+	reconciler adminserver.StuckProjectsReporter,
+) *GrpcApp {
+	// payloads are not logged, responses carry signed download links
 	loggingOpts := []logging.Option{
 		logging.WithLogOnEvents(
-			logging.PayloadReceived, logging.PayloadSent,
+			logging.StartCall, logging.FinishCall,
 		),
 	}
 	recoveryOpts := []recovery.Option{
@@ -32,17 +49,29 @@ func NewGrpcApp(log *slog.Logger, projectService projectserver.ProjectService, p
 			return status.Errorf(codes.Internal, "internal server error")
 		}),
 	}
-	gRPCServer := grpc.NewServer(grpc.ChainUnaryInterceptor(
-		recovery.UnaryServerInterceptor(recoveryOpts...),
-		logging.UnaryServerInterceptor(
-			logging.LoggerFunc(func(ctx context.Context, lvl logging.Level, msg string, fields ...any) {
-				log.Log(ctx, slog.Level(lvl), msg, fields...)
-			}),
-			loggingOpts...,
+	gRPCServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			recovery.UnaryServerInterceptor(recoveryOpts...),
+			logging.UnaryServerInterceptor(
+				logging.LoggerFunc(func(ctx context.Context, lvl logging.Level, msg string, fields ...any) {
+					log.Log(ctx, slog.Level(lvl), msg, fields...)
+				}),
+				loggingOpts...,
+			),
+			auth.UnaryServerInterceptor(authenticator.Authenticate),
 		),
-	))
+		grpc.ChainStreamInterceptor(
+			recovery.StreamServerInterceptor(recoveryOpts...),
+			auth.StreamServerInterceptor(authenticator.Authenticate),
+		),
+	)
 
-	projectserver.RegisterProjectServer(gRPCServer, projectService, projectUpdater)
+	projectserver.RegisterProjectServer(gRPCServer, projectService, projectUpdater, reconciler, authorizer)
+	if adminEnabled {
+		adminserver.RegisterAdminServer(gRPCServer, dlq, replayer, reconciler, authorizer)
+	} else {
+		log.Info("admin service is disabled, set ADMIN_API_ENABLED to serve it")
+	}
 
 	return &GrpcApp{
 		log:        log,
@@ -51,26 +80,41 @@ func NewGrpcApp(log *slog.Logger, projectService projectserver.ProjectService, p
 	}
 }
 
-func (a *GrpcApp) MustRun() {
-	const op = "grpcapp.MustRun"
+func (a *GrpcApp) Run() error {
+	const op = "grpcapp.Run"
 
 	l, err := net.Listen("tcp", fmt.Sprintf(":%d", a.port))
 	if err != nil {
-		panic(fmt.Errorf("%s: %w", op, err))
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	a.log.Info("grpc server started", slog.String("addr", l.Addr().String()))
 
 	if err := a.gRPCServer.Serve(l); err != nil {
-		panic(fmt.Errorf("%s: %w", op, err))
+		return fmt.Errorf("%s: %w", op, err)
 	}
+
+	return nil
 }
 
-func (a *GrpcApp) Stop() {
+// Stop waits for running calls to finish, and cuts them off once ctx is done.
+func (a *GrpcApp) Stop(ctx context.Context) error {
 	const op = "grpcapp.Stop"
 
 	a.log.With(slog.String("op", op)).
 		Info("stopping gRPC server", slog.Int("port", a.port))
 
-	a.gRPCServer.GracefulStop()
+	stopped := make(chan struct{})
+	go func() {
+		a.gRPCServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		a.gRPCServer.Stop()
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	}
 }
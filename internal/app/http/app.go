@@ -0,0 +1,58 @@
+package httpapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type HttpApp struct {
+	log        *slog.Logger
+	httpServer *http.Server
+	port       int
+}
+
+func NewHttpApp(log *slog.Logger, port int, routes map[string]http.Handler) *HttpApp {
+	mux := http.NewServeMux()
+	for pattern, handler := range routes {
+		mux.Handle(pattern, handler)
+	}
+
+	return &HttpApp{
+		log: log,
+		httpServer: &http.Server{
+			Addr:              fmt.Sprintf(":%d", port),
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+		port: port,
+	}
+}
+
+func (a *HttpApp) Run() error {
+	const op = "httpapp.Run"
+
+	a.log.Info("http server started", slog.String("addr", a.httpServer.Addr))
+
+	if err := a.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (a *HttpApp) Stop(ctx context.Context) error {
+	const op = "httpapp.Stop"
+
+	a.log.With(slog.String("op", op)).
+		Info("stopping http server", slog.Int("port", a.port))
+
+	if err := a.httpServer.Shutdown(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
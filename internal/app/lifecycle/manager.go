@@ -0,0 +1,129 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// RunFunc runs a component until ctx is cancelled. Components without a loop of their own have none.
+type RunFunc func(ctx context.Context) error
+
+// StopFunc releases a component. ctx carries the shutdown deadline.
+type StopFunc func(ctx context.Context) error
+
+type component struct {
+	name   string
+	run    RunFunc
+	stop   StopFunc
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Manager launches the run funcs of components in the order they were added without waiting for one
+// to be ready before launching the next, so a component must not rely on the ones added before it
+// already serving. It stops them in reverse order within the shutdown timeout, so a component has
+// to be added after everything it depends on.
+type Manager struct {
+	log             *slog.Logger
+	shutdownTimeout time.Duration
+	components      []*component
+}
+
+func New(log *slog.Logger, shutdownTimeout time.Duration) *Manager {
+	return &Manager{
+		log:             log,
+		shutdownTimeout: shutdownTimeout,
+	}
+}
+
+func (m *Manager) Add(name string, run RunFunc, stop StopFunc) {
+	m.components = append(m.components, &component{name: name, run: run, stop: stop})
+}
+
+// Run blocks until ctx is done or a component fails, then shuts everything down.
+func (m *Manager) Run(ctx context.Context) error {
+	failed := make(chan error, len(m.components))
+
+	for _, c := range m.components {
+		if c.run != nil {
+			runCtx, cancel := context.WithCancel(context.Background())
+			c.cancel = cancel
+			c.done = make(chan struct{})
+
+			go func(c *component) {
+				defer close(c.done)
+
+				err := c.run(runCtx)
+				if runCtx.Err() != nil {
+					return
+				}
+				if err == nil {
+					err = errors.New("stopped unexpectedly")
+				}
+				failed <- fmt.Errorf("%s: %w", c.name, err)
+			}(c)
+		}
+
+		m.log.Info("component started", "component", c.name)
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		m.log.Info("shutting down")
+	case runErr = <-failed:
+		m.log.Error("component failed, shutting down", "error", runErr)
+	}
+
+	return errors.Join(runErr, m.shutdown())
+}
+
+func (m *Manager) shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.shutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(m.components) - 1; i >= 0; i-- {
+		c := m.components[i]
+		start := time.Now()
+
+		if c.stop != nil {
+			if err := m.stop(ctx, c); err != nil {
+				errs = append(errs, fmt.Errorf("stop %s: %w", c.name, err))
+			}
+		}
+
+		if c.cancel != nil {
+			c.cancel()
+			select {
+			case <-c.done:
+			case <-ctx.Done():
+				errs = append(errs, fmt.Errorf("%s did not stop before the shutdown deadline", c.name))
+				continue
+			}
+		}
+
+		m.log.Info("component stopped", "component", c.name, "duration", time.Since(start))
+	}
+
+	return errors.Join(errs...)
+}
+
+// stop runs the stop func of c until it returns or the shutdown deadline passes. A stop func that
+// ignores ctx is abandoned at the deadline and left to finish in the background.
+func (m *Manager) stop(ctx context.Context, c *component) error {
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- c.stop(ctx)
+	}()
+
+	select {
+	case err := <-stopped:
+		return err
+	case <-ctx.Done():
+		return errors.New("did not stop before the shutdown deadline")
+	}
+}
@@ -0,0 +1,42 @@
+package lifecycle
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestShutdownAbandonsStopFuncsAtTheDeadline(t *testing.T) {
+	m := New(slog.New(slog.NewTextHandler(io.Discard, nil)), 50*time.Millisecond)
+
+	stopped := make(chan string, 2)
+	m.Add("stuck", nil, func(context.Context) error {
+		select {}
+	})
+	m.Add("dependent", nil, func(context.Context) error {
+		stopped <- "dependent"
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("got no error for the stuck component")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("shutdown did not return after its deadline")
+	}
+	if len(stopped) != 1 {
+		t.Fatal("the component stopped before the stuck one was not stopped")
+	}
+}
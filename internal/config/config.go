@@ -9,40 +9,283 @@ import (
 )
 
 type Config struct {
-	Env               string // dev || prod
-	GRPC              GRPCConfig
-	SchemaRegistryUrl string
-	KafkaHost         string
-	MongoURL          string
-	MongoDB           string
+	Env                 string // dev || prod
+	ShutdownTimeout     time.Duration
+	GRPC                GRPCConfig
+	Auth                AuthConfig
+	HTTP                HTTPConfig
+	SchemaRegistry      SchemaRegistryConfig
+	KafkaHost           string
+	AvroTextualFallback bool // accept Avro JSON values that are not in the Confluent wire format
+	Consumer            ConsumerConfig
+	ConsumerRetry       ConsumerRetryConfig
+	MongoURL            string
+	MongoDB             string
+	Outbox              OutboxConfig
+	Download            DownloadConfig
+	Reconciler          ReconcilerConfig
 }
 
+// GRPCConfig is the gRPC server. The admin service, which lists, replays and purges dead letters
+// and replays topics, is only served with AdminEnabled.
 type GRPCConfig struct {
-	Port    int
-	Timeout time.Duration
+	Port         int
+	Timeout      time.Duration
+	AdminEnabled bool
+}
+
+// AuthConfig verifies the JWTs of gRPC callers with exactly one key source: a JWKS endpoint, cached for
+// JWKSRefreshInterval, a JWKS file or static HMAC keys (id1:secret1,id2:secret2). Tokens have to be issued
+// by Issuer for Audience; Leeway tolerates clock skew. The roles of a caller are read from RolesClaim,
+// callers with AdminRole may work on the projects of every owner and use the admin service.
+type AuthConfig struct {
+	JWKSURL             string
+	JWKSFile            string
+	HMACKeys            string
+	JWKSRefreshInterval time.Duration
+	JWKSTimeout         time.Duration
+	Issuer              string
+	Audience            string
+	Leeway              time.Duration
+	RolesClaim          string
+	AdminRole           string
+}
+
+// HTTPConfig serves downloads on Port. Health checks and metrics are served on InternalPort, which
+// is not meant to be exposed outside the cluster.
+type HTTPConfig struct {
+	Port         int
+	InternalPort int
+}
+
+type SchemaRegistryConfig struct {
+	URL             string
+	RefreshInterval time.Duration
+	Timeout         time.Duration
+	MaxAttempts     int
+	InitialBackoff  time.Duration
+	MaxBackoff      time.Duration
+	Username        string
+	Password        string
+	CAFile          string
+	CertFile        string
+	KeyFile         string
+	BundleFile      string // used when the registry is unreachable, or instead of it when URL is empty
+}
+
+type DownloadConfig struct {
+	BaseURL     string
+	Root        string
+	URLTTL      time.Duration
+	SigningKeys string // id1:secret1,id2:secret2 - the first one signs, all verify
+}
+
+// ConsumerConfig is the consumer group membership and concurrency. AutoOffsetReset (earliest or latest)
+// applies to partitions the group has no committed offset for. Workers handle messages of different
+// projects concurrently, each queueing up to WorkerQueueSize messages. A worker applies up to BatchSize
+// queued messages at once, waiting up to BatchWindow to fill a batch; a BatchSize of 1 turns batching off.
+// Batches are filled from the queue, so WorkerQueueSize is at least BatchSize.
+// The consumer reports unhealthy when its lag, checked every LagCheckInterval, exceeds MaxLag messages
+// or more than MaxConsecutiveErrors errors happen in a row; 0 disables a limit. When partitions are
+// revoked, messages being handled get RevokeTimeout to finish; it has to stay below MaxPollInterval.
+type ConsumerConfig struct {
+	GroupID              string
+	SessionTimeout       time.Duration
+	HeartbeatInterval    time.Duration
+	MaxPollInterval      time.Duration
+	AutoOffsetReset      string
+	Workers              int
+	WorkerQueueSize      int
+	BatchSize            int
+	BatchWindow          time.Duration
+	LagCheckInterval     time.Duration
+	MaxLag               int64
+	MaxConsecutiveErrors int
+	RevokeTimeout        time.Duration
+}
+
+// ConsumerRetryConfig bounds in-place retries of a failed message, and of sending it to the DLQ.
+// The whole retry budget has to stay well below ConsumerConfig.MaxPollInterval or the consumer
+// is kicked out of the group.
+type ConsumerRetryConfig struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// OutboxConfig controls the relay of outbox records. Every PollInterval up to BatchSize records due for
+// an attempt are published; a failed record is retried with exponential backoff and, after MaxAttempts
+// attempts, marked failed and left alone. Only the replica holding the relay lease publishes; the lease
+// is renewed every poll and taken over by another replica once it was not renewed for LeaseTTL.
+type OutboxConfig struct {
+	PollInterval   time.Duration
+	BatchSize      int64
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	LeaseTTL       time.Duration
+}
+
+// ReconcilerConfig controls the search for projects stuck in a status. SLAs lists the statuses it watches
+// as STATUS:duration[:MARK_AS], e.g. QUEUED:30m,GENERATING:2h,STALE:24h:FAILED; projects are marked
+// STALE unless MARK_AS says otherwise. Every Interval up to BatchSize projects per status are marked.
+// With Rerequest the generation request of a project marked STALE is sent again.
+type ReconcilerConfig struct {
+	Interval  time.Duration
+	SLAs      string
+	BatchSize int64
+	Rerequest bool
 }
 
 func MustLoad() *Config {
 	loadEnvFile()
 
 	env := getEnv("ENV", "dev")
+	shutdownTimeout := getEnvAsDuration("SHUTDOWN_TIMEOUT", 30*time.Second)
 	grpcPort := getEnvAsInt("GRPC_PORT", 50051)
 	grpcTimeout := getEnvAsDuration("GRPC_TIMEOUT", 10*time.Second)
+	adminAPIEnabled := getEnvAsBool("ADMIN_API_ENABLED", false)
+	authJWKSURL := getEnv("AUTH_JWKS_URL", "")
+	authJWKSFile := getEnv("AUTH_JWKS_FILE", "")
+	authHMACKeys := getEnv("AUTH_HMAC_KEYS", "")
+	authJWKSRefreshInterval := getEnvAsDuration("AUTH_JWKS_REFRESH_INTERVAL", 10*time.Minute)
+	authJWKSTimeout := getEnvAsDuration("AUTH_JWKS_TIMEOUT", 5*time.Second)
+	authIssuer := getEnv("AUTH_ISSUER", "")
+	authAudience := getEnv("AUTH_AUDIENCE", "project-service")
+	authLeeway := getEnvAsDuration("AUTH_LEEWAY", 30*time.Second)
+	authRolesClaim := getEnv("AUTH_ROLES_CLAIM", "roles")
+	authAdminRole := getEnv("AUTH_ADMIN_ROLE", "admin")
+	httpPort := getEnvAsInt("HTTP_PORT", 8080)
+	internalHTTPPort := getEnvAsInt("INTERNAL_HTTP_PORT", 8082)
 	schemaRegistryUrl := getEnv("SCHEMA_REGISTRY_URL", "http://localhost:6767")
+	schemaRefreshInterval := getEnvAsDuration("SCHEMA_REFRESH_INTERVAL", 5*time.Minute)
+	schemaRegistryTimeout := getEnvAsDuration("SCHEMA_REGISTRY_TIMEOUT", 5*time.Second)
+	schemaRegistryMaxAttempts := getEnvAsInt("SCHEMA_REGISTRY_MAX_ATTEMPTS", 4)
+	schemaRegistryInitialBackoff := getEnvAsDuration("SCHEMA_REGISTRY_INITIAL_BACKOFF", 250*time.Millisecond)
+	schemaRegistryMaxBackoff := getEnvAsDuration("SCHEMA_REGISTRY_MAX_BACKOFF", 5*time.Second)
+	schemaRegistryUsername := getEnv("SCHEMA_REGISTRY_USERNAME", "")
+	schemaRegistryPassword := getEnv("SCHEMA_REGISTRY_PASSWORD", "")
+	schemaRegistryCAFile := getEnv("SCHEMA_REGISTRY_CA_FILE", "")
+	schemaRegistryCertFile := getEnv("SCHEMA_REGISTRY_CERT_FILE", "")
+	schemaRegistryKeyFile := getEnv("SCHEMA_REGISTRY_KEY_FILE", "")
+	schemaBundleFile := getEnv("SCHEMA_BUNDLE_FILE", "")
 	kafkaHost := getEnv("KAFKA_HOST", "http://localhost:9092")
+	avroTextualFallback := getEnvAsBool("AVRO_TEXTUAL_FALLBACK", true)
+	consumerGroupID := getEnv("CONSUMER_GROUP_ID", "project-psg")
+	consumerSessionTimeout := getEnvAsDuration("CONSUMER_SESSION_TIMEOUT", 45*time.Second)
+	consumerHeartbeatInterval := getEnvAsDuration("CONSUMER_HEARTBEAT_INTERVAL", 3*time.Second)
+	consumerMaxPollInterval := getEnvAsDuration("CONSUMER_MAX_POLL_INTERVAL", 5*time.Minute)
+	consumerAutoOffsetReset := getEnv("CONSUMER_AUTO_OFFSET_RESET", "latest")
+	consumerWorkers := getEnvAsInt("CONSUMER_WORKERS", 8)
+	consumerBatchSize := getEnvAsInt("CONSUMER_BATCH_SIZE", 100)
+	consumerWorkerQueueSize := max(getEnvAsInt("CONSUMER_WORKER_QUEUE_SIZE", consumerBatchSize), consumerBatchSize)
+	consumerBatchWindow := getEnvAsDuration("CONSUMER_BATCH_WINDOW", 20*time.Millisecond)
+	consumerLagCheckInterval := getEnvAsDuration("CONSUMER_LAG_CHECK_INTERVAL", 15*time.Second)
+	consumerMaxLag := getEnvAsInt("CONSUMER_MAX_LAG", 10000)
+	consumerMaxConsecutiveErrors := getEnvAsInt("CONSUMER_MAX_CONSECUTIVE_ERRORS", 20)
+	consumerRevokeTimeout := getEnvAsDuration("CONSUMER_REVOKE_TIMEOUT", 10*time.Second)
+	consumerMaxAttempts := getEnvAsInt("CONSUMER_MAX_ATTEMPTS", 5)
+	consumerInitialBackoff := getEnvAsDuration("CONSUMER_RETRY_INITIAL_BACKOFF", 500*time.Millisecond)
+	consumerMaxBackoff := getEnvAsDuration("CONSUMER_RETRY_MAX_BACKOFF", 10*time.Second)
 	mongoUrl := buildMongoURL()
 	mongoDb := getEnv("MONGO_DB", "project-service-db")
+	outboxPollInterval := getEnvAsDuration("OUTBOX_POLL_INTERVAL", time.Second)
+	outboxBatchSize := getEnvAsInt("OUTBOX_BATCH_SIZE", 100)
+	outboxMaxAttempts := getEnvAsInt("OUTBOX_MAX_ATTEMPTS", 20)
+	outboxInitialBackoff := getEnvAsDuration("OUTBOX_INITIAL_BACKOFF", time.Second)
+	outboxMaxBackoff := getEnvAsDuration("OUTBOX_MAX_BACKOFF", time.Minute)
+	outboxLeaseTTL := getEnvAsDuration("OUTBOX_LEASE_TTL", 30*time.Second)
+	downloadBaseURL := getEnv("DOWNLOAD_BASE_URL", fmt.Sprintf("http://localhost:%d/downloads", httpPort))
+	downloadRoot := getEnv("DOWNLOAD_ROOT", "./data/zips")
+	downloadURLTTL := getEnvAsDuration("DOWNLOAD_URL_TTL", 15*time.Minute)
+	downloadSigningKeys := getEnv("DOWNLOAD_SIGNING_KEYS", "")
+	reconcileInterval := getEnvAsDuration("RECONCILE_INTERVAL", time.Minute)
+	reconcileSLAs := getEnv("RECONCILE_SLAS", "QUEUED:30m,GENERATING:2h")
+	reconcileBatchSize := getEnvAsInt("RECONCILE_BATCH_SIZE", 100)
+	reconcileRerequest := getEnvAsBool("RECONCILE_REREQUEST", false)
 
 	return &Config{
-		Env: env,
+		Env:             env,
+		ShutdownTimeout: shutdownTimeout,
 		GRPC: GRPCConfig{
-			Port:    grpcPort,
-			Timeout: grpcTimeout,
+			Port:         grpcPort,
+			Timeout:      grpcTimeout,
+			AdminEnabled: adminAPIEnabled,
+		},
+		Auth: AuthConfig{
+			JWKSURL:             authJWKSURL,
+			JWKSFile:            authJWKSFile,
+			HMACKeys:            authHMACKeys,
+			JWKSRefreshInterval: authJWKSRefreshInterval,
+			JWKSTimeout:         authJWKSTimeout,
+			Issuer:              authIssuer,
+			Audience:            authAudience,
+			Leeway:              authLeeway,
+			RolesClaim:          authRolesClaim,
+			AdminRole:           authAdminRole,
+		},
+		HTTP: HTTPConfig{
+			Port:         httpPort,
+			InternalPort: internalHTTPPort,
+		},
+		SchemaRegistry: SchemaRegistryConfig{
+			URL:             schemaRegistryUrl,
+			RefreshInterval: schemaRefreshInterval,
+			Timeout:         schemaRegistryTimeout,
+			MaxAttempts:     schemaRegistryMaxAttempts,
+			InitialBackoff:  schemaRegistryInitialBackoff,
+			MaxBackoff:      schemaRegistryMaxBackoff,
+			Username:        schemaRegistryUsername,
+			Password:        schemaRegistryPassword,
+			CAFile:          schemaRegistryCAFile,
+			CertFile:        schemaRegistryCertFile,
+			KeyFile:         schemaRegistryKeyFile,
+			BundleFile:      schemaBundleFile,
+		},
+		KafkaHost:           kafkaHost,
+		AvroTextualFallback: avroTextualFallback,
+		Consumer: ConsumerConfig{
+			GroupID:              consumerGroupID,
+			SessionTimeout:       consumerSessionTimeout,
+			HeartbeatInterval:    consumerHeartbeatInterval,
+			MaxPollInterval:      consumerMaxPollInterval,
+			AutoOffsetReset:      consumerAutoOffsetReset,
+			Workers:              consumerWorkers,
+			WorkerQueueSize:      consumerWorkerQueueSize,
+			BatchSize:            consumerBatchSize,
+			BatchWindow:          consumerBatchWindow,
+			LagCheckInterval:     consumerLagCheckInterval,
+			MaxLag:               int64(consumerMaxLag),
+			MaxConsecutiveErrors: consumerMaxConsecutiveErrors,
+			RevokeTimeout:        consumerRevokeTimeout,
+		},
+		ConsumerRetry: ConsumerRetryConfig{
+			MaxAttempts:    consumerMaxAttempts,
+			InitialBackoff: consumerInitialBackoff,
+			MaxBackoff:     consumerMaxBackoff,
+		},
+		MongoURL: mongoUrl,
+		MongoDB:  mongoDb,
+		Outbox: OutboxConfig{
+			PollInterval:   outboxPollInterval,
+			BatchSize:      int64(outboxBatchSize),
+			MaxAttempts:    outboxMaxAttempts,
+			InitialBackoff: outboxInitialBackoff,
+			MaxBackoff:     outboxMaxBackoff,
+			LeaseTTL:       outboxLeaseTTL,
+		},
+		Download: DownloadConfig{
+			BaseURL:     downloadBaseURL,
+			Root:        downloadRoot,
+			URLTTL:      downloadURLTTL,
+			SigningKeys: downloadSigningKeys,
+		},
+		Reconciler: ReconcilerConfig{
+			Interval:  reconcileInterval,
+			SLAs:      reconcileSLAs,
+			BatchSize: int64(reconcileBatchSize),
+			Rerequest: reconcileRerequest,
 		},
-		SchemaRegistryUrl: schemaRegistryUrl,
-		KafkaHost:         kafkaHost,
-		MongoURL:          mongoUrl,
-		MongoDB:           mongoDb,
 	}
 }
 
@@ -67,6 +310,14 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
 func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	valueStr := getEnv(key, "")
 	if value, err := time.ParseDuration(valueStr); err == nil {
@@ -75,12 +326,25 @@ func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	return defaultValue
 }
 
+// buildMongoURL returns MONGO_URL as is, or builds the URL from MONGO_HOST, MONGO_PORT and MONGO_DB.
+// MONGO_REPLICA_SET names the replica set, which the transactions of the outbox require; it is left
+// out of the URL when empty.
 func buildMongoURL() string {
+	if url := getEnv("MONGO_URL", ""); url != "" {
+		return url
+	}
+
 	//user := getEnv("MONGO_USERNAME", "root")
 	//password := getEnv("MONGO_PASSWORD", "password")
 	db := getEnv("MONGO_DB", "project-service-db")
 	port := getEnv("MONGO_PORT", "27017")
 	host := getEnv("MONGO_HOST", "localhost")
+	replicaSet := getEnv("MONGO_REPLICA_SET", "")
+
+	url := fmt.Sprintf("mongodb://%s:%s/%s", host, port, db)
+	if replicaSet != "" {
+		url += "?replicaSet=" + replicaSet
+	}
 
-	return fmt.Sprintf("mongodb://%s:%s/%s", host, port, db)
+	return url
 }
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// MaxStoredArtifacts bounds Project.Artifacts, older builds are dropped first.
+const MaxStoredArtifacts = 50
+
+type ZipArtifact struct {
+	Url              string             `bson:"url" json:"url"`
+	Checksum         string             `bson:"checksum,omitempty" json:"checksum,omitempty"`
+	Size             int64              `bson:"size,omitempty" json:"size,omitempty"`
+	GeneratorVersion string             `bson:"generatorVersion,omitempty" json:"generatorVersion,omitempty"`
+	Revision         int64              `bson:"revision" json:"revision"`
+	CreatedAt        primitive.DateTime `bson:"createdAt" json:"createdAt"`
+}
+
+// ArtifactId names the artifact of a project revision in download links without revealing where it is stored.
+type ArtifactId struct {
+	Owner    string
+	Name     string
+	Revision int64
+}
+
+func (id ArtifactId) String() string {
+	return url.PathEscape(id.Owner) + "/" + url.PathEscape(id.Name) + "/" + strconv.FormatInt(id.Revision, 10)
+}
+
+func ParseArtifactId(raw string) (ArtifactId, error) {
+	parts := strings.Split(raw, "/")
+	if len(parts) != 3 {
+		return ArtifactId{}, ErrArtifactNotFound
+	}
+
+	owner, err := url.PathUnescape(parts[0])
+	if err != nil {
+		return ArtifactId{}, ErrArtifactNotFound
+	}
+	name, err := url.PathUnescape(parts[1])
+	if err != nil {
+		return ArtifactId{}, ErrArtifactNotFound
+	}
+	revision, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return ArtifactId{}, ErrArtifactNotFound
+	}
+
+	return ArtifactId{Owner: owner, Name: name, Revision: revision}, nil
+}
+
+// Artifact returns the most recent artifact built from revision, nil if there is none.
+func (p *Project) Artifact(revision int64) *ZipArtifact {
+	for i := len(p.Artifacts) - 1; i >= 0; i-- {
+		if p.Artifacts[i].Revision == revision {
+			return &p.Artifacts[i]
+		}
+	}
+	return nil
+}
+
+// LatestArtifact returns the most recently added artifact or nil if the project was never built.
+func (p *Project) LatestArtifact() *ZipArtifact {
+	if len(p.Artifacts) == 0 {
+		return nil
+	}
+	return &p.Artifacts[len(p.Artifacts)-1]
+}
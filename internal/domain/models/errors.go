@@ -0,0 +1,18 @@
+package models
+
+import (
+	"errors"
+)
+
+var (
+	ErrProjectNotFound          = errors.New("проект не найден")
+	ErrInvalidStatusTransition  = errors.New("недопустимый переход статуса проекта")
+	ErrStaleStatusUpdate        = errors.New("обновление статуса от устаревшей генерации")
+	ErrStatusUnchanged          = errors.New("проект уже в этом статусе")
+	ErrInvalidProjectDefinition = errors.New("некорректное описание проекта")
+	ErrProjectNotGenerated      = errors.New("проект еще не сгенерирован")
+	ErrInvalidEnvironment       = errors.New("некорректное имя окружения")
+	ErrEnvironmentNotFound      = errors.New("окружение не найдено")
+	ErrArtifactNotFound         = errors.New("артефакт проекта не найден")
+	ErrEventAlreadyProcessed    = errors.New("событие уже обработано")
+)
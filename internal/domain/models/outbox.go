@@ -0,0 +1,20 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OutboxRecord is an event waiting to be published. It is pending until it is sent, or marked failed
+// once it ran out of attempts.
+type OutboxRecord struct {
+	ID            primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Topic         string              `bson:"topic" json:"topic"`
+	Key           string              `bson:"key" json:"key"`
+	Value         []byte              `bson:"value" json:"value"`
+	Attempts      int                 `bson:"attempts" json:"attempts"`
+	LastError     string              `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	NextAttemptAt primitive.DateTime  `bson:"nextAttemptAt" json:"nextAttemptAt"`
+	SentAt        *primitive.DateTime `bson:"sentAt,omitempty" json:"sentAt,omitempty"`
+	FailedAt      *primitive.DateTime `bson:"failedAt,omitempty" json:"failedAt,omitempty"`
+	CreatedAt     primitive.DateTime  `bson:"createdAt" json:"createdAt"`
+}
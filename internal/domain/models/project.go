@@ -4,15 +4,30 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const DefaultEnvironment = "production"
+
 type Project struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	ComposeId string             `bson:"composeId" json:"composeId"`
-	Owner     string             `bson:"owner" json:"owner"`
-	Name      string             `bson:"name" json:"name"`
-	Data      string             `bson:"data" json:"data"`
-	Status    string             `bson:"status" json:"status"`
-	UrlZip    string             `bson:"urlZip" json:"urlZip"`
-	UrlDeploy string             `bson:"urlDeploy" json:"urlDeploy"`
-	UpdatedAt primitive.DateTime `bson:"updatedAt" json:"updatedAt"`
-	CreatedAt primitive.DateTime `bson:"createdAt" json:"createdAt"`
+	ID                 primitive.ObjectID           `bson:"_id,omitempty" json:"id"`
+	ComposeId          string                       `bson:"composeId" json:"composeId"`
+	Owner              string                       `bson:"owner" json:"owner"`
+	Name               string                       `bson:"name" json:"name"`
+	Data               string                       `bson:"data" json:"data"`
+	Revision           int64                        `bson:"revision" json:"revision"`
+	Status             string                       `bson:"status" json:"status"`
+	StatusUpdatedAt    primitive.DateTime           `bson:"statusUpdatedAt" json:"statusUpdatedAt"`
+	GenerationJobId    string                       `bson:"generationJobId,omitempty" json:"generationJobId,omitempty"`
+	GenerationRevision int64                        `bson:"generationRevision" json:"generationRevision"`
+	Artifacts          []ZipArtifact                `bson:"artifacts,omitempty" json:"artifacts,omitempty"`
+	Environments       map[string]DeployEnvironment `bson:"environments,omitempty" json:"environments,omitempty"`
+	UpdatedAt          primitive.DateTime           `bson:"updatedAt" json:"updatedAt"`
+	CreatedAt          primitive.DateTime           `bson:"createdAt" json:"createdAt"`
+}
+
+// DeployEnvironment is keyed by its name (e.g. preview, production) in Project.Environments.
+type DeployEnvironment struct {
+	UrlDeploy         string             `bson:"urlDeploy" json:"urlDeploy"`
+	Status            string             `bson:"status" json:"status"`
+	Revision          int64              `bson:"revision" json:"revision"`
+	RequestedRevision int64              `bson:"requestedRevision" json:"requestedRevision"`
+	UpdatedAt         primitive.DateTime `bson:"updatedAt" json:"updatedAt"`
 }
@@ -0,0 +1,74 @@
+package models
+
+import "sort"
+
+const (
+	StatusNew        = "NEW"
+	StatusQueued     = "QUEUED"
+	StatusGenerating = "GENERATING"
+	StatusGenerated  = "GENERATED"
+	StatusFailed     = "FAILED"
+	// StatusStale marks a generation that has not reported progress within its SLA. Late updates
+	// of the same job still apply.
+	StatusStale = "STALE"
+)
+
+const (
+	DeployStatusQueued         = "DEPLOY_QUEUED"
+	DeployStatusDeployed       = "DEPLOYED"
+	DeployStatusUndeployQueued = "UNDEPLOY_QUEUED"
+	DeployStatusUndeployed     = "UNDEPLOYED"
+)
+
+// status -> statuses it may move to. Besides the flow of GenerateProject (QUEUED first), the generator
+// reports GENERATING, GENERATED and FAILED for projects it builds on its own, from NEW or a finished build.
+var statusTransitions = map[string][]string{
+	StatusNew:        {StatusQueued, StatusGenerating, StatusGenerated, StatusFailed},
+	StatusQueued:     {StatusGenerating, StatusGenerated, StatusFailed, StatusStale},
+	StatusGenerating: {StatusGenerated, StatusFailed, StatusStale},
+	StatusGenerated:  {StatusQueued, StatusGenerating},
+	StatusFailed:     {StatusQueued, StatusGenerating},
+	StatusStale:      {StatusQueued, StatusGenerating, StatusGenerated, StatusFailed},
+}
+
+// IsKnownStatus reports whether status is part of the state machine. Projects stored before it existed
+// may have other statuses, set by producers; they move like StatusNew does.
+func IsKnownStatus(status string) bool {
+	_, ok := statusTransitions[status]
+	return ok
+}
+
+// KnownStatuses returns the statuses of the state machine.
+func KnownStatuses() []string {
+	statuses := make([]string, 0, len(statusTransitions))
+	for status := range statusTransitions {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	return statuses
+}
+
+func CanTransition(from, to string) bool {
+	if !IsKnownStatus(from) {
+		from = StatusNew
+	}
+	for _, status := range statusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusesAllowedBefore returns the known statuses a project may be in to move to status. Unknown ones
+// may move to it as well if CanTransition(StatusNew, status).
+func StatusesAllowedBefore(status string) []string {
+	var from []string
+	for candidate := range statusTransitions {
+		if CanTransition(candidate, status) {
+			from = append(from, candidate)
+		}
+	}
+	sort.Strings(from)
+	return from
+}
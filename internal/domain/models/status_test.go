@@ -0,0 +1,50 @@
+package models
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		// GenerateProject
+		{StatusNew, StatusQueued, true},
+		{StatusQueued, StatusGenerating, true},
+		{StatusGenerating, StatusGenerated, true},
+		{StatusGenerated, StatusQueued, true},
+		// the generator building on its own
+		{StatusNew, StatusGenerating, true},
+		{StatusNew, StatusGenerated, true},
+		{StatusNew, StatusFailed, true},
+		{StatusGenerated, StatusGenerating, true},
+		{StatusFailed, StatusGenerating, true},
+		// statuses stored before the state machine move like NEW
+		{"READY", StatusGenerating, true},
+		{"", StatusQueued, true},
+		{"READY", StatusStale, false},
+		{StatusGenerated, StatusNew, false},
+		{StatusGenerating, StatusQueued, false},
+		{StatusNew, StatusStale, false},
+		{StatusNew, "DEPLOYING", false},
+	}
+
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestStatusesAllowedBeforeOnlyListsKnownStatuses(t *testing.T) {
+	from := StatusesAllowedBefore(StatusGenerating)
+
+	want := []string{StatusFailed, StatusGenerated, StatusNew, StatusQueued, StatusStale}
+	if len(from) != len(want) {
+		t.Fatalf("got %v, want %v", from, want)
+	}
+	for i := range want {
+		if from[i] != want[i] {
+			t.Fatalf("got %v, want %v", from, want)
+		}
+	}
+}
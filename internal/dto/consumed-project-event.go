@@ -0,0 +1,31 @@
+package dto
+
+// ConsumedProjectEvent is one of the consumed project events, exactly one field is set.
+// Events applied in batches are passed in this form, in consumption order.
+type ConsumedProjectEvent struct {
+	Status *ProjectStatusDTO
+	Zip    *NewZipDTO
+	Deploy *DeployPayloadDTO
+}
+
+// Outcomes of applying a consumed project event. ConsumedEventRejected is the outcome of status updates
+// the state machine does not allow, ConsumedEventInvalid that of deploy payloads with an invalid
+// environment name, ConsumedEventReapplied is the outcome of events that
+// were processed before and are applied again because a replay reprocesses them.
+const (
+	ConsumedEventApplied         = "applied"
+	ConsumedEventReapplied       = "reapplied"
+	ConsumedEventDuplicate       = "duplicate"
+	ConsumedEventSkipped         = "skipped"
+	ConsumedEventRejected        = "rejected"
+	ConsumedEventInvalid         = "invalid"
+	ConsumedEventProjectNotFound = "project_not_found"
+)
+
+// ConsumedEventPreview is what applying a consumed event would do. Change describes the
+// project change of applied events, e.g. "status GENERATING -> READY".
+type ConsumedEventPreview struct {
+	Outcome   string
+	ProjectId string
+	Change    string
+}
@@ -0,0 +1,10 @@
+package dto
+
+import "project-service/internal/events"
+
+const (
+	DeployRequestedTopic   = "DeployRequested"
+	UndeployRequestedTopic = "UndeployRequested"
+)
+
+type DeployCommandDTO = events.DeployCommand
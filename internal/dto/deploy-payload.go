@@ -1,17 +1,20 @@
 package dto
 
-type DeployPayloadDTO struct {
-	Owner string
-	Name  string
-	Url   string
-}
+import "project-service/internal/events"
+
+const DeployPayloadTopic = "DeployPayload"
 
-func MapNativeToDeployPayloadDTO(native interface{}) DeployPayloadDTO {
-	nativeMap, _ := native.(map[string]interface{})
+type DeployPayloadDTO = events.DeployPayload
 
-	return DeployPayloadDTO{
-		Owner: nativeMap["owner"].(string),
-		Name:  nativeMap["name"].(string),
-		Url:   nativeMap["url"].(string),
+func MapNativeToDeployPayloadDTO(native interface{}) (DeployPayloadDTO, error) {
+	event, err := events.DecodeDeployPayload(native)
+	if err != nil {
+		return event, invalidMessage(err)
 	}
+
+	return event, requireNonEmpty(map[string]string{
+		"owner": event.Owner,
+		"name":  event.Name,
+		"url":   event.Url,
+	})
 }
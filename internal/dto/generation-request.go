@@ -0,0 +1,7 @@
+package dto
+
+import "project-service/internal/events"
+
+const GenerationRequestedTopic = "GenerationRequested"
+
+type GenerationRequestDTO = events.GenerationRequest
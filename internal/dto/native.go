@@ -0,0 +1,30 @@
+package dto
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var ErrInvalidMessage = errors.New("invalid message")
+
+func invalidMessage(err error) error {
+	return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+}
+
+// requireNonEmpty checks string fields that Avro allows to be empty but this service cannot work without.
+func requireNonEmpty(fields map[string]string) error {
+	var empty []string
+	for name, value := range fields {
+		if value == "" {
+			empty = append(empty, name)
+		}
+	}
+	if len(empty) == 0 {
+		return nil
+	}
+	sort.Strings(empty)
+
+	return fmt.Errorf("%w: empty fields %s", ErrInvalidMessage, strings.Join(empty, ", "))
+}
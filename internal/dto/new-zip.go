@@ -1,17 +1,22 @@
 package dto
 
-type NewZipDTO struct {
-	Owner string
-	Name  string
-	Url   string
-}
+import "project-service/internal/events"
+
+const NewZipTopic = "NewZip"
 
-func MapNativeToNewZipDTO(native interface{}) NewZipDTO {
-	nativeMap, _ := native.(map[string]interface{})
+type NewZipDTO = events.NewZip
 
-	return NewZipDTO{
-		Owner: nativeMap["owner"].(string),
-		Name:  nativeMap["name"].(string),
-		Url:   nativeMap["url"].(string),
+// MapNativeToNewZipDTO accepts both the original owner/name/url record and
+// the extended one carrying optional artifact metadata.
+func MapNativeToNewZipDTO(native interface{}) (NewZipDTO, error) {
+	event, err := events.DecodeNewZip(native)
+	if err != nil {
+		return event, invalidMessage(err)
 	}
+
+	return event, requireNonEmpty(map[string]string{
+		"owner": event.Owner,
+		"name":  event.Name,
+		"url":   event.Url,
+	})
 }
@@ -0,0 +1,31 @@
+package dto
+
+import (
+	"project-service/internal/domain/models"
+	"project-service/internal/events"
+)
+
+const (
+	ProjectCreatedTopic = "ProjectCreated"
+	ProjectUpdatedTopic = "ProjectUpdated"
+	ProjectDeletedTopic = "ProjectDeleted"
+)
+
+type ProjectEventDTO = events.ProjectEvent
+
+func MapProjectToProjectEventDTO(project *models.Project) ProjectEventDTO {
+	urlZip := ""
+	if artifact := project.LatestArtifact(); artifact != nil {
+		urlZip = artifact.Url
+	}
+
+	return ProjectEventDTO{
+		Owner:     project.Owner,
+		Name:      project.Name,
+		Data:      project.Data,
+		Status:    project.Status,
+		UrlZip:    urlZip,
+		UrlDeploy: project.Environments[models.DefaultEnvironment].UrlDeploy,
+		UpdatedAt: project.UpdatedAt.Time().UnixMilli(),
+	}
+}
@@ -1,15 +1,19 @@
 package dto
 
-type ProjectStatusDTO struct {
-	Id     string
-	Status string
-}
+import "project-service/internal/events"
+
+const ProjectStatusTopic = "ProjectStatus"
 
-func MapNativeToProjectStatusDTO(native interface{}) ProjectStatusDTO {
-	nativeMap, _ := native.(map[string]interface{})
+type ProjectStatusDTO = events.ProjectStatus
 
-	return ProjectStatusDTO{
-		Id:     nativeMap["id"].(string),
-		Status: nativeMap["status"].(string),
+func MapNativeToProjectStatusDTO(native interface{}) (ProjectStatusDTO, error) {
+	event, err := events.DecodeProjectStatus(native)
+	if err != nil {
+		return event, invalidMessage(err)
 	}
+
+	return event, requireNonEmpty(map[string]string{
+		"id":     event.Id,
+		"status": event.Status,
+	})
 }
@@ -0,0 +1,5 @@
+// Package events holds the Avro events this service consumes and produces. Structs, schemas and
+// codecs are generated from schemas/*.avsc: edit a schema and run go generate, never the *.gen.go files.
+package events
+
+//go:generate go run ../../cmd/avrogen -pkg events -out . schemas
@@ -0,0 +1,16 @@
+package events
+
+import (
+	"project-service/internal/lib/avrogen"
+	"testing"
+)
+
+func TestGeneratedFilesMatchTheirSchemas(t *testing.T) {
+	stale, err := avrogen.Stale("events", ".", []string{"schemas"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stale) > 0 {
+		t.Fatalf("generated files are out of date with their schemas, run go generate: %v", stale)
+	}
+}
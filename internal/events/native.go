@@ -0,0 +1,150 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Helpers used by the generated decoders. A value may be plain or wrapped in an Avro union
+// ({"string": "..."}); for nullable fields absent values and nulls decode to the zero value.
+
+type fieldErrors []string
+
+func (e *fieldErrors) add(format string, args ...interface{}) {
+	*e = append(*e, fmt.Sprintf(format, args...))
+}
+
+func (e fieldErrors) err(record string) error {
+	if len(e) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%s: %s", record, strings.Join(e, "; "))
+}
+
+// field returns the unwrapped value of key and whether it is set.
+func field(record map[string]interface{}, key string, nullable bool, errs *fieldErrors) (interface{}, bool) {
+	value := record[key]
+	if union, ok := value.(map[string]interface{}); ok && len(union) == 1 {
+		for _, branchValue := range union {
+			value = branchValue
+		}
+	}
+
+	if value == nil {
+		if !nullable {
+			errs.add("field %s is required", key)
+		}
+		return nil, false
+	}
+
+	return value, true
+}
+
+func decodeString(record map[string]interface{}, key string, nullable bool, errs *fieldErrors) string {
+	value, ok := field(record, key, nullable, errs)
+	if !ok {
+		return ""
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		errs.add("field %s: expected string, got %T", key, value)
+	}
+	return s
+}
+
+func decodeBytes(record map[string]interface{}, key string, nullable bool, errs *fieldErrors) []byte {
+	value, ok := field(record, key, nullable, errs)
+	if !ok {
+		return nil
+	}
+
+	b, ok := value.([]byte)
+	if !ok {
+		errs.add("field %s: expected bytes, got %T", key, value)
+	}
+	return b
+}
+
+func decodeBoolean(record map[string]interface{}, key string, nullable bool, errs *fieldErrors) bool {
+	value, ok := field(record, key, nullable, errs)
+	if !ok {
+		return false
+	}
+
+	b, ok := value.(bool)
+	if !ok {
+		errs.add("field %s: expected boolean, got %T", key, value)
+	}
+	return b
+}
+
+func decodeInt(record map[string]interface{}, key string, nullable bool, errs *fieldErrors) int32 {
+	value, ok := field(record, key, nullable, errs)
+	if !ok {
+		return 0
+	}
+
+	i, ok := value.(int32)
+	if !ok {
+		errs.add("field %s: expected int, got %T", key, value)
+	}
+	return i
+}
+
+func decodeLong(record map[string]interface{}, key string, nullable bool, errs *fieldErrors) int64 {
+	value, ok := field(record, key, nullable, errs)
+	if !ok {
+		return 0
+	}
+
+	switch v := value.(type) {
+	case int64:
+		return v
+	case int32:
+		return int64(v)
+	default:
+		errs.add("field %s: expected long, got %T", key, value)
+		return 0
+	}
+}
+
+func decodeFloat(record map[string]interface{}, key string, nullable bool, errs *fieldErrors) float32 {
+	value, ok := field(record, key, nullable, errs)
+	if !ok {
+		return 0
+	}
+
+	f, ok := value.(float32)
+	if !ok {
+		errs.add("field %s: expected float, got %T", key, value)
+	}
+	return f
+}
+
+func decodeDouble(record map[string]interface{}, key string, nullable bool, errs *fieldErrors) float64 {
+	value, ok := field(record, key, nullable, errs)
+	if !ok {
+		return 0
+	}
+
+	switch v := value.(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	default:
+		errs.add("field %s: expected double, got %T", key, value)
+		return 0
+	}
+}
+
+// nullable encodes the value of a ["null", branch] field, zero values are written as null.
+func nullable(branch string, value interface{}, isZero bool) interface{} {
+	if isZero {
+		return nil
+	}
+
+	return map[string]interface{}{branch: value}
+}
@@ -0,0 +1,52 @@
+// Code generated by avrogen from schemas/ProjectStatus.avsc. DO NOT EDIT.
+
+package events
+
+import "fmt"
+
+const ProjectStatusSchema = `{
+  "type": "record",
+  "name": "ProjectStatus",
+  "namespace": "smartapiforge.project",
+  "fields": [
+    {"name": "id", "type": "string"},
+    {"name": "status", "type": "string"},
+    {"name": "jobId", "type": ["null", "string"], "default": null},
+    {"name": "eventId", "type": ["null", "string"], "default": null}
+  ]
+}`
+
+type ProjectStatus struct {
+	Id      string
+	Status  string
+	JobId   string
+	EventId string
+}
+
+// DecodeProjectStatus reads a ProjectStatus from its native goavro representation.
+func DecodeProjectStatus(native interface{}) (ProjectStatus, error) {
+	record, ok := native.(map[string]interface{})
+	if !ok {
+		return ProjectStatus{}, fmt.Errorf("ProjectStatus: expected a record, got %T", native)
+	}
+
+	var errs fieldErrors
+	event := ProjectStatus{
+		Id:      decodeString(record, "id", false, &errs),
+		Status:  decodeString(record, "status", false, &errs),
+		JobId:   decodeString(record, "jobId", true, &errs),
+		EventId: decodeString(record, "eventId", true, &errs),
+	}
+
+	return event, errs.err("ProjectStatus")
+}
+
+// ToNative returns the native goavro representation of the event.
+func (e ProjectStatus) ToNative() map[string]interface{} {
+	return map[string]interface{}{
+		"id":      e.Id,
+		"status":  e.Status,
+		"jobId":   nullable("string", e.JobId, e.JobId == ""),
+		"eventId": nullable("string", e.EventId, e.EventId == ""),
+	}
+}
@@ -0,0 +1,237 @@
+package adminserver
+
+import (
+	"context"
+	"errors"
+	adminProto "github.com/SmartAPIForge/protos/gen/go/admin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	grpcauth "project-service/internal/grpc/auth"
+	"project-service/internal/kafka"
+	reconcilerservice "project-service/internal/services/reconciler"
+	"time"
+)
+
+type DeadLetterQueue interface {
+	List(ctx context.Context, topic string, limit int) ([]kafka.DeadLetter, error)
+	Replay(ctx context.Context, topic string, partition int32, offset int64) error
+}
+
+type TopicReplayer interface {
+	Replay(ctx context.Context, req kafka.ReplayRequest) (kafka.ReplayReport, error)
+}
+
+type StuckProjectsReporter interface {
+	StuckProjects(ctx context.Context, owner string, limit int64) ([]reconcilerservice.StuckProject, error)
+}
+
+// AdminServer serves callers with the admin role only.
+type AdminServer struct {
+	adminProto.UnsafeAdminServiceServer
+	dlq        DeadLetterQueue
+	replayer   TopicReplayer
+	reconciler StuckProjectsReporter
+	authorizer *grpcauth.Authorizer
+}
+
+func RegisterAdminServer(
+	gRPCServer *grpc.Server,
+	dlq DeadLetterQueue,
+	replayer TopicReplayer,
+	reconciler StuckProjectsReporter,
+	authorizer *grpcauth.Authorizer,
+) {
+	adminProto.RegisterAdminServiceServer(
+		gRPCServer,
+		&AdminServer{dlq: dlq, replayer: replayer, reconciler: reconciler, authorizer: authorizer},
+	)
+}
+
+func (s *AdminServer) ListDeadLetters(
+	ctx context.Context,
+	in *adminProto.ListDeadLettersRequest,
+) (*adminProto.ListDeadLettersResponse, error) {
+	if err := s.authorizer.RequireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if in.Topic == "" {
+		return nil, status.Error(codes.InvalidArgument, "не указан топик")
+	}
+
+	limit := 50
+	if in.Limit > 0 {
+		limit = int(in.Limit)
+	}
+
+	deadLetters, err := s.dlq.List(ctx, in.Topic, limit)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	entries := make([]*adminProto.DeadLetter, 0, len(deadLetters))
+	for _, deadLetter := range deadLetters {
+		entries = append(entries, &adminProto.DeadLetter{
+			Partition:         deadLetter.Partition,
+			Offset:            deadLetter.Offset,
+			OriginalTopic:     deadLetter.OriginalTopic,
+			OriginalPartition: deadLetter.OriginalPartition,
+			OriginalOffset:    deadLetter.OriginalOffset,
+			Error:             deadLetter.Error,
+			Attempts:          int32(deadLetter.Attempts),
+			Key:               string(deadLetter.Key),
+			Value:             deadLetter.Value,
+			FailedAt:          deadLetter.FailedAt.Unix(),
+		})
+	}
+
+	return &adminProto.ListDeadLettersResponse{
+		Entries: entries,
+	}, nil
+}
+
+func (s *AdminServer) ReplayDeadLetter(
+	ctx context.Context,
+	in *adminProto.ReplayDeadLetterRequest,
+) (*adminProto.ReplayDeadLetterResponse, error) {
+	if err := s.authorizer.RequireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if in.Topic == "" {
+		return nil, status.Error(codes.InvalidArgument, "не указан топик")
+	}
+
+	err := s.dlq.Replay(ctx, in.Topic, in.Partition, in.Offset)
+	if errors.Is(err, kafka.ErrDeadLetterNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &adminProto.ReplayDeadLetterResponse{
+		Success: true,
+	}, nil
+}
+
+// ReplayTopic replays partitions of a consumed topic from a timestamp (unix seconds) or an offset.
+// With dryRun nothing is changed, the response reports what the replay would do. With reprocess,
+// events that were applied before are applied again instead of being skipped as duplicates.
+func (s *AdminServer) ReplayTopic(
+	ctx context.Context,
+	in *adminProto.ReplayTopicRequest,
+) (*adminProto.ReplayTopicResponse, error) {
+	if err := s.authorizer.RequireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if in.Topic == "" {
+		return nil, status.Error(codes.InvalidArgument, "не указан топик")
+	}
+	if in.FromTimestamp < 0 || in.FromOffset < 0 {
+		return nil, status.Error(codes.InvalidArgument, "начало повтора не может быть отрицательным")
+	}
+	if in.FromTimestamp > 0 && in.FromOffset > 0 {
+		return nil, status.Error(codes.InvalidArgument, "укажите либо время, либо смещение начала повтора")
+	}
+
+	req := kafka.ReplayRequest{
+		Topic:      in.Topic,
+		Partitions: in.Partitions,
+		FromOffset: in.FromOffset,
+		DryRun:     in.DryRun,
+		Reprocess:  in.Reprocess,
+	}
+	if in.FromTimestamp > 0 {
+		req.From = time.Unix(in.FromTimestamp, 0)
+	}
+
+	report, err := s.replayer.Replay(ctx, req)
+	switch {
+	case errors.Is(err, kafka.ErrReplayUnknownTopic),
+		errors.Is(err, kafka.ErrReplayUnknownPartition),
+		errors.Is(err, kafka.ErrReplayDryRunUnsupported),
+		errors.Is(err, kafka.ErrReplayReprocessUnsupported):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, kafka.ErrReplayInProgress):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	partitions := make([]*adminProto.ReplayedPartition, 0, len(report.Partitions))
+	for _, partition := range report.Partitions {
+		partitions = append(partitions, &adminProto.ReplayedPartition{
+			Partition:  partition.Partition,
+			FromOffset: partition.FromOffset,
+			ToOffset:   partition.ToOffset,
+			Messages:   int64(partition.Messages),
+		})
+	}
+
+	outcomes := make(map[string]int64, len(report.Outcomes))
+	for outcome, count := range report.Outcomes {
+		outcomes[outcome] = int64(count)
+	}
+
+	messages := make([]*adminProto.ReplayedMessage, 0, len(report.Messages))
+	for _, message := range report.Messages {
+		messages = append(messages, &adminProto.ReplayedMessage{
+			Partition: message.Partition,
+			Offset:    message.Offset,
+			Key:       message.Key,
+			Outcome:   message.Outcome,
+			Change:    message.Change,
+		})
+	}
+
+	return &adminProto.ReplayTopicResponse{
+		DryRun:     report.DryRun,
+		Reprocess:  report.Reprocess,
+		Partitions: partitions,
+		Outcomes:   outcomes,
+		Messages:   messages,
+		Truncated:  report.Truncated,
+	}, nil
+}
+
+// ListStuckProjects lists the projects that have been in a status longer than its SLA, grouped by owner.
+func (s *AdminServer) ListStuckProjects(
+	ctx context.Context,
+	in *adminProto.ListStuckProjectsRequest,
+) (*adminProto.ListStuckProjectsResponse, error) {
+	if err := s.authorizer.RequireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	limit := int64(100)
+	if in.Limit > 0 {
+		limit = in.Limit
+	}
+
+	stuck, err := s.reconciler.StuckProjects(ctx, in.Owner, limit)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	var owners []*adminProto.OwnerStuckProjects
+	for _, project := range stuck {
+		if len(owners) == 0 || owners[len(owners)-1].Owner != project.Project.Owner {
+			owners = append(owners, &adminProto.OwnerStuckProjects{Owner: project.Project.Owner})
+		}
+		owner := owners[len(owners)-1]
+		owner.Projects = append(owner.Projects, &adminProto.StuckProject{
+			Name:            project.Project.Name,
+			Status:          project.Project.Status,
+			StatusUpdatedAt: project.Project.StatusUpdatedAt.Time().Unix(),
+			StuckForSeconds: int64(project.StuckFor.Seconds()),
+			SlaSeconds:      int64(project.SLA.Seconds()),
+		})
+	}
+
+	return &adminProto.ListStuckProjectsResponse{
+		Owners: owners,
+	}, nil
+}
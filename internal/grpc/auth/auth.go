@@ -0,0 +1,120 @@
+package grpcauth
+
+import (
+	"context"
+	"errors"
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"project-service/internal/lib/jwt"
+	"strings"
+)
+
+// Principal is the authenticated caller of an RPC.
+type Principal struct {
+	Subject string
+	Roles   []string
+	Claims  map[string]interface{}
+}
+
+func (p *Principal) HasRole(role string) bool {
+	for _, candidate := range p.Roles {
+		if candidate == role {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal Authenticate stored in ctx.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
+
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (*jwt.Claims, error)
+}
+
+// Authenticator authenticates RPCs by the bearer token in their authorization metadata.
+type Authenticator struct {
+	log        *slog.Logger
+	verifier   TokenVerifier
+	rolesClaim string
+}
+
+// NewAuthenticator reads the roles of a principal from rolesClaim, a dotted path such as
+// realm_access.roles for nested claims. The claim holds an array or a space separated string.
+func NewAuthenticator(log *slog.Logger, verifier TokenVerifier, rolesClaim string) *Authenticator {
+	return &Authenticator{
+		log:        log,
+		verifier:   verifier,
+		rolesClaim: rolesClaim,
+	}
+}
+
+// Authenticate is the auth.AuthFunc of the unary and stream interceptors. It stores the principal
+// of a valid token in the context and fails with Unauthenticated otherwise.
+func (a *Authenticator) Authenticate(ctx context.Context) (context.Context, error) {
+	token, err := auth.AuthFromMD(ctx, "bearer")
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := a.verifier.Verify(ctx, token)
+	if errors.Is(err, jwt.ErrInvalidToken) || errors.Is(err, jwt.ErrExpired) {
+		a.log.Warn("rejected token", "error", err)
+		return nil, status.Error(codes.Unauthenticated, "недействительный токен")
+	}
+	if err != nil {
+		a.log.Error("failed to verify token", "error", err)
+		return nil, status.Error(codes.Unavailable, "не удалось проверить токен")
+	}
+	if claims.Subject == "" {
+		return nil, status.Error(codes.Unauthenticated, "в токене не указан пользователь")
+	}
+
+	return WithPrincipal(ctx, &Principal{
+		Subject: claims.Subject,
+		Roles:   stringsClaim(claims.Raw, a.rolesClaim),
+		Claims:  claims.Raw,
+	}), nil
+}
+
+// stringsClaim returns the strings of the claim at path, nil when it is missing or of another type.
+func stringsClaim(claims map[string]interface{}, path string) []string {
+	if path == "" {
+		return nil
+	}
+
+	var value interface{} = claims
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
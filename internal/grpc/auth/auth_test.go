@@ -0,0 +1,128 @@
+package grpcauth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"project-service/internal/lib/jwt"
+	"testing"
+	"time"
+)
+
+// tokenVerifier returns the claims or the error of a token.
+type tokenVerifier map[string]interface{}
+
+func (v tokenVerifier) Verify(_ context.Context, token string) (*jwt.Claims, error) {
+	switch result := v[token].(type) {
+	case *jwt.Claims:
+		return result, nil
+	case error:
+		return nil, result
+	default:
+		return nil, fmt.Errorf("%w: unknown token", jwt.ErrInvalidToken)
+	}
+}
+
+func withToken(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func newTestAuthenticator(verifier TokenVerifier) *Authenticator {
+	return NewAuthenticator(slog.New(slog.NewTextHandler(io.Discard, nil)), verifier, "realm_access.roles")
+}
+
+func TestAuthenticate(t *testing.T) {
+	authenticator := newTestAuthenticator(tokenVerifier{
+		"alice": &jwt.Claims{Subject: "alice", Raw: map[string]interface{}{
+			"realm_access": map[string]interface{}{"roles": []interface{}{"admin", 1}},
+		}},
+		"anonymous": &jwt.Claims{Raw: map[string]interface{}{}},
+		"expired":   fmt.Errorf("%w at noon", jwt.ErrExpired),
+		"keys down": errors.New("load keys: connection refused"),
+	})
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		code codes.Code
+	}{
+		{name: "valid", ctx: withToken("alice"), code: codes.OK},
+		{name: "no token", ctx: context.Background(), code: codes.Unauthenticated},
+		{name: "other scheme", ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Basic YWxpY2U6")), code: codes.Unauthenticated},
+		{name: "invalid token", ctx: withToken("forged"), code: codes.Unauthenticated},
+		{name: "expired token", ctx: withToken("expired"), code: codes.Unauthenticated},
+		{name: "token without subject", ctx: withToken("anonymous"), code: codes.Unauthenticated},
+		{name: "keys unavailable", ctx: withToken("keys down"), code: codes.Unavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, err := authenticator.Authenticate(tt.ctx)
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("got code %s (%v), want %s", code, err, tt.code)
+			}
+			if err != nil {
+				return
+			}
+
+			principal, ok := PrincipalFromContext(ctx)
+			if !ok || principal.Subject != "alice" || !principal.HasRole("admin") || len(principal.Roles) != 1 {
+				t.Fatalf("got principal %+v", principal)
+			}
+		})
+	}
+}
+
+func TestAuthenticateFailsWithUnavailableWhenTheJWKSIsUnreachable(t *testing.T) {
+	server := httptest.NewServer(nil)
+	server.Close()
+
+	verifier, err := jwt.NewVerifier(jwt.Options{
+		Keys:     jwt.NewRemoteJWKS(server.URL, time.Hour, time.Second),
+		Issuer:   "https://auth.example.com",
+		Audience: "project-service",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := func(segment string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(segment))
+	}
+	token := encode(`{"alg":"RS256","kid":"key"}`) + "." + encode(`{"sub":"alice"}`) + "." + encode("signature")
+
+	_, err = newTestAuthenticator(verifier).Authenticate(withToken(token))
+	if code := status.Code(err); code != codes.Unavailable {
+		t.Fatalf("got code %s (%v), want %s", code, err, codes.Unavailable)
+	}
+}
+
+func TestStringsClaim(t *testing.T) {
+	claims := map[string]interface{}{
+		"scope":        "read write",
+		"roles":        []interface{}{"admin", "user"},
+		"realm_access": map[string]interface{}{"roles": []interface{}{"admin"}},
+		"number":       1,
+	}
+
+	tests := map[string][]string{
+		"scope":              {"read", "write"},
+		"roles":              {"admin", "user"},
+		"realm_access.roles": {"admin"},
+		"number":             nil,
+		"missing.roles":      nil,
+		"scope.roles":        nil,
+		"":                   nil,
+	}
+	for path, want := range tests {
+		if got := stringsClaim(claims, path); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%q: got %v, want %v", path, got, want)
+		}
+	}
+}
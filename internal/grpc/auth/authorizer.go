@@ -0,0 +1,76 @@
+package grpcauth
+
+import (
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Authorizer decides which owners the principal of an RPC may act on. The subject of a principal is
+// the owner of its projects; principals with the admin role may act on every owner.
+type Authorizer struct {
+	adminRole string
+}
+
+func NewAuthorizer(adminRole string) *Authorizer {
+	return &Authorizer{adminRole: adminRole}
+}
+
+// Owner returns the owner an RPC on a single owner's projects acts on: owner itself, or the
+// principal's own when it is empty. Other owners are only allowed for admins.
+func (a *Authorizer) Owner(ctx context.Context, owner string) (string, error) {
+	principal, err := principal(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if owner == "" || owner == principal.Subject {
+		return principal.Subject, nil
+	}
+	if !a.isAdmin(principal) {
+		return "", status.Error(codes.PermissionDenied, "нет доступа к проектам другого владельца")
+	}
+
+	return owner, nil
+}
+
+// ListOwner is Owner for RPCs that cover all owners when owner is empty. An admin gets an empty
+// owner back, anyone else is limited to their own projects.
+func (a *Authorizer) ListOwner(ctx context.Context, owner string) (string, error) {
+	principal, err := principal(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if owner == "" && a.isAdmin(principal) {
+		return "", nil
+	}
+
+	return a.Owner(ctx, owner)
+}
+
+// RequireAdmin fails with PermissionDenied unless the principal has the admin role.
+func (a *Authorizer) RequireAdmin(ctx context.Context) error {
+	principal, err := principal(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !a.isAdmin(principal) {
+		return status.Error(codes.PermissionDenied, "требуется роль администратора")
+	}
+
+	return nil
+}
+
+func (a *Authorizer) isAdmin(principal *Principal) bool {
+	return a.adminRole != "" && principal.HasRole(a.adminRole)
+}
+
+func principal(ctx context.Context) (*Principal, error) {
+	principal, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "пользователь не аутентифицирован")
+	}
+	return principal, nil
+}
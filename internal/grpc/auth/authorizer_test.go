@@ -0,0 +1,60 @@
+package grpcauth
+
+import (
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestAuthorizer(t *testing.T) {
+	authorizer := NewAuthorizer("admin")
+	alice := WithPrincipal(context.Background(), &Principal{Subject: "alice", Roles: []string{"user"}})
+	admin := WithPrincipal(context.Background(), &Principal{Subject: "root", Roles: []string{"admin"}})
+	anonymous := context.Background()
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		owner     string
+		wantOwner string
+		wantList  string
+		code      codes.Code
+		listCode  codes.Code
+		adminCode codes.Code
+	}{
+		{name: "own projects", ctx: alice, owner: "alice", wantOwner: "alice", wantList: "alice", adminCode: codes.PermissionDenied},
+		{name: "owner defaults to the caller", ctx: alice, wantOwner: "alice", wantList: "alice", adminCode: codes.PermissionDenied},
+		{name: "another owner's projects", ctx: alice, owner: "bob", code: codes.PermissionDenied, listCode: codes.PermissionDenied, adminCode: codes.PermissionDenied},
+		{name: "admin on another owner", ctx: admin, owner: "bob", wantOwner: "bob", wantList: "bob"},
+		{name: "admin on all owners", ctx: admin, wantOwner: "root", wantList: ""},
+		{name: "no principal", ctx: anonymous, owner: "alice", code: codes.Unauthenticated, listCode: codes.Unauthenticated, adminCode: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner, err := authorizer.Owner(tt.ctx, tt.owner)
+			if code := status.Code(err); code != tt.code || owner != tt.wantOwner {
+				t.Fatalf("Owner: got %q and code %s, want %q and %s", owner, code, tt.wantOwner, tt.code)
+			}
+
+			owner, err = authorizer.ListOwner(tt.ctx, tt.owner)
+			if code := status.Code(err); code != tt.listCode || owner != tt.wantList {
+				t.Fatalf("ListOwner: got %q and code %s, want %q and %s", owner, code, tt.wantList, tt.listCode)
+			}
+
+			if code := status.Code(authorizer.RequireAdmin(tt.ctx)); code != tt.adminCode {
+				t.Fatalf("RequireAdmin: got code %s, want %s", code, tt.adminCode)
+			}
+		})
+	}
+}
+
+func TestAuthorizerWithoutAdminRole(t *testing.T) {
+	authorizer := NewAuthorizer("")
+	ctx := WithPrincipal(context.Background(), &Principal{Subject: "alice", Roles: []string{""}})
+
+	if _, err := authorizer.Owner(ctx, "bob"); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("got error %v, want PermissionDenied without an admin role", err)
+	}
+}
@@ -2,13 +2,19 @@ package projectserver
 
 import (
 	"context"
+	"errors"
 	projectProto "github.com/SmartAPIForge/protos/gen/go/project"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"project-service/internal/domain/models"
+	grpcauth "project-service/internal/grpc/auth"
+	"project-service/internal/lib/urlsigner"
 	projectservice "project-service/internal/services/project"
+	reconcilerservice "project-service/internal/services/reconciler"
+	"sort"
 	"strconv"
+	"time"
 )
 
 type ProjectService interface {
@@ -38,22 +44,65 @@ type ProjectService interface {
 		owner string,
 		name string,
 	) error
+	GenerateProject(
+		ctx context.Context,
+		owner string,
+		name string,
+	) (*models.Project, string, error)
+	DeployProject(
+		ctx context.Context,
+		owner string,
+		name string,
+		environment string,
+	) (*models.Project, string, error)
+	UndeployProject(
+		ctx context.Context,
+		owner string,
+		name string,
+		environment string,
+	) (*models.Project, string, error)
+	ListArtifacts(
+		ctx context.Context,
+		owner string,
+		name string,
+	) ([]models.ZipArtifact, error)
+	GetLatestArtifact(
+		ctx context.Context,
+		owner string,
+		name string,
+	) (*models.ZipArtifact, error)
+	GetDownloadUrl(
+		ctx context.Context,
+		owner string,
+		name string,
+		revision int64,
+	) (string, time.Time, error)
+}
+
+type StuckProjectsReporter interface {
+	StuckProjects(ctx context.Context, owner string, limit int64) ([]reconcilerservice.StuckProject, error)
 }
 
+// ProjectServer lets callers work on their own projects; the owner of a request defaults to the caller.
+// Other owners' projects, and GetFilteredProjects across all owners, are reserved to admins.
 type ProjectServer struct {
 	projectProto.UnsafeProjectServiceServer
 	projectService ProjectService
 	projectUpdater *projectservice.ProjectUpdater
+	reconciler     StuckProjectsReporter
+	authorizer     *grpcauth.Authorizer
 }
 
 func RegisterProjectServer(
 	gRPCServer *grpc.Server,
 	project ProjectService,
 	projectUpdater *projectservice.ProjectUpdater,
+	reconciler StuckProjectsReporter,
+	authorizer *grpcauth.Authorizer,
 ) {
 	projectProto.RegisterProjectServiceServer(
 		gRPCServer,
-		&ProjectServer{projectService: project, projectUpdater: projectUpdater},
+		&ProjectServer{projectService: project, projectUpdater: projectUpdater, reconciler: reconciler, authorizer: authorizer},
 	)
 }
 
@@ -61,8 +110,9 @@ func (s *ProjectServer) GetAllUserProjects(
 	ctx context.Context,
 	in *projectProto.GetAllUserProjectsRequest,
 ) (*projectProto.ListOfProjectsResponse, error) {
-	if in.Owner == "" {
-		return nil, status.Error(codes.InvalidArgument, "не указан владелец проектов")
+	owner, err := s.authorizer.Owner(ctx, in.Owner)
+	if err != nil {
+		return nil, err
 	}
 
 	page := int64(1)
@@ -82,7 +132,7 @@ func (s *ProjectServer) GetAllUserProjects(
 		}
 	}
 
-	projects, err := s.projectService.GetAllUserProjects(ctx, in.Owner, page, limit)
+	projects, err := s.projectService.GetAllUserProjects(ctx, owner, page, limit)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
@@ -105,6 +155,11 @@ func (s *ProjectServer) GetFilteredProjects(
 	ctx context.Context,
 	in *projectProto.GetFilteredProjectsRequest,
 ) (*projectProto.ListOfProjectsResponse, error) {
+	owner, err := s.authorizer.ListOwner(ctx, in.Owner)
+	if err != nil {
+		return nil, err
+	}
+
 	page := int64(1)
 	limit := int64(10)
 
@@ -124,7 +179,7 @@ func (s *ProjectServer) GetFilteredProjects(
 
 	projects, err := s.projectService.GetFilteredProjects(
 		ctx,
-		in.Owner,
+		owner,
 		in.Status,
 		in.NamePrefix,
 		page,
@@ -152,16 +207,22 @@ func (s *ProjectServer) StreamUserProjectsUpdates(
 	req *projectProto.Owner,
 	stream projectProto.ProjectService_StreamUserProjectsUpdatesServer,
 ) error {
-	updates := s.projectUpdater.Subscribe()
+	owner, err := s.authorizer.ListOwner(stream.Context(), req.Owner)
+	if err != nil {
+		return err
+	}
+
+	updates, unsubscribe := s.projectUpdater.Subscribe()
+	defer unsubscribe()
 
 	for {
 		select {
 		case project, ok := <-updates:
 			if !ok {
-				return status.Error(codes.Internal, "project updates channel closed")
+				return status.Error(codes.Unavailable, "сервер останавливается")
 			}
 
-			if project.Owner != req.Owner {
+			if owner != "" && project.Owner != owner {
 				continue
 			}
 
@@ -180,13 +241,18 @@ func (s *ProjectServer) InitProject(
 	ctx context.Context,
 	in *projectProto.InitProjectRequest,
 ) (*projectProto.ProjectResponse, error) {
-	if in.ComposeId == nil {
+	if in.ComposeId == nil || in.ComposeId.Name == "" {
 		return nil, status.Error(codes.InvalidArgument, "не указан идентификатор проекта")
 	}
 
-	project, err := s.projectService.InitProject(ctx, in.ComposeId.Owner, in.ComposeId.Name)
+	owner, err := s.authorizer.Owner(ctx, in.ComposeId.Owner)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, err
+	}
+
+	project, err := s.projectService.InitProject(ctx, owner, in.ComposeId.Name)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
 	}
 
 	return projectToResponse(project)
@@ -196,13 +262,18 @@ func (s *ProjectServer) UpdateProject(
 	ctx context.Context,
 	in *projectProto.UpdateProjectRequest,
 ) (*projectProto.ProjectResponse, error) {
-	if in.ComposeId == nil {
+	if in.ComposeId == nil || in.ComposeId.Name == "" {
 		return nil, status.Error(codes.InvalidArgument, "не указан идентификатор проекта")
 	}
 
-	project, err := s.projectService.UpdateProject(ctx, in.ComposeId.Owner, in.ComposeId.Name, in.Data)
+	owner, err := s.authorizer.Owner(ctx, in.ComposeId.Owner)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, err
+	}
+
+	project, err := s.projectService.UpdateProject(ctx, owner, in.ComposeId.Name, in.Data)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
 	}
 
 	return projectToResponse(project)
@@ -212,13 +283,17 @@ func (s *ProjectServer) DeleteProject(
 	ctx context.Context,
 	in *projectProto.ProjectUniqueIdentifier,
 ) (*projectProto.DeleteProjectResponse, error) {
-	if in.Owner == "" || in.Name == "" {
-		return nil, status.Error(codes.InvalidArgument, "не указаны владелец или имя проекта")
+	if in.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "не указано имя проекта")
 	}
 
-	err := s.projectService.DeleteProject(ctx, in.Owner, in.Name)
+	owner, err := s.authorizer.Owner(ctx, in.Owner)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, err
+	}
+
+	if err := s.projectService.DeleteProject(ctx, owner, in.Name); err != nil {
+		return nil, serviceErrorToStatus(err)
 	}
 
 	return &projectProto.DeleteProjectResponse{
@@ -226,6 +301,215 @@ func (s *ProjectServer) DeleteProject(
 	}, nil
 }
 
+func (s *ProjectServer) GenerateProject(
+	ctx context.Context,
+	in *projectProto.ProjectUniqueIdentifier,
+) (*projectProto.GenerateProjectResponse, error) {
+	if in.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "не указано имя проекта")
+	}
+
+	owner, err := s.authorizer.Owner(ctx, in.Owner)
+	if err != nil {
+		return nil, err
+	}
+
+	project, jobId, err := s.projectService.GenerateProject(ctx, owner, in.Name)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+
+	projectResponse, err := projectToResponse(project)
+	if err != nil {
+		return nil, err
+	}
+
+	return &projectProto.GenerateProjectResponse{
+		JobId:   jobId,
+		Project: projectResponse,
+	}, nil
+}
+
+func (s *ProjectServer) DeployProject(
+	ctx context.Context,
+	in *projectProto.DeployProjectRequest,
+) (*projectProto.DeployCommandResponse, error) {
+	if in.ComposeId == nil || in.ComposeId.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "не указан идентификатор проекта")
+	}
+
+	owner, err := s.authorizer.Owner(ctx, in.ComposeId.Owner)
+	if err != nil {
+		return nil, err
+	}
+
+	project, commandId, err := s.projectService.DeployProject(ctx, owner, in.ComposeId.Name, in.Environment)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+
+	return deployCommandToResponse(project, commandId)
+}
+
+func (s *ProjectServer) UndeployProject(
+	ctx context.Context,
+	in *projectProto.DeployProjectRequest,
+) (*projectProto.DeployCommandResponse, error) {
+	if in.ComposeId == nil || in.ComposeId.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "не указан идентификатор проекта")
+	}
+
+	owner, err := s.authorizer.Owner(ctx, in.ComposeId.Owner)
+	if err != nil {
+		return nil, err
+	}
+
+	project, commandId, err := s.projectService.UndeployProject(ctx, owner, in.ComposeId.Name, in.Environment)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+
+	return deployCommandToResponse(project, commandId)
+}
+
+func (s *ProjectServer) ListArtifacts(
+	ctx context.Context,
+	in *projectProto.ProjectUniqueIdentifier,
+) (*projectProto.ListArtifactsResponse, error) {
+	if in.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "не указано имя проекта")
+	}
+
+	owner, err := s.authorizer.Owner(ctx, in.Owner)
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts, err := s.projectService.ListArtifacts(ctx, owner, in.Name)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+
+	protoArtifacts := make([]*projectProto.Artifact, 0, len(artifacts))
+	for i := range artifacts {
+		protoArtifacts = append(protoArtifacts, artifactToResponse(&artifacts[i]))
+	}
+
+	return &projectProto.ListArtifactsResponse{
+		Artifacts: protoArtifacts,
+	}, nil
+}
+
+func (s *ProjectServer) GetLatestArtifact(
+	ctx context.Context,
+	in *projectProto.ProjectUniqueIdentifier,
+) (*projectProto.Artifact, error) {
+	if in.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "не указано имя проекта")
+	}
+
+	owner, err := s.authorizer.Owner(ctx, in.Owner)
+	if err != nil {
+		return nil, err
+	}
+
+	artifact, err := s.projectService.GetLatestArtifact(ctx, owner, in.Name)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+
+	return artifactToResponse(artifact), nil
+}
+
+func (s *ProjectServer) GetDownloadUrl(
+	ctx context.Context,
+	in *projectProto.GetDownloadUrlRequest,
+) (*projectProto.DownloadUrlResponse, error) {
+	if in.ComposeId == nil || in.ComposeId.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "не указан идентификатор проекта")
+	}
+
+	owner, err := s.authorizer.Owner(ctx, in.ComposeId.Owner)
+	if err != nil {
+		return nil, err
+	}
+
+	url, expiresAt, err := s.projectService.GetDownloadUrl(ctx, owner, in.ComposeId.Name, in.Revision)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+
+	return &projectProto.DownloadUrlResponse{
+		Url:       url,
+		ExpiresAt: expiresAt.Unix(),
+	}, nil
+}
+
+// ListStuckProjects lists the projects of the owner that have been in a status longer than its SLA,
+// longest stuck first. AdminService.ListStuckProjects lists them across owners.
+func (s *ProjectServer) ListStuckProjects(
+	ctx context.Context,
+	in *projectProto.ListStuckProjectsRequest,
+) (*projectProto.ListStuckProjectsResponse, error) {
+	owner, err := s.authorizer.Owner(ctx, in.Owner)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := int64(100)
+	if in.Limit > 0 {
+		limit = in.Limit
+	}
+
+	stuck, err := s.reconciler.StuckProjects(ctx, owner, limit)
+	if err != nil {
+		return nil, serviceErrorToStatus(err)
+	}
+
+	projects := make([]*projectProto.StuckProject, 0, len(stuck))
+	for _, project := range stuck {
+		projects = append(projects, &projectProto.StuckProject{
+			Name:            project.Project.Name,
+			Status:          project.Project.Status,
+			StatusUpdatedAt: project.Project.StatusUpdatedAt.Time().Unix(),
+			StuckForSeconds: int64(project.StuckFor.Seconds()),
+			SlaSeconds:      int64(project.SLA.Seconds()),
+		})
+	}
+
+	return &projectProto.ListStuckProjectsResponse{
+		Projects: projects,
+	}, nil
+}
+
+func deployCommandToResponse(project *models.Project, commandId string) (*projectProto.DeployCommandResponse, error) {
+	projectResponse, err := projectToResponse(project)
+	if err != nil {
+		return nil, err
+	}
+
+	return &projectProto.DeployCommandResponse{
+		CommandId: commandId,
+		Project:   projectResponse,
+	}, nil
+}
+
+func serviceErrorToStatus(err error) error {
+	switch {
+	case errors.Is(err, models.ErrProjectNotFound),
+		errors.Is(err, models.ErrEnvironmentNotFound),
+		errors.Is(err, models.ErrArtifactNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, models.ErrInvalidProjectDefinition), errors.Is(err, models.ErrInvalidEnvironment):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, models.ErrInvalidStatusTransition), errors.Is(err, models.ErrProjectNotGenerated),
+		errors.Is(err, urlsigner.ErrDisabled):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
 func projectToResponse(project *models.Project) (*projectProto.ProjectResponse, error) {
 	if project == nil {
 		return nil, status.Error(codes.NotFound, "проект не найден")
@@ -236,13 +520,47 @@ func projectToResponse(project *models.Project) (*projectProto.ProjectResponse,
 			Owner: project.Owner,
 			Name:  project.Name,
 		},
+		// UrlZip is left empty on purpose: zips are downloaded through GetDownloadUrl
 		Info: &projectProto.ProjectInfo{
-			Data:      project.Data,
-			Status:    project.Status,
-			UrlZip:    project.UrlZip,
-			UrlDeploy: project.UrlDeploy,
-			CreatedAt: project.CreatedAt.Time().Unix(),
-			UpdatedAt: project.UpdatedAt.Time().Unix(),
+			Data:         project.Data,
+			Status:       project.Status,
+			UrlDeploy:    project.Environments[models.DefaultEnvironment].UrlDeploy,
+			Environments: environmentsToResponse(project.Environments),
+			CreatedAt:    project.CreatedAt.Time().Unix(),
+			UpdatedAt:    project.UpdatedAt.Time().Unix(),
 		},
 	}, nil
 }
+
+func environmentsToResponse(environments map[string]models.DeployEnvironment) []*projectProto.DeployEnvironment {
+	names := make([]string, 0, len(environments))
+	for name := range environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	response := make([]*projectProto.DeployEnvironment, 0, len(names))
+	for _, name := range names {
+		env := environments[name]
+		response = append(response, &projectProto.DeployEnvironment{
+			Name:      name,
+			UrlDeploy: env.UrlDeploy,
+			Status:    env.Status,
+			Revision:  env.Revision,
+			UpdatedAt: env.UpdatedAt.Time().Unix(),
+		})
+	}
+
+	return response
+}
+
+// artifactToResponse leaves out the storage location, downloads go through GetDownloadUrl.
+func artifactToResponse(artifact *models.ZipArtifact) *projectProto.Artifact {
+	return &projectProto.Artifact{
+		Checksum:         artifact.Checksum,
+		Size:             artifact.Size,
+		GeneratorVersion: artifact.GeneratorVersion,
+		Revision:         artifact.Revision,
+		CreatedAt:        artifact.CreatedAt.Time().Unix(),
+	}
+}
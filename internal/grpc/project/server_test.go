@@ -0,0 +1,273 @@
+package projectserver
+
+import (
+	"context"
+	"errors"
+	projectProto "github.com/SmartAPIForge/protos/gen/go/project"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"project-service/internal/domain/models"
+	grpcauth "project-service/internal/grpc/auth"
+	projectservice "project-service/internal/services/project"
+	reconcilerservice "project-service/internal/services/reconciler"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryProjects is a ProjectService over the projects of a test; other methods are not implemented.
+type memoryProjects struct {
+	ProjectService
+	projects map[string]*models.Project
+	err      error
+}
+
+func (m *memoryProjects) project(owner, name string) (*models.Project, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	project, ok := m.projects[owner+"/"+name]
+	if !ok {
+		return nil, models.ErrProjectNotFound
+	}
+	return project, nil
+}
+
+func (m *memoryProjects) InitProject(_ context.Context, owner, name string) (*models.Project, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &models.Project{Owner: owner, Name: name}, nil
+}
+
+func (m *memoryProjects) UpdateProject(_ context.Context, owner, name, data string) (*models.Project, error) {
+	return m.project(owner, name)
+}
+
+func (m *memoryProjects) DeleteProject(_ context.Context, owner, name string) error {
+	_, err := m.project(owner, name)
+	return err
+}
+
+func newTestServer(service ProjectService) *ProjectServer {
+	return &ProjectServer{
+		projectService: service,
+		projectUpdater: projectservice.NewProjectUpdater(),
+		authorizer:     grpcauth.NewAuthorizer("admin"),
+	}
+}
+
+func as(subject string, roles ...string) context.Context {
+	return grpcauth.WithPrincipal(context.Background(), &grpcauth.Principal{Subject: subject, Roles: roles})
+}
+
+func TestProjectRPCsCheckTheOwner(t *testing.T) {
+	server := newTestServer(&memoryProjects{projects: map[string]*models.Project{
+		"alice/api": {Owner: "alice", Name: "api"},
+		"bob/api":   {Owner: "bob", Name: "api"},
+	}})
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		owner string
+		code  codes.Code
+	}{
+		{name: "own project", ctx: as("alice"), owner: "alice", code: codes.OK},
+		{name: "owner defaults to the caller", ctx: as("alice"), code: codes.OK},
+		{name: "another owner's project", ctx: as("alice"), owner: "bob", code: codes.PermissionDenied},
+		{name: "admin on another owner's project", ctx: as("root", "admin"), owner: "bob", code: codes.OK},
+		{name: "unauthenticated", ctx: context.Background(), owner: "alice", code: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := &projectProto.ProjectUniqueIdentifier{Owner: tt.owner, Name: "api"}
+
+			_, err := server.UpdateProject(tt.ctx, &projectProto.UpdateProjectRequest{ComposeId: id, Data: "{}"})
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("UpdateProject: got code %s (%v), want %s", code, err, tt.code)
+			}
+			_, err = server.DeleteProject(tt.ctx, id)
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("DeleteProject: got code %s (%v), want %s", code, err, tt.code)
+			}
+			_, err = server.InitProject(tt.ctx, &projectProto.InitProjectRequest{ComposeId: id})
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("InitProject: got code %s (%v), want %s", code, err, tt.code)
+			}
+		})
+	}
+}
+
+func TestProjectRPCsMapServiceErrors(t *testing.T) {
+	tests := []struct {
+		err  error
+		code codes.Code
+	}{
+		{err: models.ErrProjectNotFound, code: codes.NotFound},
+		{err: models.ErrInvalidProjectDefinition, code: codes.InvalidArgument},
+		{err: models.ErrInvalidStatusTransition, code: codes.FailedPrecondition},
+		{err: errors.New("mongo is down"), code: codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			server := newTestServer(&memoryProjects{err: tt.err})
+			id := &projectProto.ProjectUniqueIdentifier{Name: "api"}
+
+			_, err := server.UpdateProject(as("alice"), &projectProto.UpdateProjectRequest{ComposeId: id})
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("UpdateProject: got code %s, want %s", code, tt.code)
+			}
+			_, err = server.DeleteProject(as("alice"), id)
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("DeleteProject: got code %s, want %s", code, tt.code)
+			}
+			_, err = server.InitProject(as("alice"), &projectProto.InitProjectRequest{ComposeId: id})
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("InitProject: got code %s, want %s", code, tt.code)
+			}
+		})
+	}
+}
+
+// updatesStream records the projects sent on a StreamUserProjectsUpdates call.
+type updatesStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	mu   sync.Mutex
+	sent []*projectProto.ProjectResponse
+}
+
+func (s *updatesStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *updatesStream) Send(project *projectProto.ProjectResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent = append(s.sent, project)
+	return nil
+}
+
+func (s *updatesStream) owners() map[string]bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	owners := make(map[string]bool)
+	for _, project := range s.sent {
+		owners[project.ComposeId.Owner] = true
+	}
+	return owners
+}
+
+// streamUpdates streams the updates of owner to ctx until updates of all owners in want have been sent
+// or a second passed, and returns the owners of the sent projects.
+func streamUpdates(t *testing.T, ctx context.Context, owner string, want ...string) map[string]bool {
+	t.Helper()
+
+	server := newTestServer(&memoryProjects{})
+	ctx, cancel := context.WithCancel(ctx)
+	stream := &updatesStream{ctx: ctx}
+	done := make(chan error, 1)
+	go func() {
+		done <- server.StreamUserProjectsUpdates(&projectProto.Owner{Owner: owner}, stream)
+	}()
+
+	// updates published before the stream subscribed are lost, so they are published until received
+	deadline := time.Now().Add(time.Second)
+	for {
+		for _, publisher := range []string{"bob", "alice"} {
+			server.projectUpdater.Publish(&models.Project{Owner: publisher, Name: "api"})
+		}
+		owners := stream.owners()
+		received := true
+		for _, owner := range want {
+			received = received && owners[owner]
+		}
+		if received || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; status.Code(err) == codes.PermissionDenied || status.Code(err) == codes.Unauthenticated {
+		return nil
+	}
+	return stream.owners()
+}
+
+func TestStreamUserProjectsUpdatesOnlySendsTheCallersProjects(t *testing.T) {
+	if owners := streamUpdates(t, as("alice"), "", "alice"); len(owners) != 1 || !owners["alice"] {
+		t.Fatalf("got updates of %v, want only alice's", owners)
+	}
+	if owners := streamUpdates(t, as("alice"), "alice", "alice"); len(owners) != 1 || !owners["alice"] {
+		t.Fatalf("got updates of %v, want only alice's", owners)
+	}
+	if owners := streamUpdates(t, as("alice"), "bob"); owners != nil {
+		t.Fatalf("got updates of %v, want PermissionDenied for another owner", owners)
+	}
+	if owners := streamUpdates(t, as("root", "admin"), "", "alice", "bob"); len(owners) != 2 {
+		t.Fatalf("got updates of %v, want all owners for an admin", owners)
+	}
+	if owners := streamUpdates(t, as("root", "admin"), "bob", "bob"); len(owners) != 1 || !owners["bob"] {
+		t.Fatalf("got updates of %v, want only bob's", owners)
+	}
+}
+
+// stuckReporter reports a stuck project of every owner it is asked for and records the owner.
+type stuckReporter struct {
+	owner string
+}
+
+func (r *stuckReporter) StuckProjects(_ context.Context, owner string, _ int64) ([]reconcilerservice.StuckProject, error) {
+	r.owner = owner
+	return []reconcilerservice.StuckProject{{
+		Project:  &models.Project{Owner: owner, Name: "api", Status: models.StatusQueued},
+		StuckFor: time.Hour,
+		SLA:      30 * time.Minute,
+	}}, nil
+}
+
+func TestListStuckProjectsChecksTheOwner(t *testing.T) {
+	reporter := &stuckReporter{}
+	server := newTestServer(&memoryProjects{})
+	server.reconciler = reporter
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		owner     string
+		wantOwner string
+		code      codes.Code
+	}{
+		{name: "own projects", ctx: as("alice"), owner: "alice", wantOwner: "alice"},
+		{name: "owner defaults to the caller", ctx: as("alice"), wantOwner: "alice"},
+		{name: "another owner's projects", ctx: as("alice"), owner: "bob", code: codes.PermissionDenied},
+		{name: "admin on another owner's projects", ctx: as("root", "admin"), owner: "bob", wantOwner: "bob"},
+		{name: "admin without an owner gets their own", ctx: as("root", "admin"), wantOwner: "root"},
+		{name: "unauthenticated", ctx: context.Background(), owner: "alice", code: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := server.ListStuckProjects(tt.ctx, &projectProto.ListStuckProjectsRequest{Owner: tt.owner})
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("got code %s (%v), want %s", code, err, tt.code)
+			}
+			if err != nil {
+				return
+			}
+			if reporter.owner != tt.wantOwner {
+				t.Fatalf("got projects of %q, want %q", reporter.owner, tt.wantOwner)
+			}
+			if len(resp.Projects) != 1 || resp.Projects[0].StuckForSeconds != 3600 || resp.Projects[0].SlaSeconds != 1800 {
+				t.Fatalf("got %v", resp.Projects)
+			}
+		})
+	}
+}
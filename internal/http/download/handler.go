@@ -0,0 +1,133 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"project-service/internal/domain/models"
+	"project-service/internal/lib/urlsigner"
+	"strings"
+	"time"
+)
+
+type Verifier interface {
+	Verify(query url.Values) (string, error)
+}
+
+type ArtifactResolver interface {
+	ArtifactLocation(ctx context.Context, artifactId string) (string, error)
+}
+
+// Handler serves artifacts by signed URL. The URL only carries an artifact id that is resolved to its
+// location here. Local locations (file:// or plain paths) are served from root, remote ones are streamed
+// so that the storage URL is never exposed to the client.
+type Handler struct {
+	log      *slog.Logger
+	verifier Verifier
+	resolver ArtifactResolver
+	root     string
+	client   *http.Client
+}
+
+func NewHandler(log *slog.Logger, verifier Verifier, resolver ArtifactResolver, root string) *Handler {
+	return &Handler{
+		log:      log,
+		verifier: verifier,
+		resolver: resolver,
+		root:     root,
+		client:   &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	artifactId, err := h.verifier.Verify(r.URL.Query())
+	if err != nil {
+		status := http.StatusForbidden
+		if errors.Is(err, urlsigner.ErrExpired) {
+			status = http.StatusGone
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	location, err := h.resolver.ArtifactLocation(r.Context(), artifactId)
+	if errors.Is(err, models.ErrProjectNotFound) || errors.Is(err, models.ErrArtifactNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.log.Error("failed to resolve artifact", "artifact", artifactId, "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	locationURL, err := url.Parse(location)
+	if err != nil {
+		h.log.Error("invalid artifact location", "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	switch locationURL.Scheme {
+	case "http", "https":
+		h.proxy(w, r, location)
+	case "", "file":
+		h.serveFile(w, r, locationURL.Path)
+	default:
+		h.log.Error("unsupported artifact location scheme", "scheme", locationURL.Scheme)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) serveFile(w http.ResponseWriter, r *http.Request, path string) {
+	root, err := filepath.Abs(h.root)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	filePath := filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(path, root)))
+	if filePath != root && !strings.HasPrefix(filePath, root+string(filepath.Separator)) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", "attachment; filename="+filepath.Base(filePath))
+	http.ServeFile(w, r, filePath)
+}
+
+func (h *Handler) proxy(w http.ResponseWriter, r *http.Request, location string) {
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, location, nil)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		h.log.Error("failed to fetch artifact", "error", err)
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, header := range []string{"Content-Type", "Content-Length", "Content-Disposition", "Last-Modified", "ETag"} {
+		if value := resp.Header.Get(header); value != "" {
+			w.Header().Set(header, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		h.log.Warn("artifact download interrupted", "error", err)
+	}
+}
@@ -0,0 +1,116 @@
+package download
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"project-service/internal/domain/models"
+	"project-service/internal/lib/urlsigner"
+	"testing"
+	"time"
+)
+
+// locations resolves artifact ids to the locations of a test.
+type locations map[string]string
+
+func (l locations) ArtifactLocation(_ context.Context, artifactId string) (string, error) {
+	location, ok := l[artifactId]
+	if !ok {
+		return "", models.ErrArtifactNotFound
+	}
+	return location, nil
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "zips")
+	writeFile(t, filepath.Join(root, "alice", "api.zip"), "artifact")
+	writeFile(t, filepath.Join(dir, "secret.zip"), "secret")
+	writeFile(t, filepath.Join(dir, "zips-other", "api.zip"), "secret")
+
+	signer, err := urlsigner.New("http://localhost/downloads", time.Minute, []urlsigner.Key{{ID: "key", Secret: []byte("secret")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), signer, locations{
+		"relative":               "alice/api.zip",
+		"absolute":               filepath.Join(root, "alice", "api.zip"),
+		"file":                   "file://" + filepath.ToSlash(filepath.Join(root, "alice", "api.zip")),
+		"parent":                 "../secret.zip",
+		"nested parent":          "alice/../../secret.zip",
+		"absolute outside":       filepath.Join(dir, "secret.zip"),
+		"file outside":           "file://" + filepath.ToSlash(filepath.Join(dir, "secret.zip")),
+		"sibling with root name": filepath.Join(dir, "zips-other", "api.zip"),
+		"unsupported scheme":     "ftp://storage/api.zip",
+	}, root)
+
+	tests := []struct {
+		name     string
+		artifact string
+		tamper   bool
+		status   int
+	}{
+		{name: "relative path", artifact: "relative", status: http.StatusOK},
+		{name: "absolute path in the root", artifact: "absolute", status: http.StatusOK},
+		{name: "file url in the root", artifact: "file", status: http.StatusOK},
+		{name: "path to the parent", artifact: "parent", status: http.StatusForbidden},
+		{name: "nested path to the parent", artifact: "nested parent", status: http.StatusForbidden},
+		{name: "absolute path outside the root", artifact: "absolute outside", status: http.StatusNotFound},
+		{name: "file url outside the root", artifact: "file outside", status: http.StatusNotFound},
+		{name: "sibling directory with the root as prefix", artifact: "sibling with root name", status: http.StatusNotFound},
+		{name: "unsupported scheme", artifact: "unsupported scheme", status: http.StatusInternalServerError},
+		{name: "unknown artifact", artifact: "unknown", status: http.StatusNotFound},
+		{name: "tampered link", artifact: "relative", tamper: true, status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link, _, err := signer.Sign(tt.artifact)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.tamper {
+				link += "x"
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, link, nil))
+
+			if recorder.Code != tt.status {
+				t.Fatalf("got status %d, want %d", recorder.Code, tt.status)
+			}
+			if tt.status == http.StatusOK && recorder.Body.String() != "artifact" {
+				t.Fatalf("got body %q", recorder.Body.String())
+			}
+			if recorder.Body.String() == "secret" {
+				t.Fatal("served a file outside the root")
+			}
+		})
+	}
+}
+
+func TestServeHTTPRejectsOtherMethods(t *testing.T) {
+	handler := NewHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), nil, nil, t.TempDir())
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/downloads", nil))
+
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("got status %d, want %d", recorder.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,66 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+type Checker interface {
+	Health() error
+}
+
+// Detailer is implemented by checkers that report more than whether they are healthy.
+type Detailer interface {
+	HealthDetails() interface{}
+}
+
+// Handler reports the state of every check and responds with 503 as soon as one of them fails,
+// so it serves as the readiness probe.
+type Handler struct {
+	checks map[string]Checker
+}
+
+func NewHandler(checks map[string]Checker) *Handler {
+	return &Handler{checks: checks}
+}
+
+type response struct {
+	Status  string                 `json:"status"`
+	Checks  map[string]string      `json:"checks"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(h.checks))
+	for name := range h.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resp := response{Status: "ok", Checks: make(map[string]string, len(names))}
+	for _, name := range names {
+		if detailer, ok := h.checks[name].(Detailer); ok {
+			if resp.Details == nil {
+				resp.Details = make(map[string]interface{})
+			}
+			resp.Details[name] = detailer.HealthDetails()
+		}
+
+		if err := h.checks[name].Health(); err != nil {
+			resp.Status = "fail"
+			resp.Checks[name] = err.Error()
+			continue
+		}
+		resp.Checks[name] = "ok"
+	}
+
+	status := http.StatusOK
+	if resp.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
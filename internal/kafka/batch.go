@@ -0,0 +1,116 @@
+package kafka
+
+import (
+	"context"
+	"expvar"
+	"runtime/debug"
+	"time"
+)
+
+var (
+	consumedBatches    = expvar.NewInt("kafka_consumed_batches_total")
+	consumedBatchItems = expvar.NewInt("kafka_consumed_batch_messages_total")
+)
+
+// applyBatch passes the batchable messages of jobs to the batch handler at once and reports which
+// jobs it finished. A single message is left to its topic handler, batching it gains nothing.
+func (kc *KafkaConsumer) applyBatch(ctx context.Context, jobs []job) []bool {
+	applied := make([]bool, len(jobs))
+
+	var msgs []*Message
+	var index []int
+	for i, next := range jobs {
+		if next.native != nil && kc.offsets.begin(next.msg.TopicPartition, next.epoch) {
+			msgs = append(msgs, &Message{Message: next.msg, Native: next.native, Attempt: 1})
+			index = append(index, i)
+		}
+	}
+	defer func() {
+		for _, i := range index {
+			kc.offsets.end(jobs[i].msg.TopicPartition, jobs[i].epoch)
+		}
+	}()
+	if len(msgs) < 2 {
+		return applied
+	}
+
+	start := time.Now()
+	errs, ok := kc.handleBatch(context.WithoutCancel(ctx), msgs)
+	if !ok {
+		return applied
+	}
+	duration := time.Since(start)
+
+	failed := 0
+	for i, err := range errs {
+		if err != nil {
+			failed++
+			continue
+		}
+		applied[index[i]] = true
+	}
+	consumedBatches.Add(1)
+	consumedBatchItems.Add(int64(len(msgs)))
+
+	attrs := []any{"messages", len(msgs), "failed", failed, "duration", duration}
+	if failed > 0 {
+		kc.health.failed()
+		kc.log.Warn("Applied message batch, failed messages are handled one by one", append(attrs, "error", firstError(errs))...)
+	} else {
+		kc.log.Info("Applied message batch", attrs...)
+	}
+
+	return applied
+}
+
+// handleBatch calls the batch handler and then passes every message it finished through the middlewares,
+// so batched messages are logged, traced and counted once like the others. Messages the batch failed on
+// skip them here, their topic handler takes them through the middlewares. The batch handler gets ctx,
+// not the contexts the middlewares derive per message, and the durations the middlewares see leave out
+// the batch, which is logged with its own.
+func (kc *KafkaConsumer) handleBatch(ctx context.Context, msgs []*Message) ([]error, bool) {
+	errs, ok := kc.runBatchHandler(ctx, msgs)
+	if !ok {
+		return nil, false
+	}
+
+	acknowledge := kc.registry.wrap(func(context.Context, *Message) (Result, error) {
+		return ResultAck, nil
+	})
+	for i, msg := range msgs {
+		if errs[i] == nil {
+			acknowledge(ctx, msg)
+		}
+	}
+
+	return errs, true
+}
+
+// runBatchHandler reports false when the batch handler panics or does not return a result per message,
+// all messages then go to their topic handlers.
+func (kc *KafkaConsumer) runBatchHandler(ctx context.Context, msgs []*Message) (errs []error, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			kc.log.Error("Panic in batch handler", "messages", len(msgs), "panic", r, "stack", string(debug.Stack()))
+			errs, ok = nil, false
+		}
+	}()
+
+	errs = kc.registry.batchHandler(ctx, msgs)
+	if len(errs) != len(msgs) {
+		kc.log.Error("Batch handler returned a wrong number of results", "messages", len(msgs), "results", len(errs))
+		return nil, false
+	}
+
+	return errs, true
+}
+
+func firstError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
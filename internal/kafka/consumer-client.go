@@ -0,0 +1,192 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"project-service/internal/config"
+	"time"
+)
+
+// ConsumerClient is the part of a Kafka consumer KafkaConsumer works with. Rebalances are reported
+// from ReadMessage: onAssigned with the partitions gained, onRevoked before partitions are given up,
+// with lost set when they have already been taken away, e.g. after a session timeout.
+type ConsumerClient interface {
+	Subscribe(
+		topics []string,
+		onAssigned func(partitions []kafka.TopicPartition),
+		onRevoked func(partitions []kafka.TopicPartition, lost bool),
+	) error
+	// ReadMessage returns a kafka.ErrTimedOut error when no message arrives within timeout.
+	ReadMessage(timeout time.Duration) (*kafka.Message, error)
+	CommitOffsets(offsets []kafka.TopicPartition) ([]kafka.TopicPartition, error)
+	Committed(partitions []kafka.TopicPartition, timeout time.Duration) ([]kafka.TopicPartition, error)
+	// Position returns the offset of the next message ReadMessage returns per partition,
+	// kafka.OffsetInvalid while it is not known yet.
+	Position(partitions []kafka.TopicPartition) ([]kafka.TopicPartition, error)
+	WatermarkOffsets(topic string, partition int32, timeout time.Duration) (low, high int64, err error)
+	Close() error
+}
+
+// PartitionReader reads partitions it assigns itself, outside of any consumer group and without committing.
+// Replayer and DeadLetterQueue read through it. Partitions returns the partition ids of a topic,
+// OffsetsForTimes the first offset at or after each timestamp, kafka.OffsetEnd for partitions without one.
+type PartitionReader interface {
+	Partitions(topic string, timeout time.Duration) ([]int32, error)
+	OffsetsForTimes(times []kafka.TopicPartition, timeout time.Duration) ([]kafka.TopicPartition, error)
+	WatermarkOffsets(topic string, partition int32, timeout time.Duration) (low, high int64, err error)
+	Assign(partitions []kafka.TopicPartition) error
+	Pause(partitions []kafka.TopicPartition) error
+	Position(partitions []kafka.TopicPartition) ([]kafka.TopicPartition, error)
+	// ReadMessage returns a kafka.ErrTimedOut error when no message arrives within timeout.
+	ReadMessage(timeout time.Duration) (*kafka.Message, error)
+	Close() error
+}
+
+// PartitionReaderFactory opens a new PartitionReader for every read.
+type PartitionReaderFactory func() (PartitionReader, error)
+
+// DeadLetterSender stores messages that cannot be handled, see DeadLetterQueue.
+type DeadLetterSender interface {
+	Send(ctx context.Context, msg *kafka.Message, cause error, attempts int) error
+}
+
+// ConfluentConsumerClient is a ConsumerClient on confluent-kafka-go. Partitions are balanced with
+// the cooperative-sticky strategy, so only the partitions that move are assigned and revoked.
+type ConfluentConsumerClient struct {
+	consumer *kafka.Consumer
+}
+
+func NewConfluentConsumerClient(cfg *config.Config) *ConfluentConsumerClient {
+	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":             cfg.KafkaHost,
+		"group.id":                      cfg.Consumer.GroupID,
+		"session.timeout.ms":            int(cfg.Consumer.SessionTimeout.Milliseconds()),
+		"heartbeat.interval.ms":         int(cfg.Consumer.HeartbeatInterval.Milliseconds()),
+		"max.poll.interval.ms":          int(cfg.Consumer.MaxPollInterval.Milliseconds()),
+		"auto.offset.reset":             cfg.Consumer.AutoOffsetReset,
+		"partition.assignment.strategy": "cooperative-sticky",
+		"enable.auto.commit":            false,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("Error creating kafka consumer %v", err))
+	}
+
+	return &ConfluentConsumerClient{consumer: consumer}
+}
+
+func (c *ConfluentConsumerClient) Subscribe(
+	topics []string,
+	onAssigned func(partitions []kafka.TopicPartition),
+	onRevoked func(partitions []kafka.TopicPartition, lost bool),
+) error {
+	return c.consumer.SubscribeTopics(topics, func(consumer *kafka.Consumer, event kafka.Event) error {
+		cooperative := consumer.GetRebalanceProtocol() == "COOPERATIVE"
+
+		switch e := event.(type) {
+		case kafka.AssignedPartitions:
+			onAssigned(e.Partitions)
+			if cooperative {
+				return consumer.IncrementalAssign(e.Partitions)
+			}
+			return consumer.Assign(e.Partitions)
+
+		case kafka.RevokedPartitions:
+			onRevoked(e.Partitions, consumer.AssignmentLost())
+			if cooperative {
+				return consumer.IncrementalUnassign(e.Partitions)
+			}
+			return consumer.Unassign()
+		}
+
+		return nil
+	})
+}
+
+func (c *ConfluentConsumerClient) ReadMessage(timeout time.Duration) (*kafka.Message, error) {
+	return c.consumer.ReadMessage(timeout)
+}
+
+func (c *ConfluentConsumerClient) CommitOffsets(offsets []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	return c.consumer.CommitOffsets(offsets)
+}
+
+func (c *ConfluentConsumerClient) Committed(partitions []kafka.TopicPartition, timeout time.Duration) ([]kafka.TopicPartition, error) {
+	return c.consumer.Committed(partitions, int(timeout.Milliseconds()))
+}
+
+func (c *ConfluentConsumerClient) Position(partitions []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	return c.consumer.Position(partitions)
+}
+
+func (c *ConfluentConsumerClient) WatermarkOffsets(topic string, partition int32, timeout time.Duration) (int64, int64, error) {
+	return c.consumer.QueryWatermarkOffsets(topic, partition, int(timeout.Milliseconds()))
+}
+
+func (c *ConfluentConsumerClient) Close() error {
+	return c.consumer.Close()
+}
+
+// ConfluentPartitionReaders opens confluent-kafka-go readers. groupID only names them to the broker,
+// readers never join the group or commit.
+func ConfluentPartitionReaders(kafkaHost, groupID string) PartitionReaderFactory {
+	return func() (PartitionReader, error) {
+		consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
+			"bootstrap.servers":  kafkaHost,
+			"group.id":           groupID,
+			"enable.auto.commit": false,
+			"auto.offset.reset":  "earliest",
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return &confluentPartitionReader{consumer: consumer}, nil
+	}
+}
+
+type confluentPartitionReader struct {
+	consumer *kafka.Consumer
+}
+
+func (c *confluentPartitionReader) Partitions(topic string, timeout time.Duration) ([]int32, error) {
+	metadata, err := c.consumer.GetMetadata(&topic, false, int(timeout.Milliseconds()))
+	if err != nil {
+		return nil, err
+	}
+
+	var partitions []int32
+	for _, p := range metadata.Topics[topic].Partitions {
+		partitions = append(partitions, p.ID)
+	}
+
+	return partitions, nil
+}
+
+func (c *confluentPartitionReader) OffsetsForTimes(times []kafka.TopicPartition, timeout time.Duration) ([]kafka.TopicPartition, error) {
+	return c.consumer.OffsetsForTimes(times, int(timeout.Milliseconds()))
+}
+
+func (c *confluentPartitionReader) WatermarkOffsets(topic string, partition int32, timeout time.Duration) (int64, int64, error) {
+	return c.consumer.QueryWatermarkOffsets(topic, partition, int(timeout.Milliseconds()))
+}
+
+func (c *confluentPartitionReader) Assign(partitions []kafka.TopicPartition) error {
+	return c.consumer.Assign(partitions)
+}
+
+func (c *confluentPartitionReader) Pause(partitions []kafka.TopicPartition) error {
+	return c.consumer.Pause(partitions)
+}
+
+func (c *confluentPartitionReader) Position(partitions []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	return c.consumer.Position(partitions)
+}
+
+func (c *confluentPartitionReader) ReadMessage(timeout time.Duration) (*kafka.Message, error) {
+	return c.consumer.ReadMessage(timeout)
+}
+
+func (c *confluentPartitionReader) Close() error {
+	return c.consumer.Close()
+}
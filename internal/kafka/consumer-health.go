@@ -0,0 +1,291 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ConsumerIdle       = "idle"
+	ConsumerSubscribed = "subscribed"
+	ConsumerStopping   = "stopping"
+	ConsumerClosed     = "closed"
+)
+
+var (
+	consumerAssignedPartitions = expvar.NewInt("kafka_consumer_assigned_partitions")
+	consumerLag                = expvar.NewMap("kafka_consumer_lag")
+	consumerConsecutiveErrors  = expvar.NewInt("kafka_consumer_consecutive_errors")
+	consumerLastMessageUnix    = expvar.NewInt("kafka_consumer_last_message_timestamp_seconds")
+)
+
+// ConsumerStatus is a snapshot of the consumer for the health endpoint.
+type ConsumerStatus struct {
+	State             string            `json:"state"`
+	Partitions        []PartitionStatus `json:"partitions"`
+	LastMessageAt     *time.Time        `json:"lastMessageAt,omitempty"`
+	LagCheckedAt      *time.Time        `json:"lagCheckedAt,omitempty"`
+	Lag               int64             `json:"lag"`
+	ConsecutiveErrors int               `json:"consecutiveErrors"`
+}
+
+// PartitionStatus is an assigned partition. Lag is the distance between the committed offset, or the
+// consumer's position without one, and the high watermark as of the last lag check, -1 until it has been checked.
+type PartitionStatus struct {
+	Topic         string `json:"topic"`
+	Partition     int32  `json:"partition"`
+	Committed     int64  `json:"committed"`
+	HighWatermark int64  `json:"highWatermark"`
+	Lag           int64  `json:"lag"`
+}
+
+// consumerHealth is the state the consumer reports. Errors are counted until a message is finished.
+type consumerHealth struct {
+	mu                sync.Mutex
+	state             string
+	partitions        map[partition]*PartitionStatus
+	lastMessageAt     time.Time
+	lagCheckedAt      time.Time
+	consecutiveErrors int
+}
+
+func newConsumerHealth() *consumerHealth {
+	return &consumerHealth{state: ConsumerIdle, partitions: make(map[partition]*PartitionStatus)}
+}
+
+func (h *consumerHealth) setState(state string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.state = state
+}
+
+func (h *consumerHealth) assigned(partitions []kafka.TopicPartition) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, tp := range partitions {
+		h.partitions[partitionOf(tp)] = &PartitionStatus{
+			Topic:         *tp.Topic,
+			Partition:     tp.Partition,
+			Committed:     int64(kafka.OffsetInvalid),
+			HighWatermark: int64(kafka.OffsetInvalid),
+			Lag:           -1,
+		}
+	}
+	consumerAssignedPartitions.Set(int64(len(h.partitions)))
+}
+
+func (h *consumerHealth) revoked(partitions []kafka.TopicPartition) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, tp := range partitions {
+		delete(h.partitions, partitionOf(tp))
+		consumerLag.Delete(partitionName(tp))
+	}
+	consumerAssignedPartitions.Set(int64(len(h.partitions)))
+}
+
+func (h *consumerHealth) messageRead() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastMessageAt = time.Now()
+	consumerLastMessageUnix.Set(h.lastMessageAt.Unix())
+}
+
+func (h *consumerHealth) failed() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.consecutiveErrors++
+	consumerConsecutiveErrors.Set(int64(h.consecutiveErrors))
+}
+
+func (h *consumerHealth) succeeded() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.consecutiveErrors = 0
+	consumerConsecutiveErrors.Set(0)
+}
+
+func (h *consumerHealth) assignment() []kafka.TopicPartition {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	partitions := make([]kafka.TopicPartition, 0, len(h.partitions))
+	for p := range h.partitions {
+		partitions = append(partitions, p.topicPartition())
+	}
+
+	return partitions
+}
+
+func (h *consumerHealth) setLag(tp kafka.TopicPartition, committed, high int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	p, ok := h.partitions[partitionOf(tp)]
+	if !ok {
+		return
+	}
+	p.Committed = committed
+	p.HighWatermark = high
+	p.Lag = max(high-committed, 0)
+	consumerLag.Set(partitionName(tp), lagVar(p.Lag))
+}
+
+func (h *consumerHealth) lagChecked() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lagCheckedAt = time.Now()
+}
+
+func (h *consumerHealth) status() ConsumerStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	status := ConsumerStatus{
+		State:             h.state,
+		Partitions:        make([]PartitionStatus, 0, len(h.partitions)),
+		ConsecutiveErrors: h.consecutiveErrors,
+	}
+	for _, p := range h.partitions {
+		status.Partitions = append(status.Partitions, *p)
+		status.Lag += max(p.Lag, 0)
+	}
+	sort.Slice(status.Partitions, func(i, j int) bool {
+		if status.Partitions[i].Topic != status.Partitions[j].Topic {
+			return status.Partitions[i].Topic < status.Partitions[j].Topic
+		}
+		return status.Partitions[i].Partition < status.Partitions[j].Partition
+	})
+	if !h.lastMessageAt.IsZero() {
+		lastMessageAt := h.lastMessageAt
+		status.LastMessageAt = &lastMessageAt
+	}
+	if !h.lagCheckedAt.IsZero() {
+		lagCheckedAt := h.lagCheckedAt
+		status.LagCheckedAt = &lagCheckedAt
+	}
+
+	return status
+}
+
+func lagVar(lag int64) *expvar.Int {
+	v := new(expvar.Int)
+	v.Set(lag)
+	return v
+}
+
+func partitionName(tp kafka.TopicPartition) string {
+	return fmt.Sprintf("%s/%d", *tp.Topic, tp.Partition)
+}
+
+// Status returns the current state of the consumer.
+func (kc *KafkaConsumer) Status() ConsumerStatus {
+	return kc.health.status()
+}
+
+// Health fails while the consumer is not subscribed, or its lag or consecutive errors exceed the configured limits.
+func (kc *KafkaConsumer) Health() error {
+	status := kc.health.status()
+
+	var problems []string
+	if status.State != ConsumerSubscribed {
+		problems = append(problems, fmt.Sprintf("consumer is %s", status.State))
+	}
+	if kc.cfg.MaxLag > 0 && status.Lag > kc.cfg.MaxLag {
+		problems = append(problems, fmt.Sprintf("lag %d exceeds %d", status.Lag, kc.cfg.MaxLag))
+	}
+	if kc.cfg.MaxConsecutiveErrors > 0 && status.ConsecutiveErrors > kc.cfg.MaxConsecutiveErrors {
+		problems = append(problems, fmt.Sprintf("%d consecutive errors exceed %d", status.ConsecutiveErrors, kc.cfg.MaxConsecutiveErrors))
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return errors.New(strings.Join(problems, "; "))
+}
+
+// HealthDetails is reported next to the result of Health.
+func (kc *KafkaConsumer) HealthDetails() interface{} {
+	return kc.health.status()
+}
+
+// checkLag refreshes the lag of the assigned partitions every LagCheckInterval until ctx is done.
+func (kc *KafkaConsumer) checkLag(ctx context.Context) {
+	if kc.cfg.LagCheckInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(kc.cfg.LagCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			kc.refreshLag()
+		}
+	}
+}
+
+// lagPosition is the offset the lag of a partition is counted from: its committed offset or, without one,
+// where the consumer reads. Until the consumer has fetched from the partition that is where
+// the offset reset puts it: the end of the partition for latest, its start otherwise.
+func (kc *KafkaConsumer) lagPosition(committed, position kafka.Offset, low, high int64) int64 {
+	switch {
+	case committed >= 0:
+		return int64(committed)
+	case position >= 0:
+		return int64(position)
+	case kc.cfg.AutoOffsetReset == "latest":
+		return high
+	default:
+		return low
+	}
+}
+
+func (kc *KafkaConsumer) refreshLag() {
+	timeout := min(kc.cfg.LagCheckInterval, 5*time.Second)
+
+	partitions := kc.health.assignment()
+	if len(partitions) == 0 {
+		kc.health.lagChecked()
+		return
+	}
+
+	committed, err := kc.consumer.Committed(partitions, timeout)
+	if err != nil {
+		kc.log.Warn("Failed to read committed offsets", "error", err)
+		return
+	}
+	positions, err := kc.consumer.Position(partitions)
+	if err != nil {
+		kc.log.Warn("Failed to read consumer positions", "error", err)
+		return
+	}
+
+	for i, tp := range committed {
+		low, high, err := kc.consumer.WatermarkOffsets(*tp.Topic, tp.Partition, timeout)
+		if err != nil {
+			kc.log.Warn("Failed to read watermark offsets", "partition", partitionName(tp), "error", err)
+			continue
+		}
+
+		kc.health.setLag(tp, kc.lagPosition(tp.Offset, positions[i].Offset, low, high), high)
+	}
+	kc.health.lagChecked()
+}
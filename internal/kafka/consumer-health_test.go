@@ -0,0 +1,56 @@
+package kafka
+
+import (
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestRefreshLagWithoutCommittedOffset(t *testing.T) {
+	tests := []struct {
+		name            string
+		autoOffsetReset string
+		committed       int
+		read            int
+		lag             int64
+	}{
+		{name: "latest counts from the end", autoOffsetReset: "latest", lag: 0},
+		{name: "earliest counts from the start", autoOffsetReset: "earliest", lag: 10},
+		{name: "the position once reading", autoOffsetReset: "earliest", read: 4, lag: 6},
+		{name: "the committed offset when there is one", autoOffsetReset: "latest", committed: 3, lag: 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mc := NewMemoryConsumerClient()
+			for range 10 {
+				mc.Produce("events", 0, nil, []byte("event"))
+			}
+			if tt.committed > 0 {
+				tp := eventsPartition(0)
+				tp.Offset = kafka.Offset(tt.committed)
+				mc.CommitOffsets([]kafka.TopicPartition{tp})
+			}
+			if tt.read > 0 {
+				mc.Subscribe([]string{"events"}, func([]kafka.TopicPartition) {}, func([]kafka.TopicPartition, bool) {})
+				for range tt.read {
+					mc.ReadMessage(time.Second)
+				}
+			}
+
+			cfg := testConsumerConfig()
+			cfg.Consumer.AutoOffsetReset = tt.autoOffsetReset
+			cfg.Consumer.LagCheckInterval = time.Second
+			kc := NewKafkaConsumer(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, mc, NewHandlerRegistry(), valueDecoder{}, &memoryDeadLetters{})
+			kc.health.assigned([]kafka.TopicPartition{eventsPartition(0)})
+
+			kc.refreshLag()
+
+			if lag := kc.health.status().Lag; lag != tt.lag {
+				t.Fatalf("got lag %d, want %d", lag, tt.lag)
+			}
+		})
+	}
+}
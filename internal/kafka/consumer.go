@@ -4,158 +4,321 @@ import (
 	"context"
 	"fmt"
 	"github.com/confluentinc/confluent-kafka-go/kafka"
-	"github.com/linkedin/goavro/v2"
 	"log/slog"
 	"project-service/internal/config"
-	"project-service/internal/dto"
-	projectservice "project-service/internal/services/project"
+	"project-service/internal/lib/backoff"
+	"sync"
+	"time"
 )
 
+type Decoder interface {
+	Decode(topic string, value []byte) (interface{}, error)
+}
+
+// KafkaConsumer reads all topics of the registry with one consumer and hands messages to a pool of workers.
+// Messages of the same project go to the same worker, so they are handled in order, while different
+// projects are handled concurrently. Workers apply the messages they have queued in batches when
+// their topics have a batch handler.
 type KafkaConsumer struct {
-	log            *slog.Logger
-	consumer       *kafka.Consumer
-	topic          string
-	codec          *goavro.Codec
-	projectService *projectservice.ProjectService
+	log      *slog.Logger
+	consumer ConsumerClient
+	registry *HandlerRegistry
+	decoder  Decoder
+	dlq      DeadLetterSender
+	cfg      config.ConsumerConfig
+	retry    config.ConsumerRetryConfig
+	pool     *workerPool[job]
+	offsets  *offsetTracker
+	health   *consumerHealth
+	// commitMu keeps commits of the same partition from overtaking each other
+	commitMu sync.Mutex
 }
 
+// job is a consumed message waiting for a worker.
+type job struct {
+	msg *kafka.Message
+	// epoch is the assignment of the partition the message was read in, see offsetTracker
+	epoch uint64
+	// native is the decoded value of messages that may be applied in a batch, nil for others
+	native interface{}
+	// handle handles the message on its own and reports whether it is finished
+	handle func(ctx context.Context) bool
+}
+
+// NewKafkaConsumer consumes through client, a ConfluentConsumerClient in production and
+// a MemoryConsumerClient in tests.
 func NewKafkaConsumer(
 	log *slog.Logger,
 	cfg *config.Config,
-	topic string,
-	codec *goavro.Codec,
-	projectService *projectservice.ProjectService,
+	client ConsumerClient,
+	registry *HandlerRegistry,
+	decoder Decoder,
+	dlq DeadLetterSender,
 ) *KafkaConsumer {
-	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
-		"bootstrap.servers":  cfg.KafkaHost,
-		"group.id":           "project-psg",
-		"enable.auto.commit": false,
-	})
-	if err != nil {
-		panic(fmt.Sprintf("Error creating kafka consumer %v", err))
-	}
-
 	return &KafkaConsumer{
-		log:            log,
-		consumer:       consumer,
-		topic:          topic,
-		codec:          codec,
-		projectService: projectService,
+		log:      log,
+		consumer: client,
+		registry: registry,
+		decoder:  decoder,
+		dlq:      dlq,
+		cfg:      cfg.Consumer,
+		retry:    cfg.ConsumerRetry,
+		offsets:  newOffsetTracker(),
+		health:   newConsumerHealth(),
 	}
 }
 
-func (kc *KafkaConsumer) Sub() {
-	err := kc.consumer.Subscribe(kc.topic, nil)
-	if err != nil {
-		kc.log.Error("Error subscribing to topic: ", kc.topic, err)
-	}
-}
+// pollTimeout bounds how long Run waits for a message before checking whether it has to stop.
+const pollTimeout = 500 * time.Millisecond
+
+// Run consumes until ctx is cancelled. Messages the workers are handling are finished and committed
+// first, queued ones are left for the next owner, then the consumer closes and leaves the group
+// so its partitions are reassigned right away.
+func (kc *KafkaConsumer) Run(ctx context.Context) error {
+	kc.pool = newWorkerPool(kc.cfg.Workers, kc.cfg.WorkerQueueSize, kc.cfg.BatchSize, kc.cfg.BatchWindow, func(jobs []job) {
+		kc.run(ctx, jobs)
+	})
 
-// Consume > ~1 minute wait for assigning
-func (kc *KafkaConsumer) Consume() {
-	switch kc.topic {
-	case "ProjectStatus":
-		kc.consumeProjectStatus()
-		break
-	case "NewZip":
-		kc.consumeProjectNewZip()
-		break
-	case "DeployPayload":
-		kc.consumeDeployPayload()
-		break
+	topics := kc.registry.Topics()
+	if err := kc.consumer.Subscribe(topics, kc.assigned, kc.revoked); err != nil {
+		kc.pool.stop()
+		return fmt.Errorf("subscribe to topics %v: %w", topics, err)
 	}
-}
+	kc.health.setState(ConsumerSubscribed)
+	kc.log.Info("Started consuming", "topics", topics)
 
-func (kc *KafkaConsumer) consumeProjectStatus() {
-	kc.log.Info("Started consuming ProjectStatus")
+	lagChecked := make(chan struct{})
+	go func() {
+		defer close(lagChecked)
+		kc.checkLag(ctx)
+	}()
 
-	for {
-		msg, err := kc.consumer.ReadMessage(-1)
+	for ctx.Err() == nil {
+		msg, err := kc.consumer.ReadMessage(pollTimeout)
 		if err != nil {
-			kc.log.Error("Error reading from topic ProjectStatus:", err)
+			if !isTimeout(err) {
+				kc.log.Error("Error reading message", "error", err)
+				kc.health.failed()
+			}
 			continue
 		}
 
-		kc.log.Info("New message from topic ProjectStatus")
+		kc.health.messageRead()
+		kc.dispatch(ctx, msg)
+	}
 
-		native, _, err := kc.codec.NativeFromTextual(msg.Value)
-		if err != nil {
-			kc.log.Error("Incorrect message while handling ProjectStatus:", string(msg.Value), err)
-			kc.commitMessage(msg)
-			continue
-		}
+	kc.log.Info("Stopping consumer")
+	kc.health.setState(ConsumerStopping)
+	kc.pool.stop()
+	<-lagChecked
+	err := kc.consumer.Close()
+	kc.health.setState(ConsumerClosed)
+	if err != nil {
+		return fmt.Errorf("close consumer: %w", err)
+	}
 
-		projectStatusDTO := dto.MapNativeToProjectStatusDTO(native)
-		canCommit, _ := kc.projectService.UpdateProjectStatus(context.Background(), projectStatusDTO)
-		if canCommit {
-			kc.commitMessage(msg)
-		}
+	return nil
+}
+
+func (kc *KafkaConsumer) dispatch(ctx context.Context, msg *kafka.Message) {
+	topic := *msg.TopicPartition.Topic
+	epoch := kc.offsets.track(msg.TopicPartition)
+
+	handler, ok := kc.registry.handler(topic)
+	if !ok {
+		kc.log.Error("No handler registered for topic", "topic", topic)
+		kc.submit(msg, "", job{msg: msg, epoch: epoch, handle: func(ctx context.Context) bool {
+			return kc.deadLetter(ctx, msg, epoch, fmt.Errorf("no handler registered for topic %s", topic), 1)
+		}})
+		return
+	}
+
+	native, err := kc.decoder.Decode(topic, msg.Value)
+	if err != nil {
+		kc.log.Error("Incorrect message", "topic", topic, "value", string(msg.Value), "error", err)
+		kc.submit(msg, "", job{msg: msg, epoch: epoch, handle: func(ctx context.Context) bool {
+			return kc.deadLetter(ctx, msg, epoch, err, 1)
+		}})
+		return
 	}
+
+	next := job{msg: msg, epoch: epoch, handle: func(ctx context.Context) bool {
+		return kc.process(ctx, msg, epoch, native, handler)
+	}}
+	if kc.registry.batchable(topic) {
+		next.native = native
+	}
+	kc.submit(msg, kc.registry.key(topic, native), next)
 }
 
-func (kc *KafkaConsumer) consumeProjectNewZip() {
-	kc.log.Info("Started consuming NewZip")
+// submit queues the job on the worker of key. Messages without a key are ordered by their Kafka key,
+// or by their partition when they have none.
+func (kc *KafkaConsumer) submit(msg *kafka.Message, key string, next job) {
+	if key == "" {
+		key = string(msg.Key)
+	}
+	if key == "" {
+		key = fmt.Sprintf("%s/%d", *msg.TopicPartition.Topic, msg.TopicPartition.Partition)
+	}
 
-	for {
-		msg, err := kc.consumer.ReadMessage(-1)
-		if err != nil {
-			kc.log.Error("Error reading from topic NewZip:", err)
-			continue
-		}
+	kc.pool.submit(key, next)
+}
 
-		kc.log.Info("New message from topic NewZip")
+// run handles the jobs a worker took and commits their partitions up to the finished messages.
+// Batchable messages are applied together first, those the batch could not apply are handled one by one
+// in order. Jobs still queued on shutdown, or of partitions revoked since they were read, are dropped:
+// they stay uncommitted and are redelivered to whoever gets the partition.
+func (kc *KafkaConsumer) run(ctx context.Context, jobs []job) {
+	if ctx.Err() != nil {
+		return
+	}
 
-		native, _, err := kc.codec.NativeFromTextual(msg.Value)
-		if err != nil {
-			kc.log.Error("Incorrect message while handling NewZip:", string(msg.Value), err)
-			kc.commitMessage(msg)
+	applied := kc.applyBatch(ctx, jobs)
+
+	advanced := make(map[partition]kafka.TopicPartition)
+	for i, next := range jobs {
+		if !applied[i] && (ctx.Err() != nil || !next.handle(ctx)) {
 			continue
 		}
+		kc.health.succeeded()
+		if kc.offsets.finish(next.msg.TopicPartition, next.epoch) {
+			advanced[partitionOf(next.msg.TopicPartition)] = next.msg.TopicPartition
+		}
+	}
 
-		newZipDTO := dto.MapNativeToNewZipDTO(native)
-		canCommit, _ := kc.projectService.UpdateProjectUrlZip(context.Background(), newZipDTO)
-		if canCommit {
-			kc.commitMessage(msg)
+	if len(advanced) > 0 {
+		partitions := make([]kafka.TopicPartition, 0, len(advanced))
+		for _, tp := range advanced {
+			partitions = append(partitions, tp)
 		}
+		kc.commit(partitions)
 	}
 }
 
-func (kc *KafkaConsumer) consumeDeployPayload() {
-	kc.log.Info("Started consuming DeployPayload")
+// process retries handler in place with exponential backoff and dead-letters the message if it keeps failing.
+// Later messages of the same key wait for it meanwhile. A running attempt is not interrupted by shutdown
+// or a revocation of the partition, but no new attempt is started and process reports the message as
+// unfinished: it is then redelivered to whoever gets the partition next.
+func (kc *KafkaConsumer) process(ctx context.Context, msg *kafka.Message, epoch uint64, native interface{}, handler HandlerFunc) bool {
+	maxAttempts := max(kc.retry.MaxAttempts, 1)
 
-	for {
-		msg, err := kc.consumer.ReadMessage(-1)
-		if err != nil {
-			kc.log.Error("Error reading from topic DeployPayload:", err)
-			continue
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if !kc.offsets.begin(msg.TopicPartition, epoch) {
+			return false
 		}
-
-		kc.log.Info("New message from topic DeployPayload")
-
-		native, _, err := kc.codec.NativeFromTextual(msg.Value)
-		if err != nil {
-			kc.log.Error("Incorrect message while handling DeployPayload:", string(msg.Value), err)
-			kc.commitMessage(msg)
-			continue
+		var result Result
+		result, err = handler(context.WithoutCancel(ctx), &Message{Message: msg, Native: native, Attempt: attempt})
+		kc.offsets.end(msg.TopicPartition, epoch)
+		switch result {
+		case ResultAck:
+			return true
+		case ResultDeadLetter:
+			return kc.deadLetter(ctx, msg, epoch, err, attempt)
+		}
+		kc.health.failed()
+		if attempt == maxAttempts {
+			break
 		}
 
-		deployPayloadDTO := dto.MapNativeToDeployPayloadDTO(native)
-		canCommit, _ := kc.projectService.UpdateProjectUrlDeploy(context.Background(), deployPayloadDTO)
-		if canCommit {
-			kc.commitMessage(msg)
+		delay := backoff.Exponential(kc.retry.InitialBackoff, kc.retry.MaxBackoff, attempt)
+		kc.log.Warn(
+			"Failed to handle message, retrying",
+			"topic", *msg.TopicPartition.Topic,
+			"partition", msg.TopicPartition.Partition,
+			"offset", msg.TopicPartition.Offset,
+			"attempt", attempt,
+			"delay", delay,
+			"error", err,
+		)
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(delay):
 		}
 	}
+
+	return kc.deadLetter(ctx, msg, epoch, err, maxAttempts)
 }
 
-func (kc *KafkaConsumer) commitMessage(msg *kafka.Message) {
-	_, err := kc.consumer.CommitMessage(msg)
-	if err != nil {
+// deadLetter reports the message finished only once it is safely stored in the DLQ. Sending is retried
+// like handling is; a message that cannot be sent stays unfinished, so its partition is not committed
+// past it and it is redelivered after the next rebalance or restart.
+func (kc *KafkaConsumer) deadLetter(ctx context.Context, msg *kafka.Message, epoch uint64, cause error, attempts int) bool {
+	if cause == nil {
+		cause = fmt.Errorf("message was not processed")
+	}
+	maxAttempts := max(kc.retry.MaxAttempts, 1)
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if !kc.offsets.begin(msg.TopicPartition, epoch) {
+			return false
+		}
+		err := kc.dlq.Send(context.WithoutCancel(ctx), msg, cause, attempts)
+		kc.offsets.end(msg.TopicPartition, epoch)
+		if err == nil {
+			return true
+		}
+
+		kc.health.failed()
 		kc.log.Error(
-			"Failed to commit message",
+			"Failed to send message to dead letter queue",
 			"topic", *msg.TopicPartition.Topic,
 			"partition", msg.TopicPartition.Partition,
 			"offset", msg.TopicPartition.Offset,
+			"attempt", attempt,
 			"error", err,
 		)
+		if attempt == maxAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff.Exponential(kc.retry.InitialBackoff, kc.retry.MaxBackoff, attempt)):
+		}
 	}
+
+	return false
+}
+
+// eventId falls back to the message coordinates for producers that do not send an event id,
+// which still makes redeliveries of the same message recognizable.
+func eventId(msg *kafka.Message, fromPayload string) string {
+	if fromPayload != "" {
+		return fromPayload
+	}
+
+	return fmt.Sprintf("%s/%d/%d", *msg.TopicPartition.Topic, msg.TopicPartition.Partition, msg.TopicPartition.Offset)
+}
+
+// commit commits partitions up to their finished messages. The offsets are read under commitMu,
+// so a commit never moves a partition back. Failed commits are repeated by the next one.
+func (kc *KafkaConsumer) commit(partitions []kafka.TopicPartition) {
+	kc.commitMu.Lock()
+	defer kc.commitMu.Unlock()
+
+	offsets := kc.offsets.uncommitted(partitions)
+	if len(offsets) == 0 {
+		return
+	}
+
+	committed, err := kc.consumer.CommitOffsets(offsets)
+	if err != nil {
+		kc.health.failed()
+		for _, tp := range offsets {
+			kc.log.Error(
+				"Failed to commit offset",
+				"topic", *tp.Topic,
+				"partition", tp.Partition,
+				"offset", tp.Offset,
+				"error", err,
+			)
+		}
+		return
+	}
+
+	kc.offsets.committed(committed)
 }
@@ -0,0 +1,396 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"io"
+	"log/slog"
+	"project-service/internal/config"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryDeadLetters is a DeadLetterSender that keeps the messages, the first failures sends fail.
+type memoryDeadLetters struct {
+	mu       sync.Mutex
+	failures int
+	attempts int
+	sent     []*kafka.Message
+}
+
+func (d *memoryDeadLetters) Send(_ context.Context, msg *kafka.Message, _ error, _ int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.attempts++
+	if d.failures != 0 {
+		d.failures--
+		return errors.New("dead letter queue is unavailable")
+	}
+	d.sent = append(d.sent, msg)
+
+	return nil
+}
+
+func (d *memoryDeadLetters) count() (attempts, sent int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.attempts, len(d.sent)
+}
+
+// handled records the values of the messages a test handler finished.
+type handled struct {
+	mu     sync.Mutex
+	values []string
+}
+
+func (h *handled) add(values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.values = append(h.values, values...)
+}
+
+func (h *handled) get() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]string(nil), h.values...)
+}
+
+func testConsumerConfig() *config.Config {
+	return &config.Config{
+		Consumer: config.ConsumerConfig{
+			Workers:         1,
+			WorkerQueueSize: 16,
+			BatchSize:       1,
+			RevokeTimeout:   2 * time.Second,
+		},
+		ConsumerRetry: config.ConsumerRetryConfig{
+			MaxAttempts:    2,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     time.Millisecond,
+		},
+	}
+}
+
+// runConsumer runs a consumer of registry on mc until the returned function is first called.
+func runConsumer(t *testing.T, cfg *config.Config, mc *MemoryConsumerClient, registry *HandlerRegistry, dlq DeadLetterSender) (*KafkaConsumer, func()) {
+	t.Helper()
+
+	kc := NewKafkaConsumer(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, mc, registry, valueDecoder{}, dlq)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- kc.Run(ctx)
+	}()
+
+	var once sync.Once
+	return kc, func() {
+		once.Do(func() {
+			cancel()
+			if err := <-done; err != nil {
+				t.Errorf("consumer stopped with %v", err)
+			}
+		})
+	}
+}
+
+// eventually fails the test unless cond holds within a second.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// revoking reports whether kc is waiting for the messages of tp to give it up.
+func revoking(kc *KafkaConsumer, tp kafka.TopicPartition) bool {
+	kc.offsets.mu.Lock()
+	defer kc.offsets.mu.Unlock()
+
+	p, ok := kc.offsets.partitions[partitionOf(tp)]
+	return ok && p.revoked
+}
+
+func eventsPartition(id int32) kafka.TopicPartition {
+	return partition{topic: "events", partition: id}.topicPartition()
+}
+
+// blockingRegistry handles events: "block" waits for release, other values are recorded.
+func blockingRegistry(h *handled, started chan<- struct{}, release <-chan struct{}) *HandlerRegistry {
+	registry := NewHandlerRegistry()
+	registry.Register("events", "", nil, func(ctx context.Context, msg *Message) (Result, error) {
+		value := msg.Native.(string)
+		if value == "block" {
+			started <- struct{}{}
+			<-release
+		}
+		h.add(value)
+		return ResultAck, nil
+	})
+
+	return registry
+}
+
+func TestRevokeDropsQueuedMessagesAndCommitsFinishedOnes(t *testing.T) {
+	mc := NewMemoryConsumerClient()
+	mc.Produce("events", 0, nil, []byte("block"))
+	mc.Produce("events", 0, nil, []byte("queued"))
+
+	h := &handled{}
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	kc, stop := runConsumer(t, testConsumerConfig(), mc, blockingRegistry(h, started, release), &memoryDeadLetters{})
+	defer stop()
+	<-started
+
+	mc.Revoke(false, eventsPartition(0))
+	eventually(t, "the revocation to start", func() bool { return revoking(kc, eventsPartition(0)) })
+	time.Sleep(20 * time.Millisecond)
+	if len(kc.health.assignment()) == 0 {
+		t.Fatal("the partition was given up while its message was being handled")
+	}
+	close(release)
+
+	eventually(t, "the revocation", func() bool { return len(kc.health.assignment()) == 0 })
+	stop()
+
+	if values := h.get(); len(values) != 1 || values[0] != "block" {
+		t.Fatalf("got handled %v, want only the message being handled at the revocation", values)
+	}
+	if offset := mc.CommittedOffset("events", 0); offset != 1 {
+		t.Fatalf("got committed offset %v, want 1", offset)
+	}
+}
+
+func TestRevokeGivesUpMessagesThatOutlastTheTimeout(t *testing.T) {
+	mc := NewMemoryConsumerClient()
+	mc.Produce("events", 0, nil, []byte("block"))
+	mc.Produce("events", 0, nil, []byte("queued"))
+
+	cfg := testConsumerConfig()
+	cfg.Consumer.RevokeTimeout = 20 * time.Millisecond
+	h := &handled{}
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	kc, stop := runConsumer(t, cfg, mc, blockingRegistry(h, started, release), &memoryDeadLetters{})
+	defer stop()
+	<-started
+
+	mc.Revoke(false, eventsPartition(0))
+	eventually(t, "the revocation", func() bool { return len(kc.health.assignment()) == 0 })
+	close(release)
+	stop()
+
+	if values := h.get(); len(values) != 1 || values[0] != "block" {
+		t.Fatalf("got handled %v, want the queued message to be dropped", values)
+	}
+	if len(mc.Commits()) != 0 {
+		t.Fatalf("got commits %v after the partition was given up", mc.Commits())
+	}
+}
+
+func TestDeadLetterSendsAreBounded(t *testing.T) {
+	mc := NewMemoryConsumerClient()
+	mc.Produce("events", 0, nil, []byte("poison"))
+	mc.Produce("events", 0, nil, []byte("fine"))
+
+	h := &handled{}
+	registry := NewHandlerRegistry()
+	registry.Register("events", "", nil, func(ctx context.Context, msg *Message) (Result, error) {
+		if msg.Native.(string) == "poison" {
+			return ResultDeadLetter, errors.New("poison")
+		}
+		h.add(msg.Native.(string))
+		return ResultAck, nil
+	})
+	dlq := &memoryDeadLetters{failures: -1}
+	_, stop := runConsumer(t, testConsumerConfig(), mc, registry, dlq)
+	defer stop()
+
+	eventually(t, "the next message", func() bool { return len(h.get()) == 1 })
+
+	if attempts, sent := dlq.count(); attempts != 2 || sent != 0 {
+		t.Fatalf("got %d sends and %d dead letters, want 2 failed sends", attempts, sent)
+	}
+	if len(mc.Commits()) != 0 {
+		t.Fatalf("got commits %v past a message that is neither handled nor dead-lettered", mc.Commits())
+	}
+}
+
+func TestCommitsUpToTheLowestUnfinishedMessage(t *testing.T) {
+	mc := NewMemoryConsumerClient()
+	// the keys go to different workers
+	mc.Produce("events", 0, []byte("a"), []byte("block"))
+	mc.Produce("events", 0, []byte("b"), []byte("fine"))
+
+	cfg := testConsumerConfig()
+	cfg.Consumer.Workers = 2
+	h := &handled{}
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	_, stop := runConsumer(t, cfg, mc, blockingRegistry(h, started, release), &memoryDeadLetters{})
+	defer stop()
+	<-started
+
+	eventually(t, "the later message", func() bool { return len(h.get()) == 1 })
+	if offset := mc.CommittedOffset("events", 0); offset != kafka.OffsetInvalid {
+		t.Fatalf("got committed offset %v past the message being handled", offset)
+	}
+
+	close(release)
+	eventually(t, "the commit", func() bool { return mc.CommittedOffset("events", 0) == 2 })
+}
+
+func TestLostPartitionsAreNotCommitted(t *testing.T) {
+	mc := NewMemoryConsumerClient()
+	mc.Produce("events", 0, nil, []byte("block"))
+
+	h := &handled{}
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	kc, stop := runConsumer(t, testConsumerConfig(), mc, blockingRegistry(h, started, release), &memoryDeadLetters{})
+	defer stop()
+	<-started
+
+	mc.Revoke(true, eventsPartition(0))
+	eventually(t, "the revocation to start", func() bool { return revoking(kc, eventsPartition(0)) })
+	close(release)
+	eventually(t, "the revocation", func() bool { return len(kc.health.assignment()) == 0 })
+	stop()
+
+	if values := h.get(); len(values) != 1 {
+		t.Fatalf("got handled %v, want the message being handled to finish", values)
+	}
+	if len(mc.Commits()) != 0 {
+		t.Fatalf("got commits %v of a lost partition", mc.Commits())
+	}
+}
+
+func TestFailingMessagesAreRetriedThenDeadLettered(t *testing.T) {
+	mc := NewMemoryConsumerClient()
+	mc.Produce("events", 0, nil, []byte("failing"))
+
+	h := &handled{}
+	registry := NewHandlerRegistry()
+	registry.Register("events", "", nil, func(ctx context.Context, msg *Message) (Result, error) {
+		h.add(msg.Native.(string))
+		return ResultRetry, errors.New("temporary")
+	})
+	dlq := &memoryDeadLetters{}
+	_, stop := runConsumer(t, testConsumerConfig(), mc, registry, dlq)
+	defer stop()
+
+	eventually(t, "the commit", func() bool { return mc.CommittedOffset("events", 0) == 1 })
+
+	if attempts := len(h.get()); attempts != 2 {
+		t.Fatalf("got %d attempts, want 2", attempts)
+	}
+	if _, sent := dlq.count(); sent != 1 {
+		t.Fatalf("got %d dead letters, want 1", sent)
+	}
+}
+
+func TestBatchesAreAppliedTogether(t *testing.T) {
+	mc := NewMemoryConsumerClient()
+	for _, value := range []string{"first", "bad", "last"} {
+		mc.Produce("events", 0, nil, []byte(value))
+	}
+
+	var mu sync.Mutex
+	var batches [][]string
+	h := &handled{}
+	registry := NewHandlerRegistry()
+	registry.Register("events", "", nil, func(ctx context.Context, msg *Message) (Result, error) {
+		h.add(msg.Native.(string))
+		return ResultAck, nil
+	})
+	registry.HandleBatch(func(ctx context.Context, msgs []*Message) []error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var batch []string
+		errs := make([]error, len(msgs))
+		for i, msg := range msgs {
+			batch = append(batch, msg.Native.(string))
+			if msg.Native.(string) == "bad" {
+				errs[i] = errors.New("bad")
+			}
+		}
+		batches = append(batches, batch)
+		return errs
+	}, "events")
+
+	cfg := testConsumerConfig()
+	cfg.Consumer.BatchSize = 3
+	cfg.Consumer.BatchWindow = time.Second
+	_, stop := runConsumer(t, cfg, mc, registry, &memoryDeadLetters{})
+	defer stop()
+
+	eventually(t, "the commit", func() bool { return mc.CommittedOffset("events", 0) == 3 })
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(batches) != 1 || len(batches[0]) != 3 {
+		t.Fatalf("got batches %v, want all messages in one", batches)
+	}
+	if values := h.get(); len(values) != 1 || values[0] != "bad" {
+		t.Fatalf("got %v handled one by one, want the message the batch failed", values)
+	}
+}
+
+func TestBatchedMessagesGoThroughTheMiddlewares(t *testing.T) {
+	mc := NewMemoryConsumerClient()
+	for _, value := range []string{"first", "bad", "last"} {
+		mc.Produce("events", 0, nil, []byte(value))
+	}
+
+	h := &handled{}
+	registry := NewHandlerRegistry(func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg *Message) (Result, error) {
+			result, err := next(ctx, msg)
+			h.add(msg.Native.(string) + "." + result.String())
+			return result, err
+		}
+	})
+	registry.Register("events", "", nil, func(ctx context.Context, msg *Message) (Result, error) {
+		return ResultAck, nil
+	})
+	registry.HandleBatch(func(ctx context.Context, msgs []*Message) []error {
+		errs := make([]error, len(msgs))
+		for i, msg := range msgs {
+			if msg.Native.(string) == "bad" {
+				errs[i] = errors.New("bad")
+			}
+		}
+		return errs
+	}, "events")
+
+	cfg := testConsumerConfig()
+	cfg.Consumer.BatchSize = 3
+	cfg.Consumer.BatchWindow = time.Second
+	_, stop := runConsumer(t, cfg, mc, registry, &memoryDeadLetters{})
+	defer stop()
+
+	eventually(t, "the commit", func() bool { return mc.CommittedOffset("events", 0) == 3 })
+
+	outcomes := make(map[string]int)
+	for _, outcome := range h.get() {
+		outcomes[outcome]++
+	}
+	// the message the batch failed on only goes through them with its topic handler
+	want := map[string]int{"first.ack": 1, "last.ack": 1, "bad.ack": 1}
+	if len(outcomes) != len(want) {
+		t.Fatalf("got outcomes %v, want %v", outcomes, want)
+	}
+	for outcome, count := range want {
+		if outcomes[outcome] != count {
+			t.Fatalf("got outcomes %v, want %v", outcomes, want)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	DeadLetterTopicSuffix = ".DLQ"
+
+	HeaderDLQError             = "x-dlq-error"
+	HeaderDLQAttempts          = "x-dlq-attempts"
+	HeaderDLQOriginalTopic     = "x-dlq-original-topic"
+	HeaderDLQOriginalPartition = "x-dlq-original-partition"
+	HeaderDLQOriginalOffset    = "x-dlq-original-offset"
+	HeaderDLQFailedAt          = "x-dlq-failed-at"
+	HeaderDLQReplayedFrom      = "x-dlq-replayed-from"
+)
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+type DeadLetter struct {
+	Topic             string
+	Partition         int32
+	Offset            int64
+	OriginalTopic     string
+	OriginalPartition int32
+	OriginalOffset    int64
+	Error             string
+	Attempts          int
+	Key               []byte
+	Value             []byte
+	FailedAt          time.Time
+}
+
+// DeadLetterQueue moves poison messages of a topic to <topic>.DLQ and lets admins browse and replay them.
+type DeadLetterQueue struct {
+	log      *slog.Logger
+	producer *KafkaProducer
+	readers  PartitionReaderFactory
+}
+
+func NewDeadLetterQueue(log *slog.Logger, producer *KafkaProducer, readers PartitionReaderFactory) *DeadLetterQueue {
+	return &DeadLetterQueue{
+		log:      log,
+		producer: producer,
+		readers:  readers,
+	}
+}
+
+func (q *DeadLetterQueue) Send(ctx context.Context, msg *kafka.Message, cause error, attempts int) error {
+	topic := *msg.TopicPartition.Topic
+	dlqTopic := topic + DeadLetterTopicSuffix
+
+	headers := append([]kafka.Header(nil), msg.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: HeaderDLQError, Value: []byte(cause.Error())},
+		kafka.Header{Key: HeaderDLQAttempts, Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: HeaderDLQOriginalTopic, Value: []byte(topic)},
+		kafka.Header{Key: HeaderDLQOriginalPartition, Value: []byte(strconv.Itoa(int(msg.TopicPartition.Partition)))},
+		kafka.Header{Key: HeaderDLQOriginalOffset, Value: []byte(strconv.FormatInt(int64(msg.TopicPartition.Offset), 10))},
+		kafka.Header{Key: HeaderDLQFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339))},
+	)
+
+	err := q.producer.produce(ctx, &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &dlqTopic, Partition: kafka.PartitionAny},
+		Key:            msg.Key,
+		Value:          msg.Value,
+		Headers:        headers,
+	})
+	if err != nil {
+		return err
+	}
+
+	q.log.Warn("Message moved to dead letter queue",
+		"topic", topic,
+		"partition", msg.TopicPartition.Partition,
+		"offset", msg.TopicPartition.Offset,
+		"attempts", attempts,
+		"error", cause,
+	)
+
+	return nil
+}
+
+// List returns up to limit newest dead letters of the topic.
+func (q *DeadLetterQueue) List(ctx context.Context, topic string, limit int) ([]DeadLetter, error) {
+	dlqTopic := topic + DeadLetterTopicSuffix
+
+	reader, err := q.readers()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	partitions, err := reader.Partitions(dlqTopic, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	var assignment []kafka.TopicPartition
+	highWatermarks := make(map[int32]int64)
+	for _, partition := range partitions {
+		low, high, err := reader.WatermarkOffsets(dlqTopic, partition, 5*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		if high <= low {
+			continue
+		}
+
+		assignment = append(assignment, kafka.TopicPartition{
+			Topic:     &dlqTopic,
+			Partition: partition,
+			Offset:    kafka.Offset(max(low, high-int64(limit))),
+		})
+		highWatermarks[partition] = high
+	}
+	if len(assignment) == 0 {
+		return nil, nil
+	}
+	if err := reader.Assign(assignment); err != nil {
+		return nil, err
+	}
+
+	var deadLetters []DeadLetter
+	for len(highWatermarks) > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		msg, err := reader.ReadMessage(time.Second)
+		if err != nil {
+			if isTimeout(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		deadLetters = append(deadLetters, toDeadLetter(msg))
+		if int64(msg.TopicPartition.Offset)+1 >= highWatermarks[msg.TopicPartition.Partition] {
+			delete(highWatermarks, msg.TopicPartition.Partition)
+		}
+	}
+
+	sort.Slice(deadLetters, func(i, j int) bool {
+		return deadLetters[i].FailedAt.After(deadLetters[j].FailedAt)
+	})
+	if len(deadLetters) > limit {
+		deadLetters = deadLetters[:limit]
+	}
+
+	return deadLetters, nil
+}
+
+// Replay produces the dead letter at partition/offset of <topic>.DLQ back to its original topic.
+func (q *DeadLetterQueue) Replay(ctx context.Context, topic string, partition int32, offset int64) error {
+	dlqTopic := topic + DeadLetterTopicSuffix
+
+	reader, err := q.readers()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	err = reader.Assign([]kafka.TopicPartition{
+		{Topic: &dlqTopic, Partition: partition, Offset: kafka.Offset(offset)},
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var msg *kafka.Message
+	for msg == nil {
+		if err := ctx.Err(); err != nil {
+			return ErrDeadLetterNotFound
+		}
+
+		msg, err = reader.ReadMessage(time.Second)
+		if err != nil {
+			if isTimeout(err) {
+				continue
+			}
+			return err
+		}
+	}
+	if int64(msg.TopicPartition.Offset) != offset {
+		return ErrDeadLetterNotFound
+	}
+
+	deadLetter := toDeadLetter(msg)
+	originalTopic := deadLetter.OriginalTopic
+	if originalTopic == "" {
+		originalTopic = topic
+	}
+
+	err = q.producer.produce(ctx, &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &originalTopic, Partition: kafka.PartitionAny},
+		Key:            msg.Key,
+		Value:          msg.Value,
+		Headers: []kafka.Header{
+			{Key: HeaderDLQReplayedFrom, Value: []byte(fmt.Sprintf("%s/%d/%d", dlqTopic, partition, offset))},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	q.log.Info("Dead letter replayed", "topic", originalTopic, "dlqPartition", partition, "dlqOffset", offset)
+
+	return nil
+}
+
+func toDeadLetter(msg *kafka.Message) DeadLetter {
+	deadLetter := DeadLetter{
+		Topic:     *msg.TopicPartition.Topic,
+		Partition: msg.TopicPartition.Partition,
+		Offset:    int64(msg.TopicPartition.Offset),
+		Key:       msg.Key,
+		Value:     msg.Value,
+		FailedAt:  msg.Timestamp,
+	}
+
+	for _, header := range msg.Headers {
+		value := string(header.Value)
+		switch header.Key {
+		case HeaderDLQError:
+			deadLetter.Error = value
+		case HeaderDLQAttempts:
+			deadLetter.Attempts, _ = strconv.Atoi(value)
+		case HeaderDLQOriginalTopic:
+			deadLetter.OriginalTopic = value
+		case HeaderDLQOriginalPartition:
+			partition, _ := strconv.ParseInt(value, 10, 32)
+			deadLetter.OriginalPartition = int32(partition)
+		case HeaderDLQOriginalOffset:
+			deadLetter.OriginalOffset, _ = strconv.ParseInt(value, 10, 64)
+		case HeaderDLQFailedAt:
+			if failedAt, err := time.Parse(time.RFC3339, value); err == nil {
+				deadLetter.FailedAt = failedAt
+			}
+		}
+	}
+
+	return deadLetter
+}
+
+func isTimeout(err error) bool {
+	var kafkaErr kafka.Error
+	return errors.As(err, &kafkaErr) && kafkaErr.Code() == kafka.ErrTimedOut
+}
@@ -0,0 +1,35 @@
+package kafka
+
+import (
+	"context"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestListReturnsNewestDeadLetters(t *testing.T) {
+	mc := NewMemoryConsumerClient()
+	for i, failedAt := range []string{"2024-01-01T10:00:00Z", "2024-01-01T12:00:00Z", "2024-01-01T11:00:00Z"} {
+		mc.Produce("events.DLQ", int32(i%2), nil, []byte("value"),
+			kafka.Header{Key: HeaderDLQFailedAt, Value: []byte(failedAt)},
+			kafka.Header{Key: HeaderDLQError, Value: []byte("boom")},
+		)
+	}
+
+	dlq := NewDeadLetterQueue(slog.New(slog.NewTextHandler(io.Discard, nil)), nil, mc.PartitionReaders())
+	deadLetters, err := dlq.List(context.Background(), "events", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deadLetters) != 2 {
+		t.Fatalf("got %d dead letters, want 2", len(deadLetters))
+	}
+	if deadLetters[0].Partition != 1 || deadLetters[1].Partition != 0 || deadLetters[1].Offset != 1 {
+		t.Fatalf("got %+v, want the dead letters failed at 12:00 and 11:00", deadLetters)
+	}
+	if deadLetters[0].Error != "boom" {
+		t.Fatalf("got error %q, want boom", deadLetters[0].Error)
+	}
+}
@@ -0,0 +1,252 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"sort"
+)
+
+// Result tells the consumer what to do with a message once its handler returns.
+type Result int
+
+const (
+	// ResultAck commits the message.
+	ResultAck Result = iota
+	// ResultRetry handles the message again after a backoff, up to the configured number of attempts.
+	ResultRetry
+	// ResultDeadLetter moves the message to the dead letter queue without further attempts.
+	ResultDeadLetter
+)
+
+func (r Result) String() string {
+	switch r {
+	case ResultAck:
+		return "ack"
+	case ResultRetry:
+		return "retry"
+	case ResultDeadLetter:
+		return "dead_letter"
+	default:
+		return fmt.Sprintf("result(%d)", int(r))
+	}
+}
+
+// Message is a consumed Kafka message together with its decoded Avro value.
+type Message struct {
+	*kafka.Message
+	Native  interface{}
+	Attempt int
+}
+
+func (m *Message) Topic() string {
+	return *m.TopicPartition.Topic
+}
+
+// Header returns the value of the first header with the given key.
+func (m *Message) Header(key string) (string, bool) {
+	for _, header := range m.Headers {
+		if header.Key == key {
+			return string(header.Value), true
+		}
+	}
+
+	return "", false
+}
+
+type HandlerFunc func(ctx context.Context, msg *Message) (Result, error)
+
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// BatchHandlerFunc handles messages of several topics at once, in consumption order. It returns an error
+// per message, nil for the ones it finished; the others are passed to their topic handler one by one.
+type BatchHandlerFunc func(ctx context.Context, msgs []*Message) []error
+
+// Preview is what handling a message would do. Key identifies what it changes, e.g. the project.
+// Unchanged is set when handling it would change nothing, as for duplicates.
+type Preview struct {
+	Outcome   string
+	Key       string
+	Change    string
+	Unchanged bool
+}
+
+// PreviewFunc reports what handling the messages would do without changing anything, in order, so that
+// later messages see the changes of earlier ones, also of the messages of earlier calls. It returns
+// a preview per message. With reprocess, messages that were handled before count as if they had not been.
+type PreviewFunc func(ctx context.Context, msgs []*Message, reprocess bool) ([]Preview, error)
+
+// PreviewFactory returns the PreviewFunc of one dry run, which passes it the messages in chunks.
+type PreviewFactory func() PreviewFunc
+
+// ReplayFunc handles replayed messages in order and reports the outcome of each like a PreviewFunc does.
+// With reprocess, messages that were handled before are handled again instead of being acknowledged
+// as duplicates. An error means none of the messages were handled.
+type ReplayFunc func(ctx context.Context, msgs []*Message, reprocess bool) ([]Preview, error)
+
+// KeyFunc returns the key messages have to stay ordered by, "" when the decoded value has none.
+type KeyFunc func(native interface{}) string
+
+// HandlerRegistry maps consumed topics to their handlers.
+// Middlewares wrap every handler, the first one being the outermost.
+type HandlerRegistry struct {
+	handlers      map[string]HandlerFunc
+	keys          map[string]KeyFunc
+	readerSchemas map[string]string
+	middlewares   []Middleware
+	batchHandler  BatchHandlerFunc
+	batchTopics   map[string]bool
+	previews      map[string]PreviewFactory
+	replays       map[string]ReplayFunc
+}
+
+func NewHandlerRegistry(middlewares ...Middleware) *HandlerRegistry {
+	return &HandlerRegistry{
+		handlers:      make(map[string]HandlerFunc),
+		keys:          make(map[string]KeyFunc),
+		readerSchemas: make(map[string]string),
+		middlewares:   middlewares,
+		batchTopics:   make(map[string]bool),
+		previews:      make(map[string]PreviewFactory),
+		replays:       make(map[string]ReplayFunc),
+	}
+}
+
+// Register adds the handler of topic. Messages are decoded into readerSchema before they reach it.
+// Messages with the same key are handled one at a time in offset order, others may be handled
+// concurrently. Without a key function the Kafka message key is used.
+func (r *HandlerRegistry) Register(topic, readerSchema string, key KeyFunc, handler HandlerFunc) {
+	if _, ok := r.handlers[topic]; ok {
+		panic(fmt.Sprintf("handler for topic %s is already registered", topic))
+	}
+
+	r.handlers[topic] = r.wrap(handler)
+	r.readerSchemas[topic] = readerSchema
+	if key != nil {
+		r.keys[topic] = key
+	}
+}
+
+// wrap puts handler inside the middlewares.
+func (r *HandlerRegistry) wrap(handler HandlerFunc) HandlerFunc {
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		handler = r.middlewares[i](handler)
+	}
+
+	return handler
+}
+
+// Handle registers a typed handler: the decoded value is turned into T by mapper before handle is called.
+// Values the mapper rejects are dead-lettered, retrying cannot fix them. key orders the events, see Register.
+func Handle[T any](
+	r *HandlerRegistry,
+	topic string,
+	readerSchema string,
+	mapper func(native interface{}) (T, error),
+	key func(event T) string,
+	handle func(ctx context.Context, msg *Message, event T) (Result, error),
+) {
+	var keyFunc KeyFunc
+	if key != nil {
+		keyFunc = func(native interface{}) string {
+			event, err := mapper(native)
+			if err != nil {
+				return ""
+			}
+			return key(event)
+		}
+	}
+
+	r.Register(topic, readerSchema, keyFunc, func(ctx context.Context, msg *Message) (Result, error) {
+		event, err := mapper(msg.Native)
+		if err != nil {
+			return ResultDeadLetter, fmt.Errorf("map message from topic %s: %w", topic, err)
+		}
+
+		return handle(ctx, msg, event)
+	})
+}
+
+// HandleBatch lets handler apply messages of topics in batches. The topics must already be registered:
+// their handlers still take the messages the batch handler fails on.
+func (r *HandlerRegistry) HandleBatch(handler BatchHandlerFunc, topics ...string) {
+	if r.batchHandler != nil {
+		panic("batch handler is already registered")
+	}
+	for _, topic := range topics {
+		if _, ok := r.handlers[topic]; !ok {
+			panic(fmt.Sprintf("batch handler for topic %s without a handler", topic))
+		}
+		r.batchTopics[topic] = true
+	}
+	r.batchHandler = handler
+}
+
+// HandlePreview lets the PreviewFuncs of handler preview messages of topics, which makes dry runs of their
+// replays possible.
+func (r *HandlerRegistry) HandlePreview(handler PreviewFactory, topics ...string) {
+	for _, topic := range topics {
+		if _, ok := r.handlers[topic]; !ok {
+			panic(fmt.Sprintf("preview handler for topic %s without a handler", topic))
+		}
+		if _, ok := r.previews[topic]; ok {
+			panic(fmt.Sprintf("preview handler for topic %s is already registered", topic))
+		}
+		r.previews[topic] = handler
+	}
+}
+
+// HandleReplay lets handler apply replayed messages of topics, which reports their outcomes and makes
+// reprocessing replays possible. Replays of other topics go through the topic handler message by message.
+func (r *HandlerRegistry) HandleReplay(handler ReplayFunc, topics ...string) {
+	for _, topic := range topics {
+		if _, ok := r.handlers[topic]; !ok {
+			panic(fmt.Sprintf("replay handler for topic %s without a handler", topic))
+		}
+		if _, ok := r.replays[topic]; ok {
+			panic(fmt.Sprintf("replay handler for topic %s is already registered", topic))
+		}
+		r.replays[topic] = handler
+	}
+}
+
+func (r *HandlerRegistry) Topics() []string {
+	topics := make([]string, 0, len(r.handlers))
+	for topic := range r.handlers {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	return topics
+}
+
+func (r *HandlerRegistry) ReaderSchemas() map[string]string {
+	return r.readerSchemas
+}
+
+func (r *HandlerRegistry) batchable(topic string) bool {
+	return r.batchTopics[topic]
+}
+
+func (r *HandlerRegistry) key(topic string, native interface{}) string {
+	if key, ok := r.keys[topic]; ok {
+		return key(native)
+	}
+
+	return ""
+}
+
+func (r *HandlerRegistry) handler(topic string) (HandlerFunc, bool) {
+	handler, ok := r.handlers[topic]
+	return handler, ok
+}
+
+func (r *HandlerRegistry) preview(topic string) (PreviewFactory, bool) {
+	preview, ok := r.previews[topic]
+	return preview, ok
+}
+
+func (r *HandlerRegistry) replay(topic string) (ReplayFunc, bool) {
+	replay, ok := r.replays[topic]
+	return replay, ok
+}
@@ -0,0 +1,168 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"project-service/internal/domain/models"
+	"project-service/internal/dto"
+	"project-service/internal/events"
+)
+
+// ProjectEventHandler applies the events other services report about projects.
+// It returns false when the message has to be handled again, ErrInvalidStatusTransition for
+// status updates the state machine rejects; repeated statuses are acknowledged without an error.
+// Deploy payloads with an invalid environment name fail with ErrInvalidEnvironment. ApplyProjectEvents applies
+// several events at once and returns an error for each event that still has to be handled,
+// ReplayProjectEvents applies replayed events and reports their outcomes, PreviewProjectEvents
+// starts a dry run that reports what replaying them would do.
+type ProjectEventHandler interface {
+	UpdateProjectStatus(ctx context.Context, dto dto.ProjectStatusDTO) (bool, error)
+	UpdateProjectUrlZip(ctx context.Context, dto dto.NewZipDTO) (bool, error)
+	UpdateProjectUrlDeploy(ctx context.Context, dto dto.DeployPayloadDTO) (bool, error)
+	ApplyProjectEvents(ctx context.Context, events []dto.ConsumedProjectEvent) []error
+	ReplayProjectEvents(ctx context.Context, events []dto.ConsumedProjectEvent, reprocess bool) ([]dto.ConsumedEventPreview, error)
+	PreviewProjectEvents() func(ctx context.Context, events []dto.ConsumedProjectEvent, reprocess bool) ([]dto.ConsumedEventPreview, error)
+}
+
+func RegisterProjectHandlers(registry *HandlerRegistry, projectService ProjectEventHandler) {
+	Handle(registry, dto.ProjectStatusTopic, events.ProjectStatusSchema, dto.MapNativeToProjectStatusDTO,
+		func(event dto.ProjectStatusDTO) string { return event.Id },
+		func(ctx context.Context, msg *Message, event dto.ProjectStatusDTO) (Result, error) {
+			event.EventId = eventId(msg.Message, event.EventId)
+			canCommit, err := projectService.UpdateProjectStatus(ctx, event)
+			if errors.Is(err, models.ErrInvalidStatusTransition) {
+				// retrying cannot help, the DLQ keeps it for a replay once the state machine allows it
+				return ResultDeadLetter, err
+			}
+			return resultOf(canCommit, err)
+		})
+
+	Handle(registry, dto.NewZipTopic, events.NewZipSchema, dto.MapNativeToNewZipDTO,
+		func(event dto.NewZipDTO) string { return projectKey(event.Owner, event.Name) },
+		func(ctx context.Context, msg *Message, event dto.NewZipDTO) (Result, error) {
+			event.EventId = eventId(msg.Message, event.EventId)
+			return resultOf(projectService.UpdateProjectUrlZip(ctx, event))
+		})
+
+	Handle(registry, dto.DeployPayloadTopic, events.DeployPayloadSchema, dto.MapNativeToDeployPayloadDTO,
+		func(event dto.DeployPayloadDTO) string { return projectKey(event.Owner, event.Name) },
+		func(ctx context.Context, msg *Message, event dto.DeployPayloadDTO) (Result, error) {
+			event.EventId = eventId(msg.Message, event.EventId)
+			canCommit, err := projectService.UpdateProjectUrlDeploy(ctx, event)
+			if errors.Is(err, models.ErrInvalidEnvironment) {
+				// the payload cannot be applied however often it is retried
+				return ResultDeadLetter, err
+			}
+			return resultOf(canCommit, err)
+		})
+
+	registry.HandleBatch(func(ctx context.Context, msgs []*Message) []error {
+		errs := make([]error, len(msgs))
+
+		events := make([]dto.ConsumedProjectEvent, 0, len(msgs))
+		index := make([]int, 0, len(msgs))
+		for i, msg := range msgs {
+			event, err := consumedProjectEvent(msg)
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+			events = append(events, event)
+			index = append(index, i)
+		}
+
+		for i, err := range projectService.ApplyProjectEvents(ctx, events) {
+			errs[index[i]] = err
+		}
+
+		return errs
+	}, dto.ProjectStatusTopic, dto.NewZipTopic, dto.DeployPayloadTopic)
+
+	registry.HandlePreview(func() PreviewFunc {
+		preview := projectService.PreviewProjectEvents()
+		return func(ctx context.Context, msgs []*Message, reprocess bool) ([]Preview, error) {
+			return projectEventPreviews(msgs, func(events []dto.ConsumedProjectEvent) ([]dto.ConsumedEventPreview, error) {
+				return preview(ctx, events, reprocess)
+			})
+		}
+	}, dto.ProjectStatusTopic, dto.NewZipTopic, dto.DeployPayloadTopic)
+
+	registry.HandleReplay(func(ctx context.Context, msgs []*Message, reprocess bool) ([]Preview, error) {
+		return projectEventPreviews(msgs, func(events []dto.ConsumedProjectEvent) ([]dto.ConsumedEventPreview, error) {
+			return projectService.ReplayProjectEvents(ctx, events, reprocess)
+		})
+	}, dto.ProjectStatusTopic, dto.NewZipTopic, dto.DeployPayloadTopic)
+}
+
+// projectEventPreviews passes the events of msgs to fn and turns its outcomes into a preview per message.
+// Messages that cannot be mapped fail without reaching fn.
+func projectEventPreviews(
+	msgs []*Message,
+	fn func(events []dto.ConsumedProjectEvent) ([]dto.ConsumedEventPreview, error),
+) ([]Preview, error) {
+	previews := make([]Preview, len(msgs))
+
+	events := make([]dto.ConsumedProjectEvent, 0, len(msgs))
+	index := make([]int, 0, len(msgs))
+	for i, msg := range msgs {
+		event, err := consumedProjectEvent(msg)
+		if err != nil {
+			previews[i] = Preview{Outcome: ReplayFailed, Change: err.Error()}
+			continue
+		}
+		events = append(events, event)
+		index = append(index, i)
+	}
+	if len(events) == 0 {
+		return previews, nil
+	}
+
+	eventPreviews, err := fn(events)
+	if err != nil {
+		return nil, err
+	}
+	for i, preview := range eventPreviews {
+		previews[index[i]] = Preview{
+			Outcome:   preview.Outcome,
+			Key:       preview.ProjectId,
+			Change:    preview.Change,
+			Unchanged: preview.Outcome == dto.ConsumedEventDuplicate,
+		}
+	}
+
+	return previews, nil
+}
+
+// consumedProjectEvent maps a message of the batched topics, invalid ones are left to their topic handler.
+func consumedProjectEvent(msg *Message) (dto.ConsumedProjectEvent, error) {
+	switch msg.Topic() {
+	case dto.ProjectStatusTopic:
+		event, err := dto.MapNativeToProjectStatusDTO(msg.Native)
+		event.EventId = eventId(msg.Message, event.EventId)
+		return dto.ConsumedProjectEvent{Status: &event}, err
+	case dto.NewZipTopic:
+		event, err := dto.MapNativeToNewZipDTO(msg.Native)
+		event.EventId = eventId(msg.Message, event.EventId)
+		return dto.ConsumedProjectEvent{Zip: &event}, err
+	case dto.DeployPayloadTopic:
+		event, err := dto.MapNativeToDeployPayloadDTO(msg.Native)
+		event.EventId = eventId(msg.Message, event.EventId)
+		return dto.ConsumedProjectEvent{Deploy: &event}, err
+	default:
+		return dto.ConsumedProjectEvent{}, fmt.Errorf("topic %s is not batched", msg.Topic())
+	}
+}
+
+// projectKey matches the compose id ProjectStatus events carry, so all events of a project share a worker.
+func projectKey(owner, name string) string {
+	return owner + "_" + name
+}
+
+func resultOf(canCommit bool, err error) (Result, error) {
+	if canCommit {
+		return ResultAck, err
+	}
+
+	return ResultRetry, err
+}
@@ -0,0 +1,96 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"project-service/internal/domain/models"
+	"project-service/internal/dto"
+	"testing"
+)
+
+// statusUpdates is a ProjectEventHandler answering status updates with canCommit and err;
+// other methods are not implemented.
+type statusUpdates struct {
+	ProjectEventHandler
+	canCommit bool
+	err       error
+}
+
+func (s statusUpdates) UpdateProjectStatus(context.Context, dto.ProjectStatusDTO) (bool, error) {
+	return s.canCommit, s.err
+}
+
+func TestStatusHandlerOnlyDeadLettersRejectedTransitions(t *testing.T) {
+	topic := dto.ProjectStatusTopic
+	msg := &Message{
+		Message: &kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic}},
+		Native:  map[string]interface{}{"id": "alice_api", "status": models.StatusGenerating},
+	}
+
+	tests := []struct {
+		name    string
+		service statusUpdates
+		result  Result
+	}{
+		// the service acknowledges repeated statuses and updates of superseded jobs without an error
+		{name: "applied or repeated status", service: statusUpdates{canCommit: true}, result: ResultAck},
+		{name: "regression", service: statusUpdates{canCommit: true, err: fmt.Errorf("%w: GENERATED -> GENERATING", models.ErrInvalidStatusTransition)}, result: ResultDeadLetter},
+		{name: "mongo unavailable", service: statusUpdates{err: errors.New("connection refused")}, result: ResultRetry},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewHandlerRegistry()
+			RegisterProjectHandlers(registry, tt.service)
+			handler, _ := registry.handler(topic)
+
+			if result, err := handler(context.Background(), msg); result != tt.result {
+				t.Fatalf("got %s (%v), want %s", result, err, tt.result)
+			}
+		})
+	}
+}
+
+// deployUpdates is a ProjectEventHandler answering deploy payloads with canCommit and err;
+// other methods are not implemented.
+type deployUpdates struct {
+	ProjectEventHandler
+	canCommit bool
+	err       error
+}
+
+func (d deployUpdates) UpdateProjectUrlDeploy(context.Context, dto.DeployPayloadDTO) (bool, error) {
+	return d.canCommit, d.err
+}
+
+func TestDeployHandlerDeadLettersInvalidEnvironments(t *testing.T) {
+	topic := dto.DeployPayloadTopic
+	msg := &Message{
+		Message: &kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic}},
+		Native:  map[string]interface{}{"owner": "alice", "name": "api", "url": "https://api.example.com", "environment": map[string]interface{}{"string": "Prod!"}},
+	}
+
+	tests := []struct {
+		name    string
+		service deployUpdates
+		result  Result
+	}{
+		{name: "applied", service: deployUpdates{canCommit: true}, result: ResultAck},
+		{name: "invalid environment", service: deployUpdates{canCommit: true, err: fmt.Errorf("%w: %q", models.ErrInvalidEnvironment, "Prod!")}, result: ResultDeadLetter},
+		{name: "mongo unavailable", service: deployUpdates{err: errors.New("connection refused")}, result: ResultRetry},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewHandlerRegistry()
+			RegisterProjectHandlers(registry, tt.service)
+			handler, _ := registry.handler(topic)
+
+			if result, err := handler(context.Background(), msg); result != tt.result {
+				t.Fatalf("got %s (%v), want %s", result, err, tt.result)
+			}
+		})
+	}
+}
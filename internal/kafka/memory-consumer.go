@@ -0,0 +1,492 @@
+package kafka
+
+import (
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryConsumerClient is a ConsumerClient over in-memory partitions. It is meant for tests of the
+// consume path that do not talk to a broker: messages are added with Produce, rebalances and errors
+// are simulated with Assign, Revoke and FailNextRead/FailNextCommit, and commits can be inspected.
+// Subscribed topics are assigned in full, partitions are read from their committed offset.
+type MemoryConsumerClient struct {
+	mu         sync.Mutex
+	messages   map[partition][]*kafka.Message
+	topics     map[string]bool
+	onAssigned func(partitions []kafka.TopicPartition)
+	onRevoked  func(partitions []kafka.TopicPartition, lost bool)
+	rebalances []memoryRebalance
+	assigned   map[partition]bool
+	position   map[partition]kafka.Offset
+	committed  map[partition]kafka.Offset
+	commits    [][]kafka.TopicPartition
+	readErrs   []error
+	commitErrs []error
+	wake       chan struct{} // wakes a waiting ReadMessage up
+	subscribed bool
+	closed     bool
+}
+
+type memoryRebalance struct {
+	partitions []kafka.TopicPartition
+	revoke     bool
+	lost       bool
+}
+
+func NewMemoryConsumerClient() *MemoryConsumerClient {
+	return &MemoryConsumerClient{
+		messages:  make(map[partition][]*kafka.Message),
+		topics:    make(map[string]bool),
+		assigned:  make(map[partition]bool),
+		position:  make(map[partition]kafka.Offset),
+		committed: make(map[partition]kafka.Offset),
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Produce appends a message to the partition and returns its offset. A new partition of a subscribed
+// topic is assigned with the next ReadMessage.
+func (mc *MemoryConsumerClient) Produce(topic string, partitionId int32, key, value []byte, headers ...kafka.Header) kafka.Offset {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	p := partition{topic: topic, partition: partitionId}
+	if _, ok := mc.messages[p]; !ok && mc.subscribed && mc.topics[topic] {
+		mc.rebalances = append(mc.rebalances, memoryRebalance{partitions: []kafka.TopicPartition{p.topicPartition()}})
+	}
+
+	offset := kafka.Offset(len(mc.messages[p]))
+	tp := p.topicPartition()
+	tp.Offset = offset
+	mc.messages[p] = append(mc.messages[p], &kafka.Message{
+		TopicPartition: tp,
+		Key:            key,
+		Value:          value,
+		Headers:        headers,
+		Timestamp:      time.Now(),
+	})
+
+	mc.wakeUp()
+
+	return offset
+}
+
+func (mc *MemoryConsumerClient) wakeUp() {
+	select {
+	case mc.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (mc *MemoryConsumerClient) Subscribe(
+	topics []string,
+	onAssigned func(partitions []kafka.TopicPartition),
+	onRevoked func(partitions []kafka.TopicPartition, lost bool),
+) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.subscribed = true
+	mc.onAssigned = onAssigned
+	mc.onRevoked = onRevoked
+
+	var partitions []kafka.TopicPartition
+	for _, topic := range topics {
+		mc.topics[topic] = true
+		for p := range mc.messages {
+			if p.topic == topic {
+				partitions = append(partitions, p.topicPartition())
+			}
+		}
+	}
+	if len(partitions) > 0 {
+		mc.rebalances = append(mc.rebalances, memoryRebalance{partitions: partitions})
+	}
+
+	return nil
+}
+
+// Assign simulates a rebalance handing the partitions to this consumer with the next ReadMessage.
+func (mc *MemoryConsumerClient) Assign(partitions ...kafka.TopicPartition) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.rebalances = append(mc.rebalances, memoryRebalance{partitions: partitions})
+	mc.wakeUp()
+}
+
+// Revoke simulates a rebalance taking the partitions away with the next ReadMessage. With lost set
+// they are reported as already owned by someone else.
+func (mc *MemoryConsumerClient) Revoke(lost bool, partitions ...kafka.TopicPartition) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.rebalances = append(mc.rebalances, memoryRebalance{partitions: partitions, revoke: true, lost: lost})
+	mc.wakeUp()
+}
+
+// FailNextRead makes the next len(errs) ReadMessage calls return the given errors in order.
+func (mc *MemoryConsumerClient) FailNextRead(errs ...error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.readErrs = append(mc.readErrs, errs...)
+}
+
+// FailNextCommit makes the next len(errs) CommitOffsets calls return the given errors in order.
+func (mc *MemoryConsumerClient) FailNextCommit(errs ...error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.commitErrs = append(mc.commitErrs, errs...)
+}
+
+func (mc *MemoryConsumerClient) ReadMessage(timeout time.Duration) (*kafka.Message, error) {
+	deadline := time.After(timeout)
+	for {
+		mc.rebalance()
+
+		msg, err := mc.next()
+		if msg != nil || err != nil {
+			return msg, err
+		}
+
+		select {
+		case <-mc.wake:
+		case <-deadline:
+			return nil, kafka.NewError(kafka.ErrTimedOut, "no message within the timeout", false)
+		}
+	}
+}
+
+// rebalance delivers the pending rebalances. The callbacks run without the lock, they commit.
+func (mc *MemoryConsumerClient) rebalance() {
+	for {
+		mc.mu.Lock()
+		if len(mc.rebalances) == 0 {
+			mc.mu.Unlock()
+			return
+		}
+		next := mc.rebalances[0]
+		mc.rebalances = mc.rebalances[1:]
+		onAssigned, onRevoked := mc.onAssigned, mc.onRevoked
+		mc.mu.Unlock()
+
+		if next.revoke {
+			onRevoked(next.partitions, next.lost)
+			mc.unassign(next.partitions)
+			continue
+		}
+
+		onAssigned(next.partitions)
+		mc.assign(next.partitions)
+	}
+}
+
+func (mc *MemoryConsumerClient) assign(partitions []kafka.TopicPartition) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	for _, tp := range partitions {
+		p := partitionOf(tp)
+		mc.assigned[p] = true
+		mc.position[p] = max(mc.committed[p], 0)
+	}
+}
+
+func (mc *MemoryConsumerClient) unassign(partitions []kafka.TopicPartition) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	for _, tp := range partitions {
+		delete(mc.assigned, partitionOf(tp))
+	}
+}
+
+// next returns the next unread message of the assigned partitions, in topic and partition order.
+func (mc *MemoryConsumerClient) next() (*kafka.Message, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if len(mc.readErrs) > 0 {
+		err := mc.readErrs[0]
+		mc.readErrs = mc.readErrs[1:]
+		return nil, err
+	}
+
+	for _, p := range mc.sortedAssignment() {
+		if position := mc.position[p]; int(position) < len(mc.messages[p]) {
+			mc.position[p] = position + 1
+			return mc.messages[p][position], nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (mc *MemoryConsumerClient) sortedAssignment() []partition {
+	partitions := make([]partition, 0, len(mc.assigned))
+	for p := range mc.assigned {
+		partitions = append(partitions, p)
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		if partitions[i].topic != partitions[j].topic {
+			return partitions[i].topic < partitions[j].topic
+		}
+		return partitions[i].partition < partitions[j].partition
+	})
+
+	return partitions
+}
+
+func (mc *MemoryConsumerClient) CommitOffsets(offsets []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if len(mc.commitErrs) > 0 {
+		err := mc.commitErrs[0]
+		mc.commitErrs = mc.commitErrs[1:]
+		return nil, err
+	}
+
+	committed := append([]kafka.TopicPartition(nil), offsets...)
+	for _, tp := range committed {
+		mc.committed[partitionOf(tp)] = tp.Offset
+	}
+	mc.commits = append(mc.commits, committed)
+
+	return committed, nil
+}
+
+func (mc *MemoryConsumerClient) Committed(partitions []kafka.TopicPartition, _ time.Duration) ([]kafka.TopicPartition, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	committed := make([]kafka.TopicPartition, 0, len(partitions))
+	for _, tp := range partitions {
+		tp.Offset = kafka.OffsetInvalid
+		if offset, ok := mc.committed[partitionOf(tp)]; ok {
+			tp.Offset = offset
+		}
+		committed = append(committed, tp)
+	}
+
+	return committed, nil
+}
+
+func (mc *MemoryConsumerClient) Position(partitions []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	positions := make([]kafka.TopicPartition, 0, len(partitions))
+	for _, tp := range partitions {
+		tp.Offset = kafka.OffsetInvalid
+		if p := partitionOf(tp); mc.assigned[p] {
+			tp.Offset = mc.position[p]
+		}
+		positions = append(positions, tp)
+	}
+
+	return positions, nil
+}
+
+func (mc *MemoryConsumerClient) WatermarkOffsets(topic string, partitionId int32, _ time.Duration) (int64, int64, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	return 0, int64(len(mc.messages[partition{topic: topic, partition: partitionId}])), nil
+}
+
+// Close revokes the assignment like leaving the group does.
+func (mc *MemoryConsumerClient) Close() error {
+	mc.mu.Lock()
+	var partitions []kafka.TopicPartition
+	for _, p := range mc.sortedAssignment() {
+		partitions = append(partitions, p.topicPartition())
+	}
+	onRevoked := mc.onRevoked
+	mc.closed = true
+	mc.mu.Unlock()
+
+	if len(partitions) > 0 && onRevoked != nil {
+		onRevoked(partitions, false)
+		mc.unassign(partitions)
+	}
+
+	return nil
+}
+
+// CommittedOffset returns the committed offset of the partition, kafka.OffsetInvalid if there is none.
+func (mc *MemoryConsumerClient) CommittedOffset(topic string, partitionId int32) kafka.Offset {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if offset, ok := mc.committed[partition{topic: topic, partition: partitionId}]; ok {
+		return offset
+	}
+	return kafka.OffsetInvalid
+}
+
+// Commits returns every successful CommitOffsets call in order.
+func (mc *MemoryConsumerClient) Commits() [][]kafka.TopicPartition {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	return append([][]kafka.TopicPartition(nil), mc.commits...)
+}
+
+// Closed reports whether Close has been called.
+func (mc *MemoryConsumerClient) Closed() bool {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	return mc.closed
+}
+
+// PartitionReaders returns a factory of PartitionReaders over the partitions of mc, for tests of replays
+// and the dead letter queue. The readers see every produced message and leave the consumer state alone.
+func (mc *MemoryConsumerClient) PartitionReaders() PartitionReaderFactory {
+	return func() (PartitionReader, error) {
+		return &memoryPartitionReader{
+			client:   mc,
+			position: make(map[partition]kafka.Offset),
+			paused:   make(map[partition]bool),
+		}, nil
+	}
+}
+
+type memoryPartitionReader struct {
+	client   *MemoryConsumerClient
+	mu       sync.Mutex
+	position map[partition]kafka.Offset
+	paused   map[partition]bool
+}
+
+func (r *memoryPartitionReader) Partitions(topic string, _ time.Duration) ([]int32, error) {
+	r.client.mu.Lock()
+	defer r.client.mu.Unlock()
+
+	var partitions []int32
+	for p := range r.client.messages {
+		if p.topic == topic {
+			partitions = append(partitions, p.partition)
+		}
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+
+	return partitions, nil
+}
+
+func (r *memoryPartitionReader) OffsetsForTimes(times []kafka.TopicPartition, _ time.Duration) ([]kafka.TopicPartition, error) {
+	r.client.mu.Lock()
+	defer r.client.mu.Unlock()
+
+	found := make([]kafka.TopicPartition, 0, len(times))
+	for _, tp := range times {
+		at := time.UnixMilli(int64(tp.Offset))
+		tp.Offset = kafka.OffsetEnd
+		for _, msg := range r.client.messages[partitionOf(tp)] {
+			if !msg.Timestamp.Before(at) {
+				tp.Offset = msg.TopicPartition.Offset
+				break
+			}
+		}
+		found = append(found, tp)
+	}
+
+	return found, nil
+}
+
+func (r *memoryPartitionReader) WatermarkOffsets(topic string, partitionId int32, timeout time.Duration) (int64, int64, error) {
+	return r.client.WatermarkOffsets(topic, partitionId, timeout)
+}
+
+func (r *memoryPartitionReader) Assign(partitions []kafka.TopicPartition) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	clear(r.position)
+	clear(r.paused)
+	for _, tp := range partitions {
+		r.position[partitionOf(tp)] = max(tp.Offset, 0)
+	}
+
+	return nil
+}
+
+func (r *memoryPartitionReader) Pause(partitions []kafka.TopicPartition) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, tp := range partitions {
+		r.paused[partitionOf(tp)] = true
+	}
+
+	return nil
+}
+
+func (r *memoryPartitionReader) Position(partitions []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	positions := make([]kafka.TopicPartition, 0, len(partitions))
+	for _, tp := range partitions {
+		tp.Offset = kafka.OffsetInvalid
+		if position, ok := r.position[partitionOf(tp)]; ok {
+			tp.Offset = position
+		}
+		positions = append(positions, tp)
+	}
+
+	return positions, nil
+}
+
+// ReadMessage returns the next unread message of the assigned partitions that are not paused,
+// in topic and partition order.
+func (r *memoryPartitionReader) ReadMessage(timeout time.Duration) (*kafka.Message, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		if msg := r.next(); msg != nil {
+			return msg, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, kafka.NewError(kafka.ErrTimedOut, "no message within the timeout", false)
+		}
+		time.Sleep(min(10*time.Millisecond, timeout))
+	}
+}
+
+func (r *memoryPartitionReader) next() *kafka.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.client.mu.Lock()
+	defer r.client.mu.Unlock()
+
+	partitions := make([]partition, 0, len(r.position))
+	for p := range r.position {
+		if !r.paused[p] {
+			partitions = append(partitions, p)
+		}
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		if partitions[i].topic != partitions[j].topic {
+			return partitions[i].topic < partitions[j].topic
+		}
+		return partitions[i].partition < partitions[j].partition
+	})
+
+	for _, p := range partitions {
+		if position := r.position[p]; int(position) < len(r.client.messages[p]) {
+			r.position[p] = position + 1
+			return r.client.messages[p][position]
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryPartitionReader) Close() error {
+	return nil
+}
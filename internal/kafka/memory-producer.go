@@ -0,0 +1,53 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+)
+
+type ProducedMessage struct {
+	Topic string
+	Key   string
+	Value []byte
+}
+
+// MemoryProducer keeps produced messages in memory. It is meant for tests of code
+// that depends on a producer and does not talk to a broker.
+type MemoryProducer struct {
+	mu       sync.Mutex
+	messages []ProducedMessage
+	failures []error
+}
+
+func NewMemoryProducer() *MemoryProducer {
+	return &MemoryProducer{}
+}
+
+func (mp *MemoryProducer) Produce(_ context.Context, topic, key string, value []byte) error {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	if len(mp.failures) > 0 {
+		err := mp.failures[0]
+		mp.failures = mp.failures[1:]
+		return err
+	}
+
+	mp.messages = append(mp.messages, ProducedMessage{Topic: topic, Key: key, Value: value})
+	return nil
+}
+
+// FailNext makes the next len(errs) Produce calls return the given errors in order.
+func (mp *MemoryProducer) FailNext(errs ...error) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.failures = append(mp.failures, errs...)
+}
+
+func (mp *MemoryProducer) Messages() []ProducedMessage {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	return append([]ProducedMessage(nil), mp.messages...)
+}
@@ -0,0 +1,145 @@
+package kafka
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"github.com/linkedin/goavro/v2"
+	"project-service/internal/lib/schemaregistry"
+	"sync"
+)
+
+// MemorySchemaRegistry is a SchemaRegistry kept in memory, the schema source for tests without
+// a registry. Together with a MemoryConsumerClient it lets the whole consume path run offline.
+type MemorySchemaRegistry struct {
+	mu       sync.Mutex
+	schemas  []schemaregistry.Schema
+	failures []error
+}
+
+func NewMemorySchemaRegistry() *MemorySchemaRegistry {
+	return &MemorySchemaRegistry{}
+}
+
+func (mr *MemorySchemaRegistry) LatestSchema(_ context.Context, subject string) (schemaregistry.Schema, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if err := mr.failure(); err != nil {
+		return schemaregistry.Schema{}, err
+	}
+
+	for i := len(mr.schemas) - 1; i >= 0; i-- {
+		if mr.schemas[i].Subject == subject {
+			return mr.schemas[i], nil
+		}
+	}
+
+	return schemaregistry.Schema{}, fmt.Errorf("subject %s: %w", subject, schemaregistry.ErrNotFound)
+}
+
+func (mr *MemorySchemaRegistry) SchemaById(_ context.Context, id int) (string, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if err := mr.failure(); err != nil {
+		return "", err
+	}
+
+	for _, schema := range mr.schemas {
+		if schema.ID == id {
+			return schema.Schema, nil
+		}
+	}
+
+	return "", fmt.Errorf("schema %d: %w", id, schemaregistry.ErrNotFound)
+}
+
+// Register adds schema as the latest version of subject. Registering a schema again returns its id.
+func (mr *MemorySchemaRegistry) Register(_ context.Context, subject, schema string) (int, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if err := mr.failure(); err != nil {
+		return 0, err
+	}
+
+	return mr.register(subject, schema)
+}
+
+func (mr *MemorySchemaRegistry) register(subject, schema string) (int, error) {
+	normalized, err := normalizeSchema(schema)
+	if err != nil {
+		return 0, err
+	}
+
+	id := 0
+	version := 1
+	for _, existing := range mr.schemas {
+		if other, err := normalizeSchema(existing.Schema); err == nil && other == normalized {
+			if existing.Subject == subject {
+				return existing.ID, nil
+			}
+			id = existing.ID
+		}
+		if existing.Subject == subject {
+			version = existing.Version + 1
+		}
+	}
+	if id == 0 {
+		id = len(mr.schemas) + 1
+	}
+
+	mr.schemas = append(mr.schemas, schemaregistry.Schema{Subject: subject, Version: version, ID: id, Schema: schema})
+	return id, nil
+}
+
+// FailNext makes the next len(errs) calls return the given errors in order.
+func (mr *MemorySchemaRegistry) FailNext(errs ...error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.failures = append(mr.failures, errs...)
+}
+
+func (mr *MemorySchemaRegistry) failure() error {
+	if len(mr.failures) == 0 {
+		return nil
+	}
+
+	err := mr.failures[0]
+	mr.failures = mr.failures[1:]
+	return err
+}
+
+// Encode registers schema under the value subject of topic and returns native in the Confluent wire
+// format, the way a producer of topic would write it.
+func (mr *MemorySchemaRegistry) Encode(topic, schema string, native interface{}) ([]byte, error) {
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	mr.mu.Lock()
+	id, err := mr.register(topic+"-value", codec.Schema())
+	mr.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := make([]byte, 5)
+	prefix[0] = confluentMagicByte
+	binary.BigEndian.PutUint32(prefix[1:], uint32(id))
+
+	return codec.BinaryFromNative(prefix, native)
+}
+
+// normalizeSchema returns the Avro parsing canonical form, equal for schemas that differ only in formatting.
+func normalizeSchema(schema string) (string, error) {
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		return "", err
+	}
+
+	return codec.CanonicalSchema(), nil
+}
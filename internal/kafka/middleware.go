@@ -0,0 +1,113 @@
+package kafka
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+const traceparentHeader = "traceparent"
+
+var (
+	consumedMessages       = expvar.NewMap("kafka_consumed_messages_total")
+	consumeDurationSeconds = expvar.NewMap("kafka_consume_duration_seconds_total")
+)
+
+type traceContextKey struct{}
+
+var traceIdContextKey = traceContextKey{}
+
+// TraceIdFromContext returns the W3C trace id propagated with the message being handled.
+func TraceIdFromContext(ctx context.Context) (string, bool) {
+	traceId, ok := ctx.Value(traceIdContextKey).(string)
+	return traceId, ok
+}
+
+// LoggingMiddleware logs the outcome of every handled message.
+func LoggingMiddleware(log *slog.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg *Message) (Result, error) {
+			start := time.Now()
+			result, err := next(ctx, msg)
+
+			attrs := []any{
+				"topic", msg.Topic(),
+				"partition", msg.TopicPartition.Partition,
+				"offset", msg.TopicPartition.Offset,
+				"attempt", msg.Attempt,
+				"result", result.String(),
+				"duration", time.Since(start),
+			}
+			if traceId, ok := TraceIdFromContext(ctx); ok {
+				attrs = append(attrs, "trace_id", traceId)
+			}
+
+			if err != nil {
+				log.Warn("Handled message", append(attrs, "error", err)...)
+			} else {
+				log.Info("Handled message", attrs...)
+			}
+
+			return result, err
+		}
+	}
+}
+
+// MetricsMiddleware counts handled messages per topic and result and sums up the handling time per topic.
+func MetricsMiddleware() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg *Message) (Result, error) {
+			start := time.Now()
+			result, err := next(ctx, msg)
+
+			consumedMessages.Add(msg.Topic()+"."+result.String(), 1)
+			consumeDurationSeconds.AddFloat(msg.Topic(), time.Since(start).Seconds())
+
+			return result, err
+		}
+	}
+}
+
+// TracingMiddleware puts the trace id of the W3C traceparent header into the handler context.
+func TracingMiddleware() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg *Message) (Result, error) {
+			if traceparent, ok := msg.Header(traceparentHeader); ok {
+				// version-traceid-parentid-flags
+				parts := strings.Split(traceparent, "-")
+				if len(parts) == 4 && len(parts[1]) == 32 {
+					ctx = context.WithValue(ctx, traceIdContextKey, parts[1])
+				}
+			}
+
+			return next(ctx, msg)
+		}
+	}
+}
+
+// RecoveryMiddleware turns a panicking handler into a dead letter.
+func RecoveryMiddleware(log *slog.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg *Message) (result Result, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Error(
+						"Panic while handling message",
+						"topic", msg.Topic(),
+						"partition", msg.TopicPartition.Partition,
+						"offset", msg.TopicPartition.Offset,
+						"panic", r,
+						"stack", string(debug.Stack()),
+					)
+					result, err = ResultDeadLetter, fmt.Errorf("panic while handling message: %v", r)
+				}
+			}()
+
+			return next(ctx, msg)
+		}
+	}
+}
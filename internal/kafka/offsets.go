@@ -0,0 +1,173 @@
+package kafka
+
+import (
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"sync"
+)
+
+// offsetTracker follows the messages handed to workers per partition. Messages finish out of order,
+// but a partition is only committed up to its lowest message that is still being handled,
+// so nothing unfinished is ever skipped by a commit.
+// Every assignment of a partition gets a new epoch. Messages carry the epoch they were read in, so
+// those of a revoked assignment are not handled or finished any more, even if the partition comes back.
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[partition]*partitionOffsets
+	epoch      uint64
+}
+
+type partitionOffsets struct {
+	epoch     uint64
+	pending   []kafka.Offset // handed to workers and not finished, in read order
+	finished  map[kafka.Offset]bool
+	next      kafka.Offset // everything before it is finished
+	committed kafka.Offset
+	running   int // messages whose handler or DLQ send is in progress
+	revoked   bool
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[partition]*partitionOffsets)}
+}
+
+// track records a message handed to the workers and returns the epoch of its partition's assignment.
+func (t *offsetTracker) track(tp kafka.TopicPartition) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.partitions[partitionOf(tp)]
+	if !ok {
+		t.epoch++
+		p = &partitionOffsets{
+			epoch:     t.epoch,
+			finished:  make(map[kafka.Offset]bool),
+			next:      kafka.OffsetInvalid,
+			committed: kafka.OffsetInvalid,
+		}
+		t.partitions[partitionOf(tp)] = p
+	}
+	p.pending = append(p.pending, tp.Offset)
+
+	return p.epoch
+}
+
+// current returns the state of tp's partition if epoch is its assignment, nil otherwise.
+func (t *offsetTracker) current(tp kafka.TopicPartition, epoch uint64) *partitionOffsets {
+	p, ok := t.partitions[partitionOf(tp)]
+	if !ok || p.epoch != epoch {
+		return nil
+	}
+
+	return p
+}
+
+// begin reports whether the message at tp, read in epoch, may still be worked on and counts it
+// as running until end is called. It reports false once its partition is revoked.
+func (t *offsetTracker) begin(tp kafka.TopicPartition, epoch uint64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.current(tp, epoch)
+	if p == nil || p.revoked {
+		return false
+	}
+	p.running++
+
+	return true
+}
+
+func (t *offsetTracker) end(tp kafka.TopicPartition, epoch uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if p := t.current(tp, epoch); p != nil {
+		p.running--
+	}
+}
+
+// revoke keeps messages of partitions from being worked on from now on.
+func (t *offsetTracker) revoke(partitions []kafka.TopicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, tp := range partitions {
+		if p, ok := t.partitions[partitionOf(tp)]; ok {
+			p.revoked = true
+		}
+	}
+}
+
+// running reports whether a message of partitions is being worked on.
+func (t *offsetTracker) running(partitions []kafka.TopicPartition) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, tp := range partitions {
+		if p, ok := t.partitions[partitionOf(tp)]; ok && p.running > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// finish marks the message at tp, read in epoch, as handled and reports whether its partition can be
+// committed further. Revoked partitions are left to the revocation to commit, lost ones must not be.
+func (t *offsetTracker) finish(tp kafka.TopicPartition, epoch uint64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.current(tp, epoch)
+	if p == nil {
+		return false
+	}
+
+	p.finished[tp.Offset] = true
+	advanced := false
+	for len(p.pending) > 0 && p.finished[p.pending[0]] {
+		delete(p.finished, p.pending[0])
+		p.next = p.pending[0] + 1
+		p.pending = p.pending[1:]
+		advanced = true
+	}
+
+	return advanced && !p.revoked
+}
+
+// uncommitted returns the offsets to commit for those of partitions that have finished messages since their last commit.
+func (t *offsetTracker) uncommitted(partitions []kafka.TopicPartition) []kafka.TopicPartition {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var offsets []kafka.TopicPartition
+	for _, tp := range partitions {
+		p, ok := t.partitions[partitionOf(tp)]
+		if ok && p.next > p.committed {
+			topic := *tp.Topic
+			offsets = append(offsets, kafka.TopicPartition{Topic: &topic, Partition: tp.Partition, Offset: p.next})
+		}
+	}
+
+	return offsets
+}
+
+func (t *offsetTracker) committed(offsets []kafka.TopicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, tp := range offsets {
+		if p, ok := t.partitions[partitionOf(tp)]; ok && tp.Error == nil && tp.Offset > p.committed {
+			p.committed = tp.Offset
+		}
+	}
+}
+
+// forget drops the state of partitions that are no longer assigned.
+func (t *offsetTracker) forget(partitions []kafka.TopicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, tp := range partitions {
+		delete(t.partitions, partitionOf(tp))
+	}
+}
@@ -0,0 +1,80 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"log/slog"
+	"project-service/internal/config"
+)
+
+type KafkaProducer struct {
+	log      *slog.Logger
+	producer *kafka.Producer
+}
+
+func NewKafkaProducer(log *slog.Logger, cfg *config.Config) *KafkaProducer {
+	producer, err := kafka.NewProducer(&kafka.ConfigMap{
+		"bootstrap.servers":  cfg.KafkaHost,
+		"acks":               "all",
+		"enable.idempotence": true,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("Error creating kafka producer %v", err))
+	}
+
+	kp := &KafkaProducer{
+		log:      log,
+		producer: producer,
+	}
+	go kp.logEvents()
+
+	return kp
+}
+
+// Produce waits for the broker to acknowledge the message.
+// Messages are keyed so that all events of one project land in the same partition.
+func (kp *KafkaProducer) Produce(ctx context.Context, topic, key string, value []byte) error {
+	return kp.produce(ctx, &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Key:            []byte(key),
+		Value:          value,
+	})
+}
+
+func (kp *KafkaProducer) produce(ctx context.Context, msg *kafka.Message) error {
+	topic := *msg.TopicPartition.Topic
+
+	deliveryChan := make(chan kafka.Event, 1)
+	err := kp.producer.Produce(msg, deliveryChan)
+	if err != nil {
+		return fmt.Errorf("produce message to topic %s: %w", topic, err)
+	}
+
+	select {
+	case e := <-deliveryChan:
+		msg, ok := e.(*kafka.Message)
+		if !ok {
+			return fmt.Errorf("unexpected delivery event for topic %s: %v", topic, e)
+		}
+		if msg.TopicPartition.Error != nil {
+			return fmt.Errorf("deliver message to topic %s: %w", topic, msg.TopicPartition.Error)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (kp *KafkaProducer) Close() {
+	kp.producer.Flush(5000)
+	kp.producer.Close()
+}
+
+func (kp *KafkaProducer) logEvents() {
+	for e := range kp.producer.Events() {
+		if err, ok := e.(kafka.Error); ok {
+			kp.log.Error("Kafka producer error", "error", err)
+		}
+	}
+}
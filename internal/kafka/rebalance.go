@@ -0,0 +1,72 @@
+package kafka
+
+import (
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"time"
+)
+
+type partition struct {
+	topic     string
+	partition int32
+}
+
+func partitionOf(tp kafka.TopicPartition) partition {
+	return partition{topic: *tp.Topic, partition: tp.Partition}
+}
+
+func (p partition) topicPartition() kafka.TopicPartition {
+	topic := p.topic
+	return kafka.TopicPartition{Topic: &topic, Partition: p.partition}
+}
+
+func (kc *KafkaConsumer) assigned(partitions []kafka.TopicPartition) {
+	kc.log.Info("Partitions assigned", "partitions", partitionNames(partitions))
+	kc.health.assigned(partitions)
+}
+
+// revoked is called from ReadMessage, so no message is dispatched meanwhile. Queued messages of the
+// partitions are dropped, those being handled get the revoke timeout to finish before the partitions
+// are committed and given up. Dropped and unfinished messages are redelivered to the next owner.
+func (kc *KafkaConsumer) revoked(partitions []kafka.TopicPartition, lost bool) {
+	kc.offsets.revoke(partitions)
+	if !kc.waitRunning(partitions) {
+		kc.log.Warn("Revoked partitions still have messages being handled, giving them up",
+			"partitions", partitionNames(partitions), "timeout", kc.cfg.RevokeTimeout)
+	}
+
+	if lost {
+		// the partitions may already belong to someone else, committing now could rewind them
+		kc.log.Warn("Partitions lost", "partitions", partitionNames(partitions))
+	} else {
+		kc.log.Info("Partitions revoked", "partitions", partitionNames(partitions))
+		kc.commit(partitions)
+	}
+	kc.offsets.forget(partitions)
+	kc.health.revoked(partitions)
+}
+
+// revokePollInterval is how often waitRunning checks the revoked partitions.
+const revokePollInterval = 10 * time.Millisecond
+
+// waitRunning waits up to the revoke timeout for the messages of partitions being handled and reports
+// whether they finished. Lost partitions are waited for as well: their handlers may still be writing.
+func (kc *KafkaConsumer) waitRunning(partitions []kafka.TopicPartition) bool {
+	deadline := time.Now().Add(kc.cfg.RevokeTimeout)
+	for kc.offsets.running(partitions) {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(revokePollInterval)
+	}
+
+	return true
+}
+
+func partitionNames(partitions []kafka.TopicPartition) []string {
+	names := make([]string, 0, len(partitions))
+	for _, tp := range partitions {
+		names = append(names, partitionName(tp))
+	}
+
+	return names
+}
@@ -0,0 +1,429 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"log/slog"
+	"project-service/internal/config"
+	"project-service/internal/lib/backoff"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// ReplayHandled is the outcome of replayed messages their handler acknowledged.
+	ReplayHandled = "handled"
+	// ReplayFailed is the outcome of replayed messages that could not be decoded or handled.
+	ReplayFailed = "failed"
+
+	// replayMaxReported bounds the messages listed in a ReplayReport, the outcomes are counted in full.
+	replayMaxReported = 1000
+	// replayBatchSize is the number of messages passed to a ReplayFunc or PreviewFunc at once.
+	replayBatchSize = 100
+	replayTimeout   = 5 * time.Second
+)
+
+var (
+	ErrReplayInProgress           = errors.New("another replay is in progress")
+	ErrReplayUnknownTopic         = errors.New("topic is not consumed")
+	ErrReplayUnknownPartition     = errors.New("partition does not exist")
+	ErrReplayDryRunUnsupported    = errors.New("dry run is not supported for the topic")
+	ErrReplayReprocessUnsupported = errors.New("reprocessing is not supported for the topic")
+)
+
+// ReplayRequest selects what to replay: the partitions of Topic, all of them when empty, from the first
+// message at or after From, or from FromOffset when From is zero, up to the end of each partition
+// as of the start of the replay. With Reprocess, messages that were handled before are handled again
+// instead of being acknowledged as duplicates.
+type ReplayRequest struct {
+	Topic      string
+	Partitions []int32
+	From       time.Time
+	FromOffset int64
+	DryRun     bool
+	Reprocess  bool
+}
+
+// ReplayedPartition is the range of a partition a replay read, ToOffset is exclusive.
+type ReplayedPartition struct {
+	Partition  int32
+	FromOffset int64
+	ToOffset   int64
+	Messages   int
+}
+
+// ReplayedMessage is a replayed message worth a look: one that failed or, in a dry run, one that would change something.
+type ReplayedMessage struct {
+	Partition int32
+	Offset    int64
+	Key       string
+	Outcome   string
+	Change    string
+}
+
+// ReplayReport counts the outcomes of a replay: those of the replay or preview handler of the topic,
+// e.g. applied, reapplied, duplicate or skipped, or ReplayHandled for topics without one, and ReplayFailed.
+type ReplayReport struct {
+	Topic      string
+	DryRun     bool
+	Reprocess  bool
+	Partitions []ReplayedPartition
+	Outcomes   map[string]int
+	Messages   []ReplayedMessage
+	// Truncated is set when more messages were worth a look than the report lists
+	Truncated bool
+}
+
+// Replayer reads a range of a consumed topic again and passes it to the handlers of the topic. It does not
+// join the consumer group and commits nothing, so it runs next to the consumer and does not move its offsets.
+// Events that were applied before are acknowledged as duplicates unless the replay reprocesses them.
+// Replayed events are not ordered with the events the consumer handles at the same time: a replayed event
+// of a key may be applied after a newer one of the consumer. The state machine rejects the status regressions
+// this causes, but other events, as deploy payloads, overwrite newer ones, so replays of topics producers
+// still write to are best run while the consumer is stopped or the keys are idle.
+type Replayer struct {
+	log      *slog.Logger
+	registry *HandlerRegistry
+	decoder  Decoder
+	retry    config.ConsumerRetryConfig
+	readers  PartitionReaderFactory
+	// running allows one replay at a time
+	running sync.Mutex
+}
+
+func NewReplayer(
+	log *slog.Logger,
+	cfg *config.Config,
+	registry *HandlerRegistry,
+	decoder Decoder,
+	readers PartitionReaderFactory,
+) *Replayer {
+	return &Replayer{
+		log:      log,
+		registry: registry,
+		decoder:  decoder,
+		retry:    cfg.ConsumerRetry,
+		readers:  readers,
+	}
+}
+
+// Replay handles the selected messages in offset order. Topics with a replay handler are applied in
+// batches of replayBatchSize, others message by message through their handler; a failing batch or
+// message is retried like the consumer does and then reported instead of being dead-lettered.
+// A dry run reports what handling the range would change through a PreviewFunc of the topic, to which
+// it passes the messages in chunks of replayBatchSize. Reprocessing needs a replay handler, or a preview
+// handler for dry runs.
+func (r *Replayer) Replay(ctx context.Context, req ReplayRequest) (ReplayReport, error) {
+	handler, ok := r.registry.handler(req.Topic)
+	if !ok {
+		return ReplayReport{}, fmt.Errorf("%w: %s", ErrReplayUnknownTopic, req.Topic)
+	}
+	var preview PreviewFunc
+	if req.DryRun {
+		newPreview, ok := r.registry.preview(req.Topic)
+		if !ok {
+			return ReplayReport{}, fmt.Errorf("%w: %s", ErrReplayDryRunUnsupported, req.Topic)
+		}
+		preview = newPreview()
+	}
+	replay, ok := r.registry.replay(req.Topic)
+	if req.Reprocess && !req.DryRun && !ok {
+		return ReplayReport{}, fmt.Errorf("%w: %s", ErrReplayReprocessUnsupported, req.Topic)
+	}
+
+	if !r.running.TryLock() {
+		return ReplayReport{}, ErrReplayInProgress
+	}
+	defer r.running.Unlock()
+
+	reader, err := r.readers()
+	if err != nil {
+		return ReplayReport{}, err
+	}
+	defer reader.Close()
+
+	ranges, err := r.ranges(reader, req)
+	if err != nil {
+		return ReplayReport{}, err
+	}
+
+	report := ReplayReport{
+		Topic:      req.Topic,
+		DryRun:     req.DryRun,
+		Reprocess:  req.Reprocess,
+		Partitions: ranges,
+		Outcomes:   make(map[string]int),
+	}
+	r.log.Info("Replay started", "topic", req.Topic, "partitions", ranges, "dryRun", req.DryRun, "reprocess", req.Reprocess)
+
+	var pending []*Message
+	flush := func() error {
+		msgs := pending
+		pending = nil
+		if req.DryRun {
+			return r.preview(ctx, preview, msgs, req.Reprocess, &report)
+		}
+		r.replayBatch(ctx, replay, msgs, req.Reprocess, &report)
+		return nil
+	}
+	err = r.read(ctx, reader, req.Topic, &report, func(msg *kafka.Message) error {
+		native, err := r.decoder.Decode(req.Topic, msg.Value)
+		if err != nil {
+			report.add(msg, Preview{Outcome: ReplayFailed, Change: err.Error()})
+			return nil
+		}
+
+		replayed := &Message{Message: msg, Native: native, Attempt: 1}
+		if req.DryRun || replay != nil {
+			pending = append(pending, replayed)
+			if len(pending) >= replayBatchSize {
+				return flush()
+			}
+			return nil
+		}
+
+		if err := r.handle(ctx, handler, replayed); err != nil {
+			report.add(msg, Preview{Outcome: ReplayFailed, Key: r.registry.key(req.Topic, native), Change: err.Error()})
+			return nil
+		}
+		report.add(msg, Preview{Outcome: ReplayHandled, Key: r.registry.key(req.Topic, native)})
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	if len(pending) > 0 {
+		if err := flush(); err != nil {
+			return report, err
+		}
+	}
+
+	r.log.Info("Replay finished", "topic", req.Topic, "dryRun", req.DryRun, "reprocess", req.Reprocess, "outcomes", report.Outcomes)
+
+	return report, nil
+}
+
+// preview reports what handling msgs would do.
+func (r *Replayer) preview(ctx context.Context, preview PreviewFunc, msgs []*Message, reprocess bool, report *ReplayReport) error {
+	previews, err := preview(ctx, msgs, reprocess)
+	if err != nil {
+		return fmt.Errorf("preview %d messages: %w", len(msgs), err)
+	}
+	if len(previews) != len(msgs) {
+		return fmt.Errorf("preview returned %d results for %d messages", len(previews), len(msgs))
+	}
+	for i, msg := range msgs {
+		report.add(msg.Message, previews[i])
+	}
+
+	return nil
+}
+
+// replayBatch applies msgs through the replay handler, retrying the whole batch with exponential backoff
+// like the consumer does. Once the attempts are used up every message of the batch is reported as failed.
+func (r *Replayer) replayBatch(ctx context.Context, replay ReplayFunc, msgs []*Message, reprocess bool, report *ReplayReport) {
+	maxAttempts := max(r.retry.MaxAttempts, 1)
+
+	for attempt := 1; ; attempt++ {
+		previews, err := replay(ctx, msgs, reprocess)
+		if err == nil && len(previews) != len(msgs) {
+			err = fmt.Errorf("replay returned %d results for %d messages", len(previews), len(msgs))
+		}
+		if err == nil {
+			for i, msg := range msgs {
+				report.add(msg.Message, previews[i])
+			}
+			return
+		}
+
+		if attempt < maxAttempts {
+			r.log.Warn("Replayed batch failed", "topic", msgs[0].Topic(), "messages", len(msgs), "attempt", attempt, "error", err)
+			select {
+			case <-time.After(backoff.Exponential(r.retry.InitialBackoff, r.retry.MaxBackoff, attempt)):
+				continue
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		}
+
+		for _, msg := range msgs {
+			report.add(msg.Message, Preview{Outcome: ReplayFailed, Key: r.registry.key(msg.Topic(), msg.Native), Change: err.Error()})
+		}
+		return
+	}
+}
+
+// ranges resolves the start of every selected partition; the end is its high watermark.
+func (r *Replayer) ranges(reader PartitionReader, req ReplayRequest) ([]ReplayedPartition, error) {
+	ids, err := reader.Partitions(req.Topic, replayTimeout)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[int32]bool)
+	for _, id := range ids {
+		existing[id] = true
+	}
+
+	partitions := req.Partitions
+	if len(partitions) == 0 {
+		for id := range existing {
+			partitions = append(partitions, id)
+		}
+		sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	}
+
+	starts := make(map[int32]int64)
+	var times []kafka.TopicPartition
+	for _, id := range partitions {
+		if !existing[id] {
+			return nil, fmt.Errorf("%w: %s/%d", ErrReplayUnknownPartition, req.Topic, id)
+		}
+		starts[id] = req.FromOffset
+		times = append(times, kafka.TopicPartition{Topic: &req.Topic, Partition: id, Offset: kafka.Offset(req.From.UnixMilli())})
+	}
+	if !req.From.IsZero() {
+		found, err := reader.OffsetsForTimes(times, replayTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("look up offsets for %s: %w", req.From, err)
+		}
+		for _, tp := range found {
+			if tp.Error != nil {
+				return nil, fmt.Errorf("look up offsets of %s for %s: %w", partitionName(tp), req.From, tp.Error)
+			}
+			starts[tp.Partition] = int64(tp.Offset)
+		}
+	}
+
+	ranges := make([]ReplayedPartition, 0, len(partitions))
+	for _, id := range partitions {
+		low, high, err := reader.WatermarkOffsets(req.Topic, id, replayTimeout)
+		if err != nil {
+			return nil, err
+		}
+
+		// OffsetsForTimes returns the logical end for partitions without a message since From
+		from := starts[id]
+		if from < 0 {
+			from = high
+		}
+		ranges = append(ranges, ReplayedPartition{Partition: id, FromOffset: min(max(from, low), high), ToOffset: high})
+	}
+
+	return ranges, nil
+}
+
+// read passes every message of the replayed ranges to fn, in offset order within a partition, and stops
+// at the first error fn returns.
+func (r *Replayer) read(ctx context.Context, reader PartitionReader, topic string, report *ReplayReport, fn func(msg *kafka.Message) error) error {
+	ends := make(map[int32]int64)
+	messages := make(map[int32]*int)
+	var assignment []kafka.TopicPartition
+	for i, p := range report.Partitions {
+		messages[p.Partition] = &report.Partitions[i].Messages
+		if p.FromOffset >= p.ToOffset {
+			continue
+		}
+		ends[p.Partition] = p.ToOffset
+		assignment = append(assignment, kafka.TopicPartition{Topic: &topic, Partition: p.Partition, Offset: kafka.Offset(p.FromOffset)})
+	}
+	if len(assignment) == 0 {
+		return nil
+	}
+	if err := reader.Assign(assignment); err != nil {
+		return err
+	}
+
+	for len(ends) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		msg, err := reader.ReadMessage(time.Second)
+		if err != nil {
+			if !isTimeout(err) {
+				return err
+			}
+			// the last offsets may be transaction markers or compacted away, they are never delivered
+			positions, err := reader.Position(assignment)
+			if err != nil {
+				return err
+			}
+			for _, tp := range positions {
+				if end, ok := ends[tp.Partition]; ok && tp.Offset >= 0 && int64(tp.Offset) >= end {
+					r.done(reader, ends, tp)
+				}
+			}
+			continue
+		}
+
+		end, ok := ends[msg.TopicPartition.Partition]
+		if !ok || int64(msg.TopicPartition.Offset) >= end {
+			continue
+		}
+		*messages[msg.TopicPartition.Partition]++
+		if err := fn(msg); err != nil {
+			return err
+		}
+		if int64(msg.TopicPartition.Offset)+1 >= end {
+			r.done(reader, ends, msg.TopicPartition)
+		}
+	}
+
+	return nil
+}
+
+// done stops reading a partition that has reached its end.
+func (r *Replayer) done(reader PartitionReader, ends map[int32]int64, tp kafka.TopicPartition) {
+	delete(ends, tp.Partition)
+	if err := reader.Pause([]kafka.TopicPartition{{Topic: tp.Topic, Partition: tp.Partition}}); err != nil {
+		r.log.Warn("Failed to pause replayed partition", "partition", partitionName(tp), "error", err)
+	}
+}
+
+// handle retries handler with exponential backoff like the consumer does.
+func (r *Replayer) handle(ctx context.Context, handler HandlerFunc, msg *Message) error {
+	maxAttempts := max(r.retry.MaxAttempts, 1)
+
+	for attempt := 1; ; attempt++ {
+		msg.Attempt = attempt
+		result, err := handler(ctx, msg)
+		if result == ResultAck {
+			return nil
+		}
+		if err == nil {
+			err = fmt.Errorf("message was not processed")
+		}
+		if result == ResultDeadLetter || attempt == maxAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff.Exponential(r.retry.InitialBackoff, r.retry.MaxBackoff, attempt)):
+		}
+	}
+}
+
+func (report *ReplayReport) add(msg *kafka.Message, preview Preview) {
+	report.Outcomes[preview.Outcome]++
+	if preview.Outcome == ReplayHandled || preview.Unchanged {
+		return
+	}
+	if len(report.Messages) >= replayMaxReported {
+		report.Truncated = true
+		return
+	}
+
+	report.Messages = append(report.Messages, ReplayedMessage{
+		Partition: msg.TopicPartition.Partition,
+		Offset:    int64(msg.TopicPartition.Offset),
+		Key:       preview.Key,
+		Outcome:   preview.Outcome,
+		Change:    preview.Change,
+	})
+}
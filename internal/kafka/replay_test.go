@@ -0,0 +1,168 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"project-service/internal/config"
+	"testing"
+)
+
+type valueDecoder struct{}
+
+func (valueDecoder) Decode(_ string, value []byte) (interface{}, error) {
+	return string(value), nil
+}
+
+// newReplayTestRegistry registers the topic events with a replay handler that applies every event
+// id once, like the project handlers do; e1 counts as processed before the replay.
+func newReplayTestRegistry() *HandlerRegistry {
+	registry := NewHandlerRegistry()
+	registry.Register("events", "", nil, func(ctx context.Context, msg *Message) (Result, error) {
+		return ResultAck, nil
+	})
+	registry.Register("plain", "", nil, func(ctx context.Context, msg *Message) (Result, error) {
+		return ResultAck, nil
+	})
+
+	processedBefore := map[string]bool{"e1": true}
+	processed := map[string]bool{"e1": true}
+	registry.HandleReplay(func(ctx context.Context, msgs []*Message, reprocess bool) ([]Preview, error) {
+		previews := make([]Preview, len(msgs))
+		for i, msg := range msgs {
+			id := msg.Native.(string)
+			switch {
+			case reprocess && processedBefore[id]:
+				delete(processedBefore, id)
+				previews[i] = Preview{Outcome: "reapplied"}
+			case processed[id]:
+				previews[i] = Preview{Outcome: "duplicate", Unchanged: true}
+			default:
+				previews[i] = Preview{Outcome: "applied"}
+			}
+			processed[id] = true
+		}
+		return previews, nil
+	}, "events")
+
+	return registry
+}
+
+func TestReplayReadsThroughPartitionReaders(t *testing.T) {
+	mc := NewMemoryConsumerClient()
+	for _, id := range []string{"e1", "e2", "e1"} {
+		mc.Produce("events", 0, nil, []byte(id))
+	}
+	mc.Produce("events", 1, nil, []byte("e3"))
+	mc.Produce("plain", 0, nil, []byte("p1"))
+
+	tests := []struct {
+		name     string
+		req      ReplayRequest
+		outcomes map[string]int
+		err      error
+	}{
+		{
+			name:     "duplicates are acknowledged",
+			req:      ReplayRequest{Topic: "events"},
+			outcomes: map[string]int{"applied": 2, "duplicate": 2},
+		},
+		{
+			name:     "reprocess applies processed events once more",
+			req:      ReplayRequest{Topic: "events", Reprocess: true},
+			outcomes: map[string]int{"applied": 2, "reapplied": 1, "duplicate": 1},
+		},
+		{
+			name:     "from offset",
+			req:      ReplayRequest{Topic: "events", Partitions: []int32{0}, FromOffset: 1},
+			outcomes: map[string]int{"applied": 1, "duplicate": 1},
+		},
+		{
+			name:     "topics without a replay handler are handled one by one",
+			req:      ReplayRequest{Topic: "plain"},
+			outcomes: map[string]int{ReplayHandled: 1},
+		},
+		{
+			name: "topics without a replay handler cannot be reprocessed",
+			req:  ReplayRequest{Topic: "plain", Reprocess: true},
+			err:  ErrReplayReprocessUnsupported,
+		},
+		{
+			name: "unknown partition",
+			req:  ReplayRequest{Topic: "events", Partitions: []int32{2}},
+			err:  ErrReplayUnknownPartition,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{ConsumerRetry: config.ConsumerRetryConfig{MaxAttempts: 1}}
+			replayer := NewReplayer(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg,
+				newReplayTestRegistry(), valueDecoder{}, mc.PartitionReaders())
+
+			report, err := replayer.Replay(context.Background(), tt.req)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if len(report.Outcomes) != len(tt.outcomes) {
+				t.Fatalf("got outcomes %v, want %v", report.Outcomes, tt.outcomes)
+			}
+			for outcome, count := range tt.outcomes {
+				if report.Outcomes[outcome] != count {
+					t.Fatalf("got outcomes %v, want %v", report.Outcomes, tt.outcomes)
+				}
+			}
+		})
+	}
+}
+
+func TestDryRunsPreviewInChunks(t *testing.T) {
+	mc := NewMemoryConsumerClient()
+	for i := 0; i < 2*replayBatchSize; i++ {
+		mc.Produce("events", 0, nil, []byte(fmt.Sprintf("e%d", i)))
+	}
+	// a duplicate of an event of the first chunk
+	mc.Produce("events", 0, nil, []byte("e0"))
+
+	registry := NewHandlerRegistry()
+	registry.Register("events", "", nil, func(ctx context.Context, msg *Message) (Result, error) {
+		return ResultAck, nil
+	})
+	var chunks []int
+	registry.HandlePreview(func() PreviewFunc {
+		seen := make(map[string]bool)
+		return func(ctx context.Context, msgs []*Message, reprocess bool) ([]Preview, error) {
+			chunks = append(chunks, len(msgs))
+			previews := make([]Preview, len(msgs))
+			for i, msg := range msgs {
+				id := msg.Native.(string)
+				previews[i] = Preview{Outcome: "applied"}
+				if seen[id] {
+					previews[i] = Preview{Outcome: "duplicate", Unchanged: true}
+				}
+				seen[id] = true
+			}
+			return previews, nil
+		}
+	}, "events")
+
+	cfg := &config.Config{ConsumerRetry: config.ConsumerRetryConfig{MaxAttempts: 1}}
+	replayer := NewReplayer(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, registry, valueDecoder{}, mc.PartitionReaders())
+
+	for run := 0; run < 2; run++ {
+		chunks = nil
+		report, err := replayer.Replay(context.Background(), ReplayRequest{Topic: "events", DryRun: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(chunks) != fmt.Sprint([]int{replayBatchSize, replayBatchSize, 1}) {
+			t.Fatalf("got chunks of %v messages", chunks)
+		}
+		// every dry run starts from the stored state again
+		if report.Outcomes["applied"] != 2*replayBatchSize || report.Outcomes["duplicate"] != 1 {
+			t.Fatalf("run %d: got outcomes %v", run, report.Outcomes)
+		}
+	}
+}
@@ -1,79 +1,391 @@
 package kafka
 
 import (
-	"encoding/json"
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/linkedin/goavro/v2"
-	"net/http"
+	"log/slog"
 	"project-service/internal/config"
+	"project-service/internal/dto"
+	"project-service/internal/events"
+	"project-service/internal/lib/schemaregistry"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
-// topic -> codec
-var schemasForThisService = map[string]*goavro.Codec{
-	"ProjectStatus": nil,
-	"NewZip":        nil,
-	"DeployPayload": nil,
+// topic -> schema owned by this service
+var eventSchemasForThisService = map[string]string{
+	dto.ProjectCreatedTopic:      events.ProjectEventSchema,
+	dto.ProjectUpdatedTopic:      events.ProjectEventSchema,
+	dto.ProjectDeletedTopic:      events.ProjectEventSchema,
+	dto.GenerationRequestedTopic: events.GenerationRequestSchema,
+	dto.DeployRequestedTopic:     events.DeployCommandSchema,
+	dto.UndeployRequestedTopic:   events.DeployCommandSchema,
+}
+
+const confluentMagicByte byte = 0
+
+type registeredSchema struct {
+	id    int
+	codec *goavro.Codec
+}
+
+// cachedSchema is a schema consumed messages were written with, looked up by its registry id.
+// Schemas are immutable in the registry, so cached entries never have to be refreshed.
+type cachedSchema struct {
+	id          int
+	codec       *goavro.Codec
+	resolutions map[string]schemaResolution // reader topic -> resolution
+}
+
+type schemaResolution struct {
+	resolver *schemaResolver // nil when writer and reader schemas are identical
+	err      error
+}
+
+type SchemaRegistry interface {
+	LatestSchema(ctx context.Context, subject string) (schemaregistry.Schema, error)
+	SchemaById(ctx context.Context, id int) (string, error)
+	Register(ctx context.Context, subject, schema string) (int, error)
 }
 
 type SchemaManager struct {
-	mu                sync.RWMutex
-	Schemas           map[string]*goavro.Codec
-	schemaRegistryURL string
+	log             *slog.Logger
+	mu              sync.RWMutex
+	readers         map[string]*goavro.Codec
+	eventSchemas    map[string]registeredSchema
+	schemasById     map[int]*cachedSchema
+	schemaVersions  map[string]map[int]int   // subject -> version -> id
+	latest          map[string]*cachedSchema // topic -> latest registered writer schema
+	incompatible    map[string]error         // subject -> why its schema cannot be read
+	unregistered    map[string]error         // subject -> why the schema of this service is not registered
+	registry        SchemaRegistry
+	textualFallback bool
+	refreshInterval time.Duration
 }
 
-func NewSchemaManager(cfg *config.Config) *SchemaManager {
+func NewSchemaManager(log *slog.Logger, cfg *config.Config, registry SchemaRegistry) *SchemaManager {
 	manager := &SchemaManager{
-		Schemas:           schemasForThisService,
-		schemaRegistryURL: cfg.SchemaRegistryUrl,
+		log:             log,
+		readers:         make(map[string]*goavro.Codec),
+		eventSchemas:    make(map[string]registeredSchema, len(eventSchemasForThisService)),
+		schemasById:     make(map[int]*cachedSchema),
+		schemaVersions:  make(map[string]map[int]int),
+		latest:          make(map[string]*cachedSchema),
+		incompatible:    make(map[string]error),
+		unregistered:    make(map[string]error),
+		registry:        registry,
+		textualFallback: cfg.AvroTextualFallback,
+		refreshInterval: cfg.SchemaRegistry.RefreshInterval,
 	}
 
-	manager.loadSchemasFromRegistry()
+	manager.registerEventSchemas()
 
 	return manager
 }
 
-func (sm *SchemaManager) loadSchemasFromRegistry() {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
+// Encode serializes native into the Confluent wire format of the schema registered for topic.
+func (sm *SchemaManager) Encode(topic string, native interface{}) ([]byte, error) {
+	sm.mu.RLock()
+	schema, ok := sm.eventSchemas[topic]
+	sm.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no registered schema for topic %s", topic)
+	}
 
-	for topic := range sm.Schemas {
-		schemaData, err := sm.fetchSchemaFromRegistry(topic)
-		if err != nil {
-			panic(fmt.Sprintf("Failed to load schema for topic %s: %v", topic, err))
-		}
+	buf := make([]byte, 5, 256)
+	buf[0] = confluentMagicByte
+	binary.BigEndian.PutUint32(buf[1:], uint32(schema.id))
 
+	return schema.codec.BinaryFromNative(buf, native)
+}
+
+// LoadSchemas takes the reader schemas of the consumed topics, the schemas messages are always decoded
+// into whatever version they were written with, and checks the latest registered versions against them.
+func (sm *SchemaManager) LoadSchemas(readerSchemas map[string]string) {
+	sm.mu.Lock()
+	for topic, schemaData := range readerSchemas {
 		codec, err := goavro.NewCodec(schemaData)
 		if err != nil {
 			panic(fmt.Sprintf("Failed to create codec for topic %s: %v", topic, err))
 		}
 
-		sm.Schemas[topic] = codec
-		fmt.Printf("Schema for topic %s successfully loaded from registry\n", topic)
+		sm.readers[topic] = codec
 	}
+	sm.mu.Unlock()
+
+	sm.refresh()
 }
 
-func (sm *SchemaManager) fetchSchemaFromRegistry(topic string) (string, error) {
-	schemaURL := fmt.Sprintf("%s/subjects/%s-value/versions/latest", sm.schemaRegistryURL, topic)
-	resp, err := http.Get(schemaURL)
+// Run periodically fetches the latest schema versions, so that incompatible evolution
+// is reported by Health before messages written with it arrive.
+func (sm *SchemaManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(sm.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sm.registerEventSchemas()
+			sm.refresh()
+		}
+	}
+}
+
+// Health fails while a consumed topic has a writer schema its reader schema cannot read, or a schema
+// of this service could not be registered.
+func (sm *SchemaManager) Health() error {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if len(sm.incompatible) == 0 && len(sm.unregistered) == 0 {
+		return nil
+	}
+
+	problems := make([]string, 0, len(sm.incompatible)+len(sm.unregistered))
+	for subject, err := range sm.incompatible {
+		problems = append(problems, fmt.Sprintf("%s: %v", subject, err))
+	}
+	for subject, err := range sm.unregistered {
+		problems = append(problems, fmt.Sprintf("%s: not registered: %v", subject, err))
+	}
+	sort.Strings(problems)
+
+	return errors.New(strings.Join(problems, "; "))
+}
+
+func (sm *SchemaManager) refresh() {
+	sm.mu.RLock()
+	topics := make([]string, 0, len(sm.readers))
+	for topic := range sm.readers {
+		topics = append(topics, topic)
+	}
+	sm.mu.RUnlock()
+
+	for _, topic := range topics {
+		cached, err := sm.fetchLatest(topic)
+		if err != nil {
+			sm.log.Warn("Failed to fetch latest schema", "subject", topic+"-value", "error", err)
+			continue
+		}
+
+		if _, err := sm.resolution(topic, cached); err == nil {
+			sm.mu.Lock()
+			delete(sm.incompatible, topic+"-value")
+			sm.mu.Unlock()
+		}
+	}
+}
+
+// fetchLatest fetches the latest schema registered for the values of topic and caches it.
+func (sm *SchemaManager) fetchLatest(topic string) (*cachedSchema, error) {
+	subject := topic + "-value"
+
+	latest, err := sm.registry.LatestSchema(context.Background(), subject)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", err
+	cached, err := sm.cacheSchema(latest.ID, latest.Schema)
+	if err != nil {
+		return nil, err
 	}
 
-	var schemaResp map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&schemaResp); err != nil {
-		return "", err
+	sm.mu.Lock()
+	if sm.schemaVersions[subject] == nil {
+		sm.schemaVersions[subject] = make(map[int]int)
 	}
+	sm.schemaVersions[subject][latest.Version] = latest.ID
+	sm.latest[topic] = cached
+	sm.mu.Unlock()
 
-	schema, ok := schemaResp["schema"].(string)
+	return cached, nil
+}
+
+// Decode turns a consumed message value into the native Avro representation of the topic's reader schema.
+// Values in the Confluent wire format are decoded with their writer schema and resolved against the
+// reader schema; anything else is treated as Avro JSON if the textual fallback is enabled. JSON carries
+// no schema id, so it is decoded with the latest schema registered for the topic and resolved the same way.
+func (sm *SchemaManager) Decode(topic string, value []byte) (interface{}, error) {
+	sm.mu.RLock()
+	_, ok := sm.readers[topic]
+	sm.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no schema loaded for topic %s", topic)
+	}
+
+	if len(value) >= 5 && value[0] == confluentMagicByte {
+		return sm.decodeBinary(topic, value)
+	}
+
+	if !sm.textualFallback {
+		return nil, fmt.Errorf("message from topic %s is not in the Confluent wire format", topic)
+	}
+
+	return sm.decodeTextual(topic, value)
+}
+
+func (sm *SchemaManager) decodeTextual(topic string, value []byte) (interface{}, error) {
+	sm.mu.RLock()
+	writer, ok := sm.latest[topic]
+	sm.mu.RUnlock()
 	if !ok {
-		return "", err
+		var err error
+		if writer, err = sm.fetchLatest(topic); err != nil {
+			return nil, fmt.Errorf("load latest writer schema of topic %s: %w", topic, err)
+		}
+	}
+
+	return sm.decodeWith(topic, writer, func(codec *goavro.Codec) (interface{}, []byte, error) {
+		return codec.NativeFromTextual(value)
+	})
+}
+
+func (sm *SchemaManager) decodeBinary(topic string, value []byte) (interface{}, error) {
+	id := int(binary.BigEndian.Uint32(value[1:5]))
+
+	cached, err := sm.schemaById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return sm.decodeWith(topic, cached, func(codec *goavro.Codec) (interface{}, []byte, error) {
+		return codec.NativeFromBinary(value[5:])
+	})
+}
+
+// decodeWith decodes a value with the writer schema and resolves it against the reader schema of topic.
+func (sm *SchemaManager) decodeWith(topic string, writer *cachedSchema, decode func(codec *goavro.Codec) (interface{}, []byte, error)) (interface{}, error) {
+	resolver, err := sm.resolution(topic, writer)
+	if err != nil {
+		return nil, err
+	}
+
+	native, _, err := decode(writer.codec)
+	if err != nil {
+		return nil, fmt.Errorf("decode message from topic %s with schema %d: %w", topic, writer.id, err)
+	}
+	if resolver == nil {
+		return native, nil
+	}
+
+	resolved, err := resolver.Resolve(native)
+	if err != nil {
+		return nil, fmt.Errorf("resolve schema %d against reader schema of topic %s: %w", writer.id, topic, err)
 	}
 
-	return schema, nil
+	return resolved, nil
+}
+
+// schemaById returns the cached schema with the given registry id, fetching it on first use.
+func (sm *SchemaManager) schemaById(id int) (*cachedSchema, error) {
+	sm.mu.RLock()
+	cached, ok := sm.schemasById[id]
+	sm.mu.RUnlock()
+	if ok {
+		return cached, nil
+	}
+
+	schemaData, err := sm.registry.SchemaById(context.Background(), id)
+	if err != nil {
+		return nil, fmt.Errorf("load writer schema %d: %w", id, err)
+	}
+
+	return sm.cacheSchema(id, schemaData)
+}
+
+func (sm *SchemaManager) cacheSchema(id int, schemaData string) (*cachedSchema, error) {
+	sm.mu.RLock()
+	cached, ok := sm.schemasById[id]
+	sm.mu.RUnlock()
+	if ok {
+		return cached, nil
+	}
+
+	codec, err := goavro.NewCodec(schemaData)
+	if err != nil {
+		return nil, fmt.Errorf("create codec for writer schema %d: %w", id, err)
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if cached, ok := sm.schemasById[id]; ok {
+		return cached, nil
+	}
+	cached = &cachedSchema{id: id, codec: codec, resolutions: make(map[string]schemaResolution)}
+	sm.schemasById[id] = cached
+
+	return cached, nil
+}
+
+// resolution returns how the writer schema resolves into the reader schema of topic. An incompatible
+// writer schema is reported by Health instead of being decoded into a broken DTO.
+func (sm *SchemaManager) resolution(topic string, writer *cachedSchema) (*schemaResolver, error) {
+	sm.mu.RLock()
+	res, ok := writer.resolutions[topic]
+	reader := sm.readers[topic]
+	sm.mu.RUnlock()
+	if ok {
+		return res.resolver, res.err
+	}
+
+	if writer.codec.CanonicalSchema() != reader.CanonicalSchema() {
+		res.resolver, res.err = newSchemaResolver(writer.codec.Schema(), reader.Schema())
+		if res.err != nil {
+			res.err = fmt.Errorf("schema %d of topic %s: %w", writer.id, topic, res.err)
+		}
+	}
+
+	sm.mu.Lock()
+	writer.resolutions[topic] = res
+	if res.err != nil {
+		sm.incompatible[topic+"-value"] = res.err
+		sm.log.Error("Incompatible schema", "topic", topic, "id", writer.id, "error", res.err)
+	}
+	sm.mu.Unlock()
+
+	return res.resolver, res.err
+}
+
+// registerEventSchemas registers the schemas of this service that are not registered yet. Schemas the
+// registry (or the bundle) cannot take are retried on the next refresh; until then Encode fails for
+// their topics and Health reports them.
+func (sm *SchemaManager) registerEventSchemas() {
+	for topic, schemaData := range eventSchemasForThisService {
+		sm.mu.RLock()
+		_, registered := sm.eventSchemas[topic]
+		sm.mu.RUnlock()
+		if registered {
+			continue
+		}
+
+		codec, err := goavro.NewCodec(schemaData)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to create codec for topic %s: %v", topic, err))
+		}
+
+		subject := topic + "-value"
+		id, err := sm.registry.Register(context.Background(), subject, codec.Schema())
+
+		sm.mu.Lock()
+		if err != nil {
+			sm.unregistered[subject] = err
+		} else {
+			delete(sm.unregistered, subject)
+			sm.eventSchemas[topic] = registeredSchema{id: id, codec: codec}
+		}
+		sm.mu.Unlock()
+
+		if err != nil {
+			sm.log.Error("Failed to register schema", "topic", topic, "error", err)
+			continue
+		}
+		sm.log.Info("Schema registered", "topic", topic, "id", id)
+	}
 }
@@ -0,0 +1,96 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"project-service/internal/config"
+	"project-service/internal/dto"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeResolvesWriterSchemasIntoTheReaderSchema(t *testing.T) {
+	reader := record(`{"name": "id", "type": "string"}, {"name": "count", "type": "int", "default": 1}`)
+	writer := record(`{"name": "id", "type": "string"}, {"name": "comment", "type": ["null", "string"]}`)
+
+	registry := NewMemorySchemaRegistry()
+	if _, err := registry.Register(context.Background(), "events-value", writer); err != nil {
+		t.Fatal(err)
+	}
+	sm := NewSchemaManager(slog.New(slog.NewTextHandler(io.Discard, nil)), &config.Config{AvroTextualFallback: true}, registry)
+	sm.LoadSchemas(map[string]string{"events": reader})
+
+	binary, err := registry.Encode("events", writer, map[string]interface{}{"id": "binary", "comment": map[string]interface{}{"string": "c"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		value []byte
+		want  map[string]interface{}
+	}{
+		{
+			name:  "wire format",
+			value: binary,
+			want:  map[string]interface{}{"id": "binary", "count": int32(1)},
+		},
+		{
+			name:  "JSON with a field the reader does not know",
+			value: []byte(`{"id": "json", "comment": {"string": "c"}}`),
+			want:  map[string]interface{}{"id": "json", "count": int32(1)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			native, err := sm.Decode("events", tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(native, tt.want) {
+				t.Fatalf("got %#v, want %#v", native, tt.want)
+			}
+		})
+	}
+
+	// JSON follows the latest schema once it is registered
+	newer := record(`{"name": "id", "type": "string"}, {"name": "count", "type": "long"}`)
+	if _, err := registry.Register(context.Background(), "events-value", newer); err != nil {
+		t.Fatal(err)
+	}
+	sm.refresh()
+	if _, err := sm.Decode("events", []byte(`{"id": "json", "count": 2}`)); err == nil {
+		t.Fatal("got no error for a long the reader's int cannot hold")
+	}
+	if err := sm.Health(); err == nil {
+		t.Fatal("got no health error for the incompatible latest schema")
+	}
+}
+
+func TestSchemasAreRegisteredOnceTheRegistryIsBack(t *testing.T) {
+	registry := NewMemorySchemaRegistry()
+	down := make([]error, len(eventSchemasForThisService))
+	for i := range down {
+		down[i] = errors.New("registry is unreachable and the bundle has no entry")
+	}
+	registry.FailNext(down...)
+
+	sm := NewSchemaManager(slog.New(slog.NewTextHandler(io.Discard, nil)), &config.Config{}, registry)
+	if err := sm.Health(); err == nil {
+		t.Fatal("got no health error without registered schemas")
+	}
+	if _, err := sm.Encode(dto.ProjectCreatedTopic, nil); err == nil {
+		t.Fatal("got no error encoding without a registered schema")
+	}
+
+	sm.registerEventSchemas()
+	if err := sm.Health(); err != nil {
+		t.Fatalf("got health error %v once the schemas are registered", err)
+	}
+	if _, ok := sm.eventSchemas[dto.ProjectCreatedTopic]; !ok {
+		t.Fatal("the schema of the project created topic is not registered")
+	}
+}
@@ -0,0 +1,672 @@
+package kafka
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// goavro cannot decode with a writer schema into a different reader schema, so data decoded with the
+// writer schema is converted here following the Avro schema resolution rules: fields are matched by
+// name or reader alias, missing fields take the reader default, writer-only fields are dropped,
+// numeric types are promoted and union branches are matched by type.
+
+var ErrIncompatibleSchema = errors.New("writer schema is incompatible with reader schema")
+
+var avroPrimitives = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+// avroSchema is a parsed schema with its named types indexed by full name.
+type avroSchema struct {
+	root  interface{}
+	named map[string]namedType
+}
+
+type namedType struct {
+	def       map[string]interface{}
+	namespace string
+}
+
+func parseAvroSchema(schema string) (*avroSchema, error) {
+	var root interface{}
+	if err := json.Unmarshal([]byte(schema), &root); err != nil {
+		return nil, fmt.Errorf("parse avro schema: %w", err)
+	}
+
+	parsed := &avroSchema{root: root, named: make(map[string]namedType)}
+	parsed.collect(root, "")
+
+	return parsed, nil
+}
+
+func (s *avroSchema) collect(node interface{}, namespace string) {
+	switch n := node.(type) {
+	case []interface{}:
+		for _, branch := range n {
+			s.collect(branch, namespace)
+		}
+	case map[string]interface{}:
+		switch n["type"] {
+		case "record", "error", "enum", "fixed":
+			fullName, ns := avroFullName(n, namespace)
+			s.named[fullName] = namedType{def: n, namespace: ns}
+			fields, _ := n["fields"].([]interface{})
+			for _, field := range fields {
+				if f, ok := field.(map[string]interface{}); ok {
+					s.collect(f["type"], ns)
+				}
+			}
+		case "array":
+			s.collect(n["items"], namespace)
+		case "map":
+			s.collect(n["values"], namespace)
+		default:
+			if _, ok := n["type"].(string); !ok {
+				s.collect(n["type"], namespace)
+			}
+		}
+	}
+}
+
+// deref replaces references to named types with their definitions and unwraps {"type": "<primitive>"}.
+// It returns the namespace names inside the node are relative to.
+func (s *avroSchema) deref(node interface{}, namespace string) (interface{}, string) {
+	switch n := node.(type) {
+	case string:
+		if avroPrimitives[n] {
+			return n, namespace
+		}
+		if named, ok := s.lookup(n, namespace); ok {
+			return named.def, named.namespace
+		}
+	case map[string]interface{}:
+		switch t := n["type"].(type) {
+		case string:
+			if _, logical := n["logicalType"]; !logical && avroPrimitives[t] {
+				return t, namespace
+			}
+			switch t {
+			case "record", "error", "enum", "fixed":
+				// names inside a named type are relative to its namespace
+				_, ns := avroFullName(n, namespace)
+				return n, ns
+			}
+			if !isComplexType(t) {
+				if named, ok := s.lookup(t, namespace); ok {
+					return named.def, named.namespace
+				}
+			}
+		case map[string]interface{}, []interface{}:
+			return s.deref(t, namespace)
+		}
+	}
+
+	return node, namespace
+}
+
+func (s *avroSchema) lookup(name, namespace string) (namedType, bool) {
+	if !strings.Contains(name, ".") && namespace != "" {
+		if named, ok := s.named[namespace+"."+name]; ok {
+			return named, true
+		}
+	}
+	named, ok := s.named[name]
+	return named, ok
+}
+
+func isComplexType(t string) bool {
+	switch t {
+	case "record", "error", "enum", "fixed", "array", "map":
+		return true
+	}
+	return false
+}
+
+func avroFullName(def map[string]interface{}, namespace string) (string, string) {
+	name, _ := def["name"].(string)
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name, name[:i]
+	}
+	if ns, ok := def["namespace"].(string); ok {
+		namespace = ns
+	}
+	if namespace == "" {
+		return name, ""
+	}
+	return namespace + "." + name, namespace
+}
+
+// avroKind returns the Avro type of a dereferenced schema node.
+func avroKind(node interface{}) string {
+	switch n := node.(type) {
+	case string:
+		return n
+	case []interface{}:
+		return "union"
+	case map[string]interface{}:
+		if t, ok := n["type"].(string); ok {
+			if t == "error" {
+				return "record"
+			}
+			return t
+		}
+	}
+	return ""
+}
+
+// unionBranchName is the key goavro uses for a union value of this branch.
+func unionBranchName(node interface{}, namespace string) string {
+	switch n := node.(type) {
+	case string:
+		return n
+	case map[string]interface{}:
+		t, _ := n["type"].(string)
+		switch t {
+		case "record", "error", "enum", "fixed":
+			fullName, _ := avroFullName(n, namespace)
+			return fullName
+		}
+		if logical, ok := n["logicalType"].(string); ok {
+			return t + "." + logical
+		}
+		return t
+	}
+	return ""
+}
+
+type schemaResolver struct {
+	writer *avroSchema
+	reader *avroSchema
+}
+
+func newSchemaResolver(writerSchema, readerSchema string) (*schemaResolver, error) {
+	writer, err := parseAvroSchema(writerSchema)
+	if err != nil {
+		return nil, fmt.Errorf("writer schema: %w", err)
+	}
+	reader, err := parseAvroSchema(readerSchema)
+	if err != nil {
+		return nil, fmt.Errorf("reader schema: %w", err)
+	}
+
+	resolver := &schemaResolver{writer: writer, reader: reader}
+	if err := resolver.check(writer.root, "", reader.root, "", make(map[string]bool)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrIncompatibleSchema, err)
+	}
+
+	return resolver, nil
+}
+
+// Resolve converts native data decoded with the writer schema into native data of the reader schema.
+func (r *schemaResolver) Resolve(native interface{}) (interface{}, error) {
+	return r.resolve(r.writer.root, "", r.reader.root, "", native)
+}
+
+func (r *schemaResolver) resolve(writer interface{}, writerNs string, reader interface{}, readerNs string, value interface{}) (interface{}, error) {
+	writer, writerNs = r.writer.deref(writer, writerNs)
+	reader, readerNs = r.reader.deref(reader, readerNs)
+	writerKind, readerKind := avroKind(writer), avroKind(reader)
+
+	if writerKind == "union" {
+		branch, branchValue, err := r.writerBranch(writer.([]interface{}), writerNs, value)
+		if err != nil {
+			return nil, err
+		}
+		return r.resolve(branch, writerNs, reader, readerNs, branchValue)
+	}
+
+	if readerKind == "union" {
+		return r.resolveIntoUnion(writer, writerNs, reader.([]interface{}), readerNs, value)
+	}
+
+	switch readerKind {
+	case "record":
+		if writerKind != "record" {
+			return nil, fmt.Errorf("cannot resolve writer %s into reader record", writerKind)
+		}
+		return r.resolveRecord(writer.(map[string]interface{}), writerNs, reader.(map[string]interface{}), readerNs, value)
+	case "enum":
+		if writerKind != "enum" {
+			return nil, fmt.Errorf("cannot resolve writer %s into reader enum", writerKind)
+		}
+		return resolveEnum(reader.(map[string]interface{}), value)
+	case "array":
+		if writerKind != "array" {
+			return nil, fmt.Errorf("cannot resolve writer %s into reader array", writerKind)
+		}
+		items, _ := value.([]interface{})
+		resolved := make([]interface{}, len(items))
+		for i, item := range items {
+			v, err := r.resolve(writer.(map[string]interface{})["items"], writerNs, reader.(map[string]interface{})["items"], readerNs, item)
+			if err != nil {
+				return nil, fmt.Errorf("array item %d: %w", i, err)
+			}
+			resolved[i] = v
+		}
+		return resolved, nil
+	case "map":
+		if writerKind != "map" {
+			return nil, fmt.Errorf("cannot resolve writer %s into reader map", writerKind)
+		}
+		entries, _ := value.(map[string]interface{})
+		resolved := make(map[string]interface{}, len(entries))
+		for key, entry := range entries {
+			v, err := r.resolve(writer.(map[string]interface{})["values"], writerNs, reader.(map[string]interface{})["values"], readerNs, entry)
+			if err != nil {
+				return nil, fmt.Errorf("map value %q: %w", key, err)
+			}
+			resolved[key] = v
+		}
+		return resolved, nil
+	case "fixed":
+		if writerKind != "fixed" {
+			return nil, fmt.Errorf("cannot resolve writer %s into reader fixed", writerKind)
+		}
+		return value, nil
+	default:
+		return promote(writerKind, readerKind, value)
+	}
+}
+
+func (r *schemaResolver) writerBranch(union []interface{}, namespace string, value interface{}) (interface{}, interface{}, error) {
+	if value == nil {
+		for _, branch := range union {
+			if avroKind(branch) == "null" {
+				return branch, nil, nil
+			}
+		}
+		return nil, nil, fmt.Errorf("null value for union without null branch")
+	}
+
+	wrapped, ok := value.(map[string]interface{})
+	if !ok || len(wrapped) != 1 {
+		return nil, nil, fmt.Errorf("unexpected union value %v", value)
+	}
+	for name, branchValue := range wrapped {
+		for _, branch := range union {
+			if unionBranchName(branch, namespace) == name || unionBranchName(r.derefWriter(branch, namespace), namespace) == name {
+				return branch, branchValue, nil
+			}
+		}
+		return nil, nil, fmt.Errorf("union value of unknown branch %s", name)
+	}
+
+	return nil, nil, fmt.Errorf("unexpected union value %v", value)
+}
+
+func (r *schemaResolver) derefWriter(node interface{}, namespace string) interface{} {
+	def, _ := r.writer.deref(node, namespace)
+	return def
+}
+
+// resolveIntoUnion picks the first compatible reader branch of the writer's type, or else the first one the writer type promotes to.
+func (r *schemaResolver) resolveIntoUnion(writer interface{}, writerNs string, union []interface{}, readerNs string, value interface{}) (interface{}, error) {
+	branch, ok := r.unionBranchFor(writer, writerNs, union, readerNs, make(map[string]bool))
+	if !ok {
+		return nil, fmt.Errorf("no reader union branch matches writer %s", avroKind(writer))
+	}
+
+	resolved, err := r.resolve(writer, writerNs, branch, readerNs, value)
+	if err != nil {
+		return nil, err
+	}
+
+	def, ns := r.reader.deref(branch, readerNs)
+	if avroKind(def) == "null" {
+		return nil, nil
+	}
+	return map[string]interface{}{unionBranchName(def, ns): resolved}, nil
+}
+
+func (r *schemaResolver) unionBranchFor(writer interface{}, writerNs string, union []interface{}, readerNs string, checking map[string]bool) (interface{}, bool) {
+	writerKind := avroKind(writer)
+	for _, sameKind := range []bool{true, false} {
+		for _, branch := range union {
+			def, _ := r.reader.deref(branch, readerNs)
+			if sameKind != (avroKind(def) == writerKind) {
+				continue
+			}
+			if r.check(writer, writerNs, branch, readerNs, checking) == nil {
+				return branch, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// check reports whether every value of the writer schema can be resolved into the reader schema.
+// Record names are not compared, producers on the platform do not agree on namespaces.
+func (r *schemaResolver) check(writer interface{}, writerNs string, reader interface{}, readerNs string, checking map[string]bool) error {
+	writer, writerNs = r.writer.deref(writer, writerNs)
+	reader, readerNs = r.reader.deref(reader, readerNs)
+	writerKind, readerKind := avroKind(writer), avroKind(reader)
+
+	if writerKind == "union" {
+		for _, branch := range writer.([]interface{}) {
+			if err := r.check(branch, writerNs, reader, readerNs, checking); err != nil {
+				return fmt.Errorf("writer union branch %s: %w", unionBranchName(branch, writerNs), err)
+			}
+		}
+		return nil
+	}
+
+	if readerKind == "union" {
+		if _, ok := r.unionBranchFor(writer, writerNs, reader.([]interface{}), readerNs, checking); !ok {
+			return fmt.Errorf("no reader union branch matches writer %s", writerKind)
+		}
+		return nil
+	}
+
+	if isComplexType(readerKind) && writerKind != readerKind {
+		return fmt.Errorf("cannot resolve writer %s into reader %s", writerKind, readerKind)
+	}
+
+	switch readerKind {
+	case "record":
+		return r.checkRecord(writer.(map[string]interface{}), writerNs, reader.(map[string]interface{}), readerNs, checking)
+	case "enum":
+		if _, hasDefault := reader.(map[string]interface{})["default"]; hasDefault {
+			return nil
+		}
+		readerSymbols := make(map[interface{}]bool)
+		symbols, _ := reader.(map[string]interface{})["symbols"].([]interface{})
+		for _, symbol := range symbols {
+			readerSymbols[symbol] = true
+		}
+		symbols, _ = writer.(map[string]interface{})["symbols"].([]interface{})
+		for _, symbol := range symbols {
+			if !readerSymbols[symbol] {
+				return fmt.Errorf("enum symbol %v is unknown to the reader", symbol)
+			}
+		}
+		return nil
+	case "array":
+		return r.check(writer.(map[string]interface{})["items"], writerNs, reader.(map[string]interface{})["items"], readerNs, checking)
+	case "map":
+		return r.check(writer.(map[string]interface{})["values"], writerNs, reader.(map[string]interface{})["values"], readerNs, checking)
+	case "fixed":
+		if writer.(map[string]interface{})["size"] != reader.(map[string]interface{})["size"] {
+			return fmt.Errorf("fixed sizes differ")
+		}
+		return nil
+	default:
+		if !canPromote(writerKind, readerKind) {
+			return fmt.Errorf("cannot resolve writer %s into reader %s", writerKind, readerKind)
+		}
+		return nil
+	}
+}
+
+func (r *schemaResolver) checkRecord(writer map[string]interface{}, writerNs string, reader map[string]interface{}, readerNs string, checking map[string]bool) error {
+	writerName, _ := avroFullName(writer, writerNs)
+	readerName, _ := avroFullName(reader, readerNs)
+	pair := writerName + "->" + readerName
+	// recursive records are assumed compatible while they are being checked
+	if checking[pair] {
+		return nil
+	}
+	checking[pair] = true
+	defer delete(checking, pair)
+
+	writerFields := make(map[string]map[string]interface{})
+	for _, field := range fieldsOf(writer) {
+		name, _ := field["name"].(string)
+		writerFields[name] = field
+	}
+
+	for _, field := range fieldsOf(reader) {
+		name, _ := field["name"].(string)
+		writerField, found := r.writerField(writerFields, field)
+		if !found {
+			if _, hasDefault := field["default"]; !hasDefault {
+				return fmt.Errorf("field %s is missing in writer schema and has no default", name)
+			}
+			continue
+		}
+		if err := r.check(writerField["type"], writerNs, field["type"], readerNs, checking); err != nil {
+			return fmt.Errorf("field %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// writerField finds the writer field of a reader field by name or by one of the reader aliases.
+func (r *schemaResolver) writerField(writerFields map[string]map[string]interface{}, readerField map[string]interface{}) (map[string]interface{}, bool) {
+	name, _ := readerField["name"].(string)
+	if field, ok := writerFields[name]; ok {
+		return field, true
+	}
+
+	aliases, _ := readerField["aliases"].([]interface{})
+	for _, alias := range aliases {
+		if a, ok := alias.(string); ok {
+			if field, ok := writerFields[a]; ok {
+				return field, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+func (r *schemaResolver) resolveRecord(writer map[string]interface{}, writerNs string, reader map[string]interface{}, readerNs string, value interface{}) (interface{}, error) {
+	record, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected record value %v", value)
+	}
+
+	writerFields := make(map[string]map[string]interface{})
+	for _, field := range fieldsOf(writer) {
+		name, _ := field["name"].(string)
+		writerFields[name] = field
+	}
+
+	resolved := make(map[string]interface{})
+	for _, field := range fieldsOf(reader) {
+		name, _ := field["name"].(string)
+
+		writerField, found := r.writerField(writerFields, field)
+		if found {
+			writerName, _ := writerField["name"].(string)
+			v, err := r.resolve(writerField["type"], writerNs, field["type"], readerNs, record[writerName])
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", name, err)
+			}
+			resolved[name] = v
+			continue
+		}
+
+		defaultValue, hasDefault := field["default"]
+		if !hasDefault {
+			return nil, fmt.Errorf("field %s is missing in writer schema and has no default", name)
+		}
+		v, err := r.defaultNative(field["type"], readerNs, defaultValue)
+		if err != nil {
+			return nil, fmt.Errorf("default of field %s: %w", name, err)
+		}
+		resolved[name] = v
+	}
+
+	return resolved, nil
+}
+
+// defaultNative converts a JSON default into the native form goavro would have decoded.
+func (r *schemaResolver) defaultNative(schema interface{}, namespace string, value interface{}) (interface{}, error) {
+	def, ns := r.reader.deref(schema, namespace)
+
+	switch avroKind(def) {
+	case "union":
+		// a union default always belongs to the first branch
+		first := def.([]interface{})[0]
+		firstDef, firstNs := r.reader.deref(first, ns)
+		if avroKind(firstDef) == "null" {
+			return nil, nil
+		}
+		v, err := r.defaultNative(first, ns, value)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{unionBranchName(firstDef, firstNs): v}, nil
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expected boolean, got %v", value)
+		}
+		return b, nil
+	case "int", "long", "float", "double":
+		f, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("expected number, got %v", value)
+		}
+		return convertNumber(f, int64(f), avroKind(def)), nil
+	case "string", "enum":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string, got %v", value)
+		}
+		return s, nil
+	case "bytes", "fixed":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string, got %v", value)
+		}
+		return []byte(s), nil
+	case "array":
+		items, _ := value.([]interface{})
+		resolved := make([]interface{}, len(items))
+		for i, item := range items {
+			v, err := r.defaultNative(def.(map[string]interface{})["items"], ns, item)
+			if err != nil {
+				return nil, err
+			}
+			resolved[i] = v
+		}
+		return resolved, nil
+	case "map":
+		entries, _ := value.(map[string]interface{})
+		resolved := make(map[string]interface{}, len(entries))
+		for key, entry := range entries {
+			v, err := r.defaultNative(def.(map[string]interface{})["values"], ns, entry)
+			if err != nil {
+				return nil, err
+			}
+			resolved[key] = v
+		}
+		return resolved, nil
+	case "record":
+		entries, _ := value.(map[string]interface{})
+		resolved := make(map[string]interface{})
+		for _, field := range fieldsOf(def.(map[string]interface{})) {
+			name, _ := field["name"].(string)
+			fieldValue, ok := entries[name]
+			if !ok {
+				if fieldValue, ok = field["default"]; !ok {
+					return nil, fmt.Errorf("field %s has no default", name)
+				}
+			}
+			v, err := r.defaultNative(field["type"], ns, fieldValue)
+			if err != nil {
+				return nil, err
+			}
+			resolved[name] = v
+		}
+		return resolved, nil
+	}
+
+	return nil, fmt.Errorf("unsupported default for schema %v", def)
+}
+
+func resolveEnum(reader map[string]interface{}, value interface{}) (interface{}, error) {
+	symbol, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected enum value %v", value)
+	}
+
+	symbols, _ := reader["symbols"].([]interface{})
+	for _, s := range symbols {
+		if s == symbol {
+			return symbol, nil
+		}
+	}
+	if defaultSymbol, ok := reader["default"].(string); ok {
+		return defaultSymbol, nil
+	}
+
+	return nil, fmt.Errorf("enum symbol %s is unknown to the reader", symbol)
+}
+
+func canPromote(from, to string) bool {
+	if from == to {
+		return true
+	}
+	switch from {
+	case "int":
+		return to == "long" || to == "float" || to == "double"
+	case "long":
+		return to == "float" || to == "double"
+	case "float":
+		return to == "double"
+	case "string":
+		return to == "bytes"
+	case "bytes":
+		return to == "string"
+	}
+	return false
+}
+
+func promote(from, to string, value interface{}) (interface{}, error) {
+	if !canPromote(from, to) {
+		return nil, fmt.Errorf("cannot resolve writer %s into reader %s", from, to)
+	}
+	if from == to {
+		return value, nil
+	}
+
+	switch v := value.(type) {
+	case int32:
+		return convertNumber(float64(v), int64(v), to), nil
+	case int64:
+		return convertNumber(float64(v), v, to), nil
+	case float32:
+		return convertNumber(float64(v), int64(v), to), nil
+	case float64:
+		return convertNumber(v, int64(v), to), nil
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return string(v), nil
+	}
+
+	return nil, fmt.Errorf("unexpected %s value %v", from, value)
+}
+
+func convertNumber(f float64, i int64, to string) interface{} {
+	switch to {
+	case "int":
+		return int32(i)
+	case "long":
+		return i
+	case "float":
+		return float32(f)
+	default:
+		return f
+	}
+}
+
+func fieldsOf(record map[string]interface{}) []map[string]interface{} {
+	raw, _ := record["fields"].([]interface{})
+	fields := make([]map[string]interface{}, 0, len(raw))
+	for _, field := range raw {
+		if f, ok := field.(map[string]interface{}); ok {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,158 @@
+package kafka
+
+import (
+	"errors"
+	"github.com/linkedin/goavro/v2"
+	"reflect"
+	"testing"
+)
+
+func record(fields string) string {
+	return `{"type": "record", "name": "Event", "namespace": "project", "fields": [` + fields + `]}`
+}
+
+func TestSchemaResolution(t *testing.T) {
+	tests := []struct {
+		name   string
+		writer string
+		reader string
+		value  map[string]interface{}
+		want   map[string]interface{}
+	}{
+		{
+			name:   "added fields take their defaults",
+			writer: record(`{"name": "id", "type": "string"}`),
+			reader: record(`{"name": "id", "type": "string"},
+				{"name": "count", "type": "int", "default": 7},
+				{"name": "tags", "type": {"type": "array", "items": "string"}, "default": ["a"]},
+				{"name": "note", "type": ["null", "string"], "default": null},
+				{"name": "owner", "type": ["string", "null"], "default": "nobody"}`),
+			value: map[string]interface{}{"id": "x"},
+			want: map[string]interface{}{
+				"id": "x", "count": int32(7), "tags": []interface{}{"a"}, "note": nil,
+				"owner": map[string]interface{}{"string": "nobody"},
+			},
+		},
+		{
+			name:   "removed fields are dropped",
+			writer: record(`{"name": "id", "type": "string"}, {"name": "extra", "type": "long"}`),
+			reader: record(`{"name": "id", "type": "string"}`),
+			value:  map[string]interface{}{"id": "x", "extra": int64(1)},
+			want:   map[string]interface{}{"id": "x"},
+		},
+		{
+			name:   "renamed fields are matched by alias",
+			writer: record(`{"name": "composeId", "type": "string"}`),
+			reader: record(`{"name": "id", "type": "string", "aliases": ["composeId"]}`),
+			value:  map[string]interface{}{"composeId": "x"},
+			want:   map[string]interface{}{"id": "x"},
+		},
+		{
+			name: "numbers and strings are promoted",
+			writer: record(`{"name": "a", "type": "int"}, {"name": "b", "type": "long"},
+				{"name": "c", "type": "float"}, {"name": "d", "type": "string"}, {"name": "e", "type": "bytes"}`),
+			reader: record(`{"name": "a", "type": "long"}, {"name": "b", "type": "double"},
+				{"name": "c", "type": "double"}, {"name": "d", "type": "bytes"}, {"name": "e", "type": "string"}`),
+			value: map[string]interface{}{"a": int32(1), "b": int64(2), "c": float32(1.5), "d": "d", "e": []byte("e")},
+			want:  map[string]interface{}{"a": int64(1), "b": float64(2), "c": float64(1.5), "d": []byte("d"), "e": "e"},
+		},
+		{
+			name: "union branches are matched by type",
+			writer: record(`{"name": "note", "type": ["null", "string"]}, {"name": "empty", "type": ["null", "string"]},
+				{"name": "plain", "type": "string"}, {"name": "count", "type": ["null", "int"]}`),
+			reader: record(`{"name": "note", "type": ["null", "string"]}, {"name": "empty", "type": ["null", "string"]},
+				{"name": "plain", "type": ["null", "string"]}, {"name": "count", "type": ["null", "long"]}`),
+			value: map[string]interface{}{
+				"note": map[string]interface{}{"string": "hi"}, "empty": nil,
+				"plain": "p", "count": map[string]interface{}{"int": int32(5)},
+			},
+			want: map[string]interface{}{
+				"note": map[string]interface{}{"string": "hi"}, "empty": nil,
+				"plain": map[string]interface{}{"string": "p"}, "count": map[string]interface{}{"long": int64(5)},
+			},
+		},
+		{
+			name: "unknown enum symbols take the reader default",
+			writer: record(`{"name": "known", "type": {"type": "enum", "name": "Status", "symbols": ["NEW", "READY", "STALE"]}},
+				{"name": "unknown", "type": "Status"}`),
+			reader: record(`{"name": "known", "type": {"type": "enum", "name": "Status", "symbols": ["NEW", "READY"], "default": "NEW"}},
+				{"name": "unknown", "type": "Status"}`),
+			value: map[string]interface{}{"known": "READY", "unknown": "STALE"},
+			want:  map[string]interface{}{"known": "READY", "unknown": "NEW"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer, err := goavro.NewCodec(tt.writer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader, err := goavro.NewCodec(tt.reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			binary, err := writer.BinaryFromNative(nil, tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			native, _, err := writer.NativeFromBinary(binary)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resolver, err := newSchemaResolver(tt.writer, tt.reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resolved, err := resolver.Resolve(native)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(resolved, tt.want) {
+				t.Fatalf("got %#v, want %#v", resolved, tt.want)
+			}
+			if _, err := reader.BinaryFromNative(nil, resolved); err != nil {
+				t.Fatalf("resolved value does not fit the reader schema: %v", err)
+			}
+		})
+	}
+}
+
+func TestSchemaResolutionRejectsIncompatibleSchemas(t *testing.T) {
+	tests := []struct {
+		name   string
+		writer string
+		reader string
+	}{
+		{
+			name:   "added field without default",
+			writer: record(`{"name": "id", "type": "string"}`),
+			reader: record(`{"name": "id", "type": "string"}, {"name": "count", "type": "int"}`),
+		},
+		{
+			name:   "narrowing a number",
+			writer: record(`{"name": "count", "type": "long"}`),
+			reader: record(`{"name": "count", "type": "int"}`),
+		},
+		{
+			name:   "union branch the reader lacks",
+			writer: record(`{"name": "value", "type": ["string", "boolean"]}`),
+			reader: record(`{"name": "value", "type": ["null", "string"]}`),
+		},
+		{
+			name:   "enum symbol the reader lacks without a default",
+			writer: record(`{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["NEW", "STALE"]}}`),
+			reader: record(`{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["NEW"]}}`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newSchemaResolver(tt.writer, tt.reader); !errors.Is(err, ErrIncompatibleSchema) {
+				t.Fatalf("got error %v, want %v", err, ErrIncompatibleSchema)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package kafka
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// workerPool runs jobs on a fixed number of workers. Jobs with the same key always go to the same
+// worker and run in submission order; each worker queues a bounded number of jobs.
+// A worker hands run up to batchSize jobs at once: after taking a job it waits up to window for more.
+type workerPool[J any] struct {
+	queues    []chan J
+	batchSize int
+	window    time.Duration
+	run       func(jobs []J)
+	running   sync.WaitGroup
+}
+
+func newWorkerPool[J any](workers, queueSize, batchSize int, window time.Duration, run func(jobs []J)) *workerPool[J] {
+	pool := &workerPool[J]{
+		queues:    make([]chan J, max(workers, 1)),
+		batchSize: max(batchSize, 1),
+		window:    window,
+		run:       run,
+	}
+	for i := range pool.queues {
+		pool.queues[i] = make(chan J, max(queueSize, 1))
+	}
+
+	pool.running.Add(len(pool.queues))
+	for _, queue := range pool.queues {
+		go pool.work(queue)
+	}
+
+	return pool
+}
+
+func (p *workerPool[J]) work(queue chan J) {
+	defer p.running.Done()
+
+	for first := range queue {
+		jobs := p.collect(queue, first)
+		p.run(jobs)
+	}
+}
+
+func (p *workerPool[J]) collect(queue chan J, first J) []J {
+	jobs := []J{first}
+	if p.batchSize == 1 {
+		return jobs
+	}
+
+	timer := time.NewTimer(p.window)
+	defer timer.Stop()
+
+	for len(jobs) < p.batchSize {
+		select {
+		case job, ok := <-queue:
+			if !ok {
+				return jobs
+			}
+			jobs = append(jobs, job)
+		case <-timer.C:
+			return jobs
+		}
+	}
+
+	return jobs
+}
+
+// submit blocks while the queue of the key's worker is full.
+func (p *workerPool[J]) submit(key string, job J) {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+
+	p.queues[h.Sum32()%uint32(len(p.queues))] <- job
+}
+
+// stop runs the queued jobs and stops the workers.
+func (p *workerPool[J]) stop() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.running.Wait()
+}
@@ -0,0 +1,298 @@
+// Package avrogen generates Go structs with Avro decode/encode functions from .avsc record schemas,
+// see cmd/avrogen. Every record gets a <record>.gen.go file.
+package avrogen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/linkedin/goavro/v2"
+	"go/format"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+type schemaFile struct {
+	path   string
+	source string
+	record record
+}
+
+type record struct {
+	Name      string
+	Namespace string
+	Fields    []recordField
+}
+
+type recordField struct {
+	AvroName string
+	GoName   string
+	GoType   string
+	Decoder  string
+	Branch   string // union branch of a nullable field, empty for required ones
+	ZeroTest string
+}
+
+// primitive Avro type -> Go type, decode helper and zero test
+var primitives = map[string]struct {
+	goType   string
+	decoder  string
+	zeroTest string
+}{
+	"string":  {"string", "decodeString", `== ""`},
+	"bytes":   {"[]byte", "decodeBytes", "== nil"},
+	"boolean": {"bool", "decodeBoolean", "== false"},
+	"int":     {"int32", "decodeInt", "== 0"},
+	"long":    {"int64", "decodeLong", "== 0"},
+	"float":   {"float32", "decodeFloat", "== 0"},
+	"double":  {"float64", "decodeDouble", "== 0"},
+}
+
+// Generate generates the files of the record schemas in paths, schema files or directories of them, for
+// package pkg in directory out. It returns the generated code by file path.
+func Generate(pkg, out string, paths []string) (map[string][]byte, error) {
+	files, err := loadSchemas(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	generated := make(map[string][]byte, len(files))
+	for _, file := range files {
+		code, err := generate(pkg, out, file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.path, err)
+		}
+		generated[filepath.Join(out, fileName(file.record.Name))] = code
+	}
+
+	return generated, nil
+}
+
+// Stale generates the files like Generate does and returns those that are missing or differ
+// from the generated code, sorted.
+func Stale(pkg, out string, paths []string) ([]string, error) {
+	generated, err := Generate(pkg, out, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []string
+	for target, code := range generated {
+		existing, err := os.ReadFile(target)
+		if err != nil || !bytes.Equal(existing, code) {
+			stale = append(stale, target)
+		}
+	}
+	sort.Strings(stale)
+
+	return stale, nil
+}
+
+func loadSchemas(args []string) ([]schemaFile, error) {
+	var paths []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(arg, "*.avsc"))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+
+	files := make([]schemaFile, 0, len(paths))
+	for _, path := range paths {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		// goavro parses schemas the way the registry does, so a schema it rejects would not register
+		if _, err := goavro.NewCodec(string(source)); err != nil {
+			return nil, fmt.Errorf("%s: invalid schema: %w", path, err)
+		}
+
+		parsed, err := parseRecord(source)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		files = append(files, schemaFile{path: path, source: strings.TrimSpace(string(source)), record: parsed})
+	}
+
+	return files, nil
+}
+
+func parseRecord(source []byte) (record, error) {
+	var schema struct {
+		Type      string `json:"type"`
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+		Fields    []struct {
+			Name string          `json:"name"`
+			Type json.RawMessage `json:"type"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal(source, &schema); err != nil {
+		return record{}, err
+	}
+	if schema.Type != "record" {
+		return record{}, fmt.Errorf("only record schemas are supported, got %q", schema.Type)
+	}
+
+	parsed := record{Name: schema.Name, Namespace: schema.Namespace}
+	for _, f := range schema.Fields {
+		field, err := parseField(f.Name, f.Type)
+		if err != nil {
+			return record{}, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		parsed.Fields = append(parsed.Fields, field)
+	}
+
+	return parsed, nil
+}
+
+// parseField supports primitive fields and nullable ones, ["null", <primitive>].
+func parseField(name string, raw json.RawMessage) (recordField, error) {
+	field := recordField{AvroName: name, GoName: exportedName(name)}
+
+	var union []json.RawMessage
+	if json.Unmarshal(raw, &union) == nil {
+		if len(union) != 2 || primitiveName(union[0]) != "null" {
+			return field, fmt.Errorf(`only ["null", <primitive>] unions are supported`)
+		}
+		raw = union[1]
+		field.Branch = primitiveName(raw)
+	}
+
+	primitive, ok := primitives[primitiveName(raw)]
+	if !ok {
+		return field, fmt.Errorf("unsupported type %s", string(raw))
+	}
+	field.GoType = primitive.goType
+	field.Decoder = primitive.decoder
+	field.ZeroTest = primitive.zeroTest
+
+	return field, nil
+}
+
+// primitiveName returns the primitive type of "string" or {"type": "string", ...}, or "" for anything else.
+func primitiveName(raw json.RawMessage) string {
+	var name string
+	if json.Unmarshal(raw, &name) == nil {
+		return name
+	}
+
+	var typed struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(raw, &typed) == nil {
+		if _, ok := primitives[typed.Type]; ok {
+			return typed.Type
+		}
+	}
+
+	return ""
+}
+
+func exportedName(name string) string {
+	runes := []rune(name)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+// fileName turns ProjectStatus into project-status.gen.go.
+func fileName(recordName string) string {
+	var b strings.Builder
+	for i, r := range recordName {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String() + ".gen.go"
+}
+
+var codeTemplate = template.Must(template.New("record").Parse(`// Code generated by avrogen from {{.Path}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import "fmt"
+
+const {{.Record.Name}}Schema = ` + "`{{.Source}}`" + `
+
+type {{.Record.Name}} struct {
+{{- range .Record.Fields}}
+	{{.GoName}} {{.GoType}}
+{{- end}}
+}
+
+// Decode{{.Record.Name}} reads a {{.Record.Name}} from its native goavro representation.
+func Decode{{.Record.Name}}(native interface{}) ({{.Record.Name}}, error) {
+	record, ok := native.(map[string]interface{})
+	if !ok {
+		return {{.Record.Name}}{}, fmt.Errorf("{{.Record.Name}}: expected a record, got %T", native)
+	}
+
+	var errs fieldErrors
+	event := {{.Record.Name}}{
+{{- range .Record.Fields}}
+		{{.GoName}}: {{.Decoder}}(record, "{{.AvroName}}", {{if .Branch}}true{{else}}false{{end}}, &errs),
+{{- end}}
+	}
+
+	return event, errs.err("{{.Record.Name}}")
+}
+
+// ToNative returns the native goavro representation of the event.
+func (e {{.Record.Name}}) ToNative() map[string]interface{} {
+	return map[string]interface{}{
+{{- range .Record.Fields}}
+{{- if .Branch}}
+		"{{.AvroName}}": nullable("{{.Branch}}", e.{{.GoName}}, e.{{.GoName}} {{.ZeroTest}}),
+{{- else}}
+		"{{.AvroName}}": e.{{.GoName}},
+{{- end}}
+{{- end}}
+	}
+}
+`))
+
+func generate(pkg, out string, file schemaFile) ([]byte, error) {
+	if strings.Contains(file.source, "`") {
+		return nil, fmt.Errorf("schema must not contain backquotes")
+	}
+
+	relPath, err := filepath.Rel(out, file.path)
+	if err != nil {
+		relPath = file.path
+	}
+
+	var buf bytes.Buffer
+	err = codeTemplate.Execute(&buf, map[string]interface{}{
+		"Package": pkg,
+		"Path":    filepath.ToSlash(relPath),
+		"Source":  file.source,
+		"Record":  file.record,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return format.Source(buf.Bytes())
+}
@@ -0,0 +1,16 @@
+package backoff
+
+import (
+	"time"
+)
+
+// Exponential returns the delay before the given attempt (starting at 1):
+// initial, 2*initial, 4*initial, ... capped at max.
+func Exponential(initial, max time.Duration, attempt int) time.Duration {
+	delay := initial
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+
+	return min(delay, max)
+}
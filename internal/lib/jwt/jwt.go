@@ -0,0 +1,306 @@
+// Package jwt verifies signed JSON Web Tokens (JWS compact serialization) with HMAC, RSA and ECDSA keys.
+package jwt
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpired      = errors.New("token is expired")
+)
+
+// Claims are the registered claims of a verified token. All claims, registered or not, are in Raw.
+type Claims struct {
+	Subject   string
+	Issuer    string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore time.Time
+	IssuedAt  time.Time
+	Raw       map[string]interface{}
+}
+
+// Options of a Verifier. Tokens have to carry an expiry and name Issuer and Audience; Leeway
+// tolerates clock skew when checking the time claims.
+type Options struct {
+	Keys     KeySource
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+}
+
+type Verifier struct {
+	keys     KeySource
+	issuer   string
+	audience string
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+func NewVerifier(opts Options) (*Verifier, error) {
+	if opts.Keys == nil {
+		return nil, errors.New("jwt: no key source")
+	}
+	if opts.Issuer == "" || opts.Audience == "" {
+		return nil, errors.New("jwt: issuer and audience are required")
+	}
+
+	return &Verifier{
+		keys:     opts.Keys,
+		issuer:   opts.Issuer,
+		audience: opts.Audience,
+		leeway:   opts.Leeway,
+		now:      time.Now,
+	}, nil
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify checks the signature of token and its issuer, audience and time claims.
+// Failures wrap ErrInvalidToken, or ErrExpired for expired tokens.
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	hash, ok := algorithmHash(h.Alg)
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, h.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrInvalidToken, err)
+	}
+
+	keys, err := v.keys.Keys(ctx, h.Kid)
+	if err != nil {
+		return nil, fmt.Errorf("load keys: %w", err)
+	}
+	if !verifyWithAny(keys, h.Alg, hash, []byte(parts[0]+"."+parts[1]), signature) {
+		return nil, fmt.Errorf("%w: signature does not match a key (kid %q)", ErrInvalidToken, h.Kid)
+	}
+
+	var raw map[string]interface{}
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+	claims, err := parseClaims(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if err := v.validate(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func (v *Verifier) validate(claims *Claims) error {
+	now := v.now()
+	if claims.ExpiresAt.IsZero() {
+		return fmt.Errorf("%w: no expiry", ErrInvalidToken)
+	}
+	if !now.Before(claims.ExpiresAt.Add(v.leeway)) {
+		return fmt.Errorf("%w at %s", ErrExpired, claims.ExpiresAt.Format(time.RFC3339))
+	}
+	if !claims.NotBefore.IsZero() && now.Add(v.leeway).Before(claims.NotBefore) {
+		return fmt.Errorf("%w: not valid before %s", ErrInvalidToken, claims.NotBefore.Format(time.RFC3339))
+	}
+	if !claims.IssuedAt.IsZero() && now.Add(v.leeway).Before(claims.IssuedAt) {
+		return fmt.Errorf("%w: issued in the future", ErrInvalidToken)
+	}
+	if claims.Issuer != v.issuer {
+		return fmt.Errorf("%w: issuer %q", ErrInvalidToken, claims.Issuer)
+	}
+	for _, audience := range claims.Audience {
+		if audience == v.audience {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: audience %v", ErrInvalidToken, claims.Audience)
+}
+
+func algorithmHash(alg string) (crypto.Hash, bool) {
+	if len(alg) != 5 {
+		return 0, false
+	}
+
+	switch alg[:2] {
+	case "HS", "RS", "PS", "ES":
+	default:
+		return 0, false
+	}
+	switch alg[2:] {
+	case "256":
+		return crypto.SHA256, true
+	case "384":
+		return crypto.SHA384, true
+	case "512":
+		return crypto.SHA512, true
+	default:
+		return 0, false
+	}
+}
+
+// verifyWithAny reports whether one of the keys fitting alg signed input. A key is only tried with
+// the algorithms of its type, so an RSA public key can never be used as an HMAC secret.
+func verifyWithAny(keys []Key, alg string, hash crypto.Hash, input, signature []byte) bool {
+	for _, key := range keys {
+		if key.Alg != "" && key.Alg != alg {
+			continue
+		}
+		if verify(key.Key, alg, hash, input, signature) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func verify(key interface{}, alg string, hash crypto.Hash, input, signature []byte) bool {
+	switch k := key.(type) {
+	case []byte:
+		if alg[:2] != "HS" {
+			return false
+		}
+		mac := hmac.New(hash.New, k)
+		mac.Write(input)
+		return hmac.Equal(mac.Sum(nil), signature)
+
+	case *rsa.PublicKey:
+		digest := digest(hash, input)
+		switch alg[:2] {
+		case "RS":
+			return rsa.VerifyPKCS1v15(k, hash, digest, signature) == nil
+		case "PS":
+			return rsa.VerifyPSS(k, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+		}
+		return false
+
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if alg[:2] != "ES" || k.Curve.Params().BitSize != esCurveBits[alg] || len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(k, digest(hash, input), r, s)
+
+	default:
+		return false
+	}
+}
+
+// esCurveBits is the curve size each ECDSA algorithm is defined on: P-256, P-384 and P-521.
+var esCurveBits = map[string]int{"ES256": 256, "ES384": 384, "ES512": 521}
+
+func digest(hash crypto.Hash, input []byte) []byte {
+	h := hash.New()
+	h.Write(input)
+	return h.Sum(nil)
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func parseClaims(raw map[string]interface{}) (*Claims, error) {
+	claims := &Claims{Raw: raw}
+
+	var err error
+	if claims.Subject, err = stringClaim(raw, "sub"); err != nil {
+		return nil, err
+	}
+	if claims.Issuer, err = stringClaim(raw, "iss"); err != nil {
+		return nil, err
+	}
+	if claims.ExpiresAt, err = timeClaim(raw, "exp"); err != nil {
+		return nil, err
+	}
+	if claims.NotBefore, err = timeClaim(raw, "nbf"); err != nil {
+		return nil, err
+	}
+	if claims.IssuedAt, err = timeClaim(raw, "iat"); err != nil {
+		return nil, err
+	}
+
+	switch aud := raw["aud"].(type) {
+	case nil:
+	case string:
+		claims.Audience = []string{aud}
+	case []interface{}:
+		for _, value := range aud {
+			audience, ok := value.(string)
+			if !ok {
+				return nil, errors.New("aud must hold strings")
+			}
+			claims.Audience = append(claims.Audience, audience)
+		}
+	default:
+		return nil, errors.New("aud must be a string or an array of strings")
+	}
+
+	return claims, nil
+}
+
+func stringClaim(raw map[string]interface{}, name string) (string, error) {
+	switch value := raw[name].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	default:
+		return "", fmt.Errorf("%s must be a string", name)
+	}
+}
+
+func timeClaim(raw map[string]interface{}, name string) (time.Time, error) {
+	value, ok := raw[name]
+	if !ok {
+		return time.Time{}, nil
+	}
+
+	number, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, fmt.Errorf("%s must be a number", name)
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be a number", name)
+	}
+
+	return time.Unix(0, int64(seconds*float64(time.Second))), nil
+}
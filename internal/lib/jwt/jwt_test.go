@@ -0,0 +1,247 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+type testKeys struct {
+	hmac  []byte
+	rsa   *rsa.PrivateKey
+	ec256 *ecdsa.PrivateKey
+	ec384 *ecdsa.PrivateKey
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testKeys{hmac: []byte("secret"), rsa: rsaKey, ec256: ec256, ec384: ec384}
+}
+
+func (k testKeys) source() StaticKeys {
+	return StaticKeys{
+		{ID: "hmac", Key: k.hmac},
+		{ID: "rsa", Key: &k.rsa.PublicKey},
+		{ID: "rsa-pss", Alg: "PS256", Key: &k.rsa.PublicKey},
+		{ID: "ec256", Key: &k.ec256.PublicKey},
+		{ID: "ec384", Key: &k.ec384.PublicKey},
+	}
+}
+
+// sign builds a token of claims, signed with key by alg; the signature is left empty for alg none.
+func sign(t *testing.T, alg, kid string, key interface{}, claims map[string]interface{}) string {
+	t.Helper()
+
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	input := encode(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + encode(claims)
+
+	hash, ok := algorithmHash(alg)
+	if !ok {
+		hash = crypto.SHA256
+	}
+	var signature []byte
+	var err error
+	switch k := key.(type) {
+	case nil:
+	case []byte:
+		mac := hmac.New(hash.New, k)
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		if strings.HasPrefix(alg, "PS") {
+			signature, err = rsa.SignPSS(rand.Reader, k, hash, digest(hash, []byte(input)), &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			signature, err = rsa.SignPKCS1v15(rand.Reader, k, hash, digest(hash, []byte(input)))
+		}
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest(hash, []byte(input)))
+		size := (k.Curve.Params().BitSize + 7) / 8
+		signature = make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub": "alice",
+		"iss": "https://auth.example.com",
+		"aud": "project-service",
+		"exp": testNow.Add(time.Hour).Unix(),
+		"iat": testNow.Add(-time.Minute).Unix(),
+	}
+}
+
+func newTestVerifier(t *testing.T, keys KeySource) *Verifier {
+	t.Helper()
+
+	verifier, err := NewVerifier(Options{
+		Keys:     keys,
+		Issuer:   "https://auth.example.com",
+		Audience: "project-service",
+		Leeway:   30 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier.now = func() time.Time { return testNow }
+
+	return verifier
+}
+
+func TestVerifySignatures(t *testing.T) {
+	keys := newTestKeys(t)
+	verifier := newTestVerifier(t, keys.source())
+
+	publicDER, err := x509.MarshalPKIXPublicKey(&keys.rsa.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	asn1Signature := func() string {
+		token := sign(t, "ES256", "ec256", nil, validClaims())
+		hash := digest(crypto.SHA256, []byte(token[:strings.LastIndex(token, ".")]))
+		der, err := ecdsa.SignASN1(rand.Reader, keys.ec256, hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token + base64.RawURLEncoding.EncodeToString(der)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{name: "HS256", token: sign(t, "HS256", "hmac", keys.hmac, validClaims()), valid: true},
+		{name: "HS512", token: sign(t, "HS512", "hmac", keys.hmac, validClaims()), valid: true},
+		{name: "RS256", token: sign(t, "RS256", "rsa", keys.rsa, validClaims()), valid: true},
+		{name: "PS256", token: sign(t, "PS256", "rsa-pss", keys.rsa, validClaims()), valid: true},
+		{name: "PS384 without a key for it", token: sign(t, "PS384", "rsa-pss", keys.rsa, validClaims())},
+		{name: "ES256", token: sign(t, "ES256", "ec256", keys.ec256, validClaims()), valid: true},
+		{name: "ES384", token: sign(t, "ES384", "ec384", keys.ec384, validClaims()), valid: true},
+		{name: "ES256 with an ASN.1 signature", token: asn1Signature()},
+		{name: "ES384 on a P-256 key", token: sign(t, "ES384", "ec256", keys.ec256, validClaims())},
+		{name: "no kid tries every key", token: sign(t, "RS256", "", keys.rsa, validClaims()), valid: true},
+		{name: "wrong kid", token: sign(t, "RS256", "hmac", keys.rsa, validClaims())},
+		{name: "unknown kid", token: sign(t, "HS256", "other", keys.hmac, validClaims())},
+		{name: "alg none", token: sign(t, "none", "hmac", nil, validClaims())},
+		{name: "alg none with a signature", token: sign(t, "none", "hmac", keys.hmac, validClaims())},
+		{name: "HMAC with the RSA public key", token: sign(t, "HS256", "rsa", publicDER, validClaims())},
+		{name: "HMAC with the RSA public key without kid", token: sign(t, "HS256", "", publicDER, validClaims())},
+		{name: "other secret", token: sign(t, "HS256", "hmac", []byte("guess"), validClaims())},
+		{name: "tampered claims", token: func() string {
+			token := sign(t, "HS256", "hmac", keys.hmac, validClaims())
+			parts := strings.Split(token, ".")
+			claims := validClaims()
+			claims["sub"] = "mallory"
+			forged := sign(t, "HS256", "hmac", []byte("guess"), claims)
+			return parts[0] + "." + strings.Split(forged, ".")[1] + "." + parts[2]
+		}()},
+		{name: "malformed", token: "a.b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifier.Verify(context.Background(), tt.token)
+			if !tt.valid {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("got error %v, want %v", err, ErrInvalidToken)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.Subject != "alice" {
+				t.Fatalf("got subject %q", claims.Subject)
+			}
+		})
+	}
+}
+
+func TestVerifyClaims(t *testing.T) {
+	keys := newTestKeys(t)
+	verifier := newTestVerifier(t, keys.source())
+
+	with := func(name string, value interface{}) map[string]interface{} {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		err    error
+	}{
+		{name: "valid", claims: validClaims()},
+		{name: "expired", claims: with("exp", testNow.Add(-time.Minute).Unix()), err: ErrExpired},
+		{name: "expired within the leeway", claims: with("exp", testNow.Add(-10*time.Second).Unix())},
+		{name: "expiring now after the leeway", claims: with("exp", testNow.Add(-30*time.Second).Unix()), err: ErrExpired},
+		{name: "no expiry", claims: with("exp", nil), err: ErrInvalidToken},
+		{name: "expiry as a string", claims: with("exp", "tomorrow"), err: ErrInvalidToken},
+		{name: "not valid yet", claims: with("nbf", testNow.Add(time.Minute).Unix()), err: ErrInvalidToken},
+		{name: "not valid yet within the leeway", claims: with("nbf", testNow.Add(10*time.Second).Unix())},
+		{name: "issued in the future", claims: with("iat", testNow.Add(time.Minute).Unix()), err: ErrInvalidToken},
+		{name: "other issuer", claims: with("iss", "https://evil.example.com"), err: ErrInvalidToken},
+		{name: "no audience", claims: with("aud", nil), err: ErrInvalidToken},
+		{name: "other audience", claims: with("aud", "billing-service"), err: ErrInvalidToken},
+		{name: "audience array", claims: with("aud", []string{"billing-service", "project-service"})},
+		{name: "audience array without ours", claims: with("aud", []string{"billing-service"}), err: ErrInvalidToken},
+		{name: "audience array of numbers", claims: with("aud", []int{1}), err: ErrInvalidToken},
+		{name: "fractional expiry", claims: with("exp", float64(testNow.Add(time.Hour).Unix())+0.5)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := verifier.Verify(context.Background(), sign(t, "HS256", "hmac", keys.hmac, tt.claims))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
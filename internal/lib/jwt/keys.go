@@ -0,0 +1,302 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Key is a verification key: []byte for HMAC, *rsa.PublicKey or *ecdsa.PublicKey.
+// Alg restricts the key to one algorithm when set.
+type Key struct {
+	ID  string
+	Alg string
+	Key interface{}
+}
+
+// KeySource returns the keys that may have signed a token with the given key id,
+// all of its keys when kid is empty.
+type KeySource interface {
+	Keys(ctx context.Context, kid string) ([]Key, error)
+}
+
+// StaticKeys is a fixed key set, e.g. HMAC secrets from the configuration or a JWKS file.
+type StaticKeys []Key
+
+func (s StaticKeys) Keys(_ context.Context, kid string) ([]Key, error) {
+	return matching(s, kid), nil
+}
+
+// ParseHMACKeys parses "id1:secret1,id2:secret2" into HMAC keys.
+func ParseHMACKeys(raw string) (StaticKeys, error) {
+	var keys StaticKeys
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		id, secret, ok := strings.Cut(pair, ":")
+		if !ok || secret == "" {
+			return nil, fmt.Errorf("hmac key %q must be in id:secret form", pair)
+		}
+		keys = append(keys, Key{ID: id, Key: []byte(secret)})
+	}
+
+	return keys, nil
+}
+
+// LoadJWKSFile reads a JWK set from a file, for tests and setups without a reachable JWKS endpoint.
+func LoadJWKSFile(path string) (StaticKeys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return nil, fmt.Errorf("jwks file %s: %w", path, err)
+	}
+
+	return keys, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// ParseJWKS parses a JWK set. Encryption keys and key types other than RSA, EC and oct are skipped.
+func ParseJWKS(data []byte) (StaticKeys, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	var keys StaticKeys
+	for _, raw := range set.Keys {
+		if raw.Use != "" && raw.Use != "sig" {
+			continue
+		}
+
+		key, err := raw.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", raw.Kid, err)
+		}
+		if key == nil {
+			continue
+		}
+		keys = append(keys, Key{ID: raw.Kid, Alg: raw.Alg, Key: key})
+	}
+
+	return keys, nil
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("rsa exponent is too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("ec point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return nil, err
+		}
+		return secret, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty key parameter")
+	}
+
+	return new(big.Int).SetBytes(data), nil
+}
+
+// minJWKSRefresh bounds how often the key set is fetched, also when fetching fails or a key id is unknown.
+const minJWKSRefresh = 30 * time.Second
+
+// RemoteJWKS fetches a JWK set from a URL and caches it for the refresh interval. A token signed with
+// a key the cached set does not know, e.g. after a key rotation, refetches it early. While the endpoint
+// is unreachable the last fetched keys keep being used.
+// Fetches run in the background, one at a time and independent of the requests that start them:
+// callers with a cached key do not wait for them, the others wait until the fetch ends or their context does.
+type RemoteJWKS struct {
+	url             string
+	client          *http.Client
+	refreshInterval time.Duration
+	now             func() time.Time
+
+	mu          sync.Mutex
+	keys        StaticKeys
+	fetchErr    error
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	fetching    chan struct{} // closed when the running fetch ends, nil without one
+}
+
+func NewRemoteJWKS(url string, refreshInterval, timeout time.Duration) *RemoteJWKS {
+	return &RemoteJWKS{
+		url:             url,
+		client:          &http.Client{Timeout: timeout},
+		refreshInterval: refreshInterval,
+		now:             time.Now,
+	}
+}
+
+func (r *RemoteJWKS) Keys(ctx context.Context, kid string) ([]Key, error) {
+	r.mu.Lock()
+	now := r.now()
+	keys := matching(r.keys, kid)
+	expired := now.Sub(r.fetchedAt) >= r.refreshInterval
+	if (expired || len(keys) == 0) && r.fetching == nil && now.Sub(r.attemptedAt) >= minJWKSRefresh {
+		r.attemptedAt = now
+		r.fetching = make(chan struct{})
+		go r.fetch(r.fetching)
+	}
+	fetching := r.fetching
+	r.mu.Unlock()
+
+	if len(keys) > 0 {
+		return keys, nil
+	}
+	if fetching != nil {
+		select {
+		case <-fetching:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.keys == nil && r.fetchErr != nil {
+		return nil, fmt.Errorf("fetch jwks from %s: %w", r.url, r.fetchErr)
+	}
+
+	return matching(r.keys, kid), nil
+}
+
+// fetch downloads the key set and closes done. The client timeout bounds it.
+func (r *RemoteJWKS) fetch(done chan struct{}) {
+	defer close(done)
+
+	fetched, err := r.download(context.Background())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.fetching = nil
+	r.fetchErr = err
+	if err == nil {
+		r.keys = fetched
+		r.fetchedAt = r.now()
+	}
+}
+
+func (r *RemoteJWKS) download(ctx context.Context) (StaticKeys, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseJWKS(data)
+}
+
+func matching(keys []Key, kid string) []Key {
+	if kid == "" {
+		return keys
+	}
+
+	var matched []Key
+	for _, key := range keys {
+		if key.ID == kid {
+			matched = append(matched, key)
+		}
+	}
+
+	return matched
+}
@@ -0,0 +1,222 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func encodeBigInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func jwksOf(t *testing.T, keys ...map[string]string) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{"kty": "RSA", "kid": kid, "alg": "RS256", "use": "sig",
+		"n": encodeBigInt(key.N), "e": encodeBigInt(big.NewInt(int64(key.E)))}
+}
+
+func TestParseJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := ParseJWKS(jwksOf(t,
+		rsaJWK("rsa", &rsaKey.PublicKey),
+		map[string]string{"kty": "EC", "kid": "ec", "crv": "P-256",
+			"x": encodeBigInt(ecKey.X), "y": encodeBigInt(ecKey.Y)},
+		map[string]string{"kty": "oct", "kid": "oct", "k": base64.RawURLEncoding.EncodeToString([]byte("secret"))},
+		map[string]string{"kty": "RSA", "kid": "enc", "use": "enc",
+			"n": encodeBigInt(rsaKey.N), "e": encodeBigInt(big.NewInt(int64(rsaKey.E)))},
+		map[string]string{"kty": "OKP", "kid": "okp", "crv": "Ed25519", "x": "AA"},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 3 || keys[0].ID != "rsa" || keys[0].Alg != "RS256" || keys[1].ID != "ec" || keys[2].ID != "oct" {
+		t.Fatalf("got keys %+v, want the rsa, ec and oct signing keys", keys)
+	}
+	if key, ok := keys[0].Key.(*rsa.PublicKey); !ok || !key.Equal(&rsaKey.PublicKey) {
+		t.Fatalf("got rsa key %v", keys[0].Key)
+	}
+	if key, ok := keys[1].Key.(*ecdsa.PublicKey); !ok || !key.Equal(&ecKey.PublicKey) {
+		t.Fatalf("got ec key %v", keys[1].Key)
+	}
+	if string(keys[2].Key.([]byte)) != "secret" {
+		t.Fatalf("got oct key %v", keys[2].Key)
+	}
+
+	invalid := map[string]map[string]string{
+		"point off the curve": {"kty": "EC", "kid": "ec", "crv": "P-256", "x": encodeBigInt(big.NewInt(1)), "y": encodeBigInt(big.NewInt(1))},
+		"unknown curve":       {"kty": "EC", "kid": "ec", "crv": "P-192", "x": "AQ", "y": "AQ"},
+		"empty modulus":       {"kty": "RSA", "kid": "rsa", "n": "", "e": "AQAB"},
+	}
+	for name, jwk := range invalid {
+		if _, err := ParseJWKS(jwksOf(t, jwk)); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+}
+
+// jwksServer serves the current key set; requests block while the gate is closed.
+type jwksServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	set      []byte
+	status   int
+	requests atomic.Int32
+	gate     chan struct{}
+}
+
+func newJWKSServer(t *testing.T, set []byte) *jwksServer {
+	s := &jwksServer{set: set, status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		s.mu.Lock()
+		gate, set, status := s.gate, s.set, s.status
+		s.mu.Unlock()
+		if gate != nil {
+			<-gate
+		}
+		w.WriteHeader(status)
+		w.Write(set)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) serve(set []byte, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set, s.status = set, status
+}
+
+func (s *jwksServer) hold() chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gate = make(chan struct{})
+	return s.gate
+}
+
+func TestRemoteJWKSRefetchesUnknownKeysAndKeepsTheLastSet(t *testing.T) {
+	first, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newJWKSServer(t, jwksOf(t, rsaJWK("first", &first.PublicKey)))
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	jwks := NewRemoteJWKS(server.URL, time.Hour, time.Second)
+	jwks.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if keys, err := jwks.Keys(ctx, "first"); err != nil || len(keys) != 1 {
+		t.Fatalf("got keys %v and error %v", keys, err)
+	}
+
+	// the key is rotated: an unknown kid refetches, but not more often than minJWKSRefresh
+	server.serve(jwksOf(t, rsaJWK("first", &first.PublicKey), rsaJWK("second", &second.PublicKey)), http.StatusOK)
+	now = now.Add(time.Second)
+	if keys, _ := jwks.Keys(ctx, "second"); len(keys) != 0 {
+		t.Fatalf("got keys %v before the minimum refresh interval", keys)
+	}
+	now = now.Add(minJWKSRefresh)
+	if keys, err := jwks.Keys(ctx, "second"); err != nil || len(keys) != 1 {
+		t.Fatalf("got keys %v and error %v after the rotation", keys, err)
+	}
+	if requests := server.requests.Load(); requests != 2 {
+		t.Fatalf("got %d requests, want 2", requests)
+	}
+
+	// the endpoint fails: the cached set keeps being used once it expired
+	server.serve(nil, http.StatusInternalServerError)
+	now = now.Add(2 * time.Hour)
+	if keys, err := jwks.Keys(ctx, "second"); err != nil || len(keys) != 1 {
+		t.Fatalf("got keys %v and error %v while the endpoint fails", keys, err)
+	}
+}
+
+func TestRemoteJWKSFetchesOnceOutsideTheCallersContext(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newJWKSServer(t, jwksOf(t, rsaJWK("key", &key.PublicKey)))
+	gate := server.hold()
+	jwks := NewRemoteJWKS(server.URL, time.Hour, 5*time.Second)
+
+	// the caller that starts the fetch gives up, the fetch goes on for the others
+	cancelled, cancel := context.WithCancel(context.Background())
+	go func() {
+		for server.requests.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	if _, err := jwks.Keys(cancelled, "key"); err != context.Canceled {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+
+	var wg sync.WaitGroup
+	results := make([]int, 10)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			keys, _ := jwks.Keys(context.Background(), "key")
+			results[i] = len(keys)
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(gate)
+	wg.Wait()
+
+	for i, found := range results {
+		if found != 1 {
+			t.Fatalf("caller %d got %d keys, want 1", i, found)
+		}
+	}
+	if requests := server.requests.Load(); requests != 1 {
+		t.Fatalf("got %d requests, want a single fetch", requests)
+	}
+}
+
+func TestRemoteJWKSFailsWithoutAnyFetchedSet(t *testing.T) {
+	server := newJWKSServer(t, nil)
+	server.serve(nil, http.StatusServiceUnavailable)
+	jwks := NewRemoteJWKS(server.URL, time.Hour, time.Second)
+
+	if _, err := jwks.Keys(context.Background(), "key"); err == nil {
+		t.Fatal("got no error without a fetched key set")
+	}
+}
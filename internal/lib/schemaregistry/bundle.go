@@ -0,0 +1,88 @@
+package schemaregistry
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Bundle is an offline copy of registry schemas, stored as {"schemas": [{"subject", "version", "id", "schema"}]}.
+type Bundle struct {
+	schemas []Schema
+}
+
+func LoadBundle(path string) (*Bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read schema bundle: %w", err)
+	}
+
+	var file struct {
+		Schemas []Schema `json:"schemas"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse schema bundle %s: %w", path, err)
+	}
+	for i, schema := range file.Schemas {
+		if schema.Subject == "" || schema.ID == 0 || schema.Schema == "" {
+			return nil, fmt.Errorf("schema bundle %s: entry %d needs subject, id and schema", path, i)
+		}
+	}
+
+	return &Bundle{schemas: file.Schemas}, nil
+}
+
+func (b *Bundle) LatestSchema(subject string) (Schema, error) {
+	var latest Schema
+	found := false
+	for _, schema := range b.schemas {
+		if schema.Subject == subject && (!found || schema.Version > latest.Version) {
+			latest = schema
+			found = true
+		}
+	}
+	if !found {
+		return Schema{}, fmt.Errorf("subject %s is not in the schema bundle: %w", subject, ErrNotFound)
+	}
+
+	return latest, nil
+}
+
+func (b *Bundle) SchemaById(id int) (string, error) {
+	for _, schema := range b.schemas {
+		if schema.ID == id {
+			return schema.Schema, nil
+		}
+	}
+
+	return "", fmt.Errorf("schema %d is not in the schema bundle: %w", id, ErrNotFound)
+}
+
+// Lookup returns the id the bundle has for schema under subject, ignoring formatting differences.
+func (b *Bundle) Lookup(subject, schema string) (int, error) {
+	normalized, err := normalize(schema)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, candidate := range b.schemas {
+		if candidate.Subject != subject {
+			continue
+		}
+		if other, err := normalize(candidate.Schema); err == nil && other == normalized {
+			return candidate.ID, nil
+		}
+	}
+
+	return 0, fmt.Errorf("schema for subject %s is not in the schema bundle: %w", subject, ErrNotFound)
+}
+
+func normalize(schema string) (string, error) {
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(schema), &parsed); err != nil {
+		return "", fmt.Errorf("parse schema: %w", err)
+	}
+
+	normalized, err := json.Marshal(parsed)
+	return string(normalized), err
+}
@@ -0,0 +1,280 @@
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"project-service/internal/lib/backoff"
+	"time"
+)
+
+var ErrNotFound = errors.New("schema not found")
+
+// Schema is a schema version as returned by the registry.
+type Schema struct {
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+	ID      int    `json:"id"`
+	Schema  string `json:"schema"`
+}
+
+// Error is a failed registry request. Code and Message come from the registry error body when it has one.
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Code       int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("schema registry %s %s: status %d, error %d: %s", e.Method, e.Path, e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("schema registry %s %s: status %d", e.Method, e.Path, e.StatusCode)
+}
+
+func (e *Error) Is(target error) bool {
+	return target == ErrNotFound && e.StatusCode == http.StatusNotFound
+}
+
+// temporary reports whether the request may succeed when repeated.
+func (e *Error) temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+type Options struct {
+	URL            string
+	Timeout        time.Duration
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Username       string
+	Password       string
+	CAFile         string
+	CertFile       string
+	KeyFile        string
+	// BundleFile is a schema bundle answering requests while the registry is unreachable.
+	BundleFile string
+}
+
+// Client talks to a Confluent schema registry, retrying temporary failures with exponential backoff.
+// When the registry cannot be reached at all, reads and registrations are answered from the bundle.
+type Client struct {
+	baseURL        string
+	httpClient     *http.Client
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	username       string
+	password       string
+	bundle         *Bundle
+}
+
+func New(opts Options) (*Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.CAFile != "" || opts.CertFile != "" {
+		tlsConfig, err := newTLSConfig(opts.CAFile, opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	var bundle *Bundle
+	if opts.BundleFile != "" {
+		var err error
+		bundle, err = LoadBundle(opts.BundleFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.URL == "" && bundle == nil {
+		return nil, errors.New("either a schema registry url or a schema bundle is required")
+	}
+
+	return &Client{
+		baseURL:        opts.URL,
+		httpClient:     &http.Client{Timeout: opts.Timeout, Transport: transport},
+		maxAttempts:    max(opts.MaxAttempts, 1),
+		initialBackoff: opts.InitialBackoff,
+		maxBackoff:     opts.MaxBackoff,
+		username:       opts.Username,
+		password:       opts.Password,
+		bundle:         bundle,
+	}, nil
+}
+
+func newTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		ca, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read schema registry ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load schema registry client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+func (c *Client) LatestSchema(ctx context.Context, subject string) (Schema, error) {
+	var schema Schema
+	err := c.do(ctx, http.MethodGet, "/subjects/"+url.PathEscape(subject)+"/versions/latest", nil, &schema)
+	if isUnreachable(err) && c.bundle != nil {
+		return c.bundle.LatestSchema(subject)
+	}
+
+	return schema, err
+}
+
+func (c *Client) SchemaById(ctx context.Context, id int) (string, error) {
+	var schema Schema
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/schemas/ids/%d", id), nil, &schema)
+	if isUnreachable(err) && c.bundle != nil {
+		return c.bundle.SchemaById(id)
+	}
+
+	return schema.Schema, err
+}
+
+// Register registers schema under subject and returns its id. Registering an existing schema is a no-op.
+func (c *Client) Register(ctx context.Context, subject, schema string) (int, error) {
+	var registered struct {
+		ID int `json:"id"`
+	}
+	err := c.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", map[string]string{"schema": schema}, &registered)
+	if isUnreachable(err) && c.bundle != nil {
+		return c.bundle.Lookup(subject, schema)
+	}
+
+	return registered.ID, err
+}
+
+// unreachableError marks a registry that could not be asked at all, as opposed to one that answered with an error.
+type unreachableError struct {
+	err error
+}
+
+func (e *unreachableError) Error() string {
+	return fmt.Sprintf("schema registry unreachable: %v", e.err)
+}
+
+func (e *unreachableError) Unwrap() error {
+	return e.err
+}
+
+func isUnreachable(err error) bool {
+	var unreachable *unreachableError
+	return errors.As(err, &unreachable)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, target interface{}) error {
+	if c.baseURL == "" {
+		return &unreachableError{err: errors.New("no schema registry url configured")}
+	}
+
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	for attempt := 1; attempt <= c.maxAttempts; attempt++ {
+		err = c.doOnce(ctx, method, path, payload, target)
+
+		var registryErr *Error
+		if err == nil || (errors.As(err, &registryErr) && !registryErr.temporary()) {
+			return err
+		}
+		if attempt == c.maxAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff.Exponential(c.initialBackoff, c.maxBackoff, attempt)):
+		}
+	}
+
+	return err
+}
+
+func (c *Client) doOnce(ctx context.Context, method, path string, payload []byte, target interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		var netErr net.Error
+		var urlErr *url.Error
+		if errors.As(err, &netErr) || errors.As(err, &urlErr) {
+			return &unreachableError{err: err}
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		registryErr := &Error{Method: method, Path: path, StatusCode: resp.StatusCode}
+		var errBody struct {
+			ErrorCode int    `json:"error_code"`
+			Message   string `json:"message"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&errBody) == nil {
+			registryErr.Code = errBody.ErrorCode
+			registryErr.Message = errBody.Message
+		}
+		if registryErr.temporary() {
+			// the registry is there but cannot serve us, the bundle is as good an answer as any
+			return &unreachableError{err: registryErr}
+		}
+		return registryErr
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return fmt.Errorf("decode schema registry response to %s %s: %w", method, path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,290 @@
+package schemaregistry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testSchema = `{"type": "record", "name": "Event", "fields": [{"name": "id", "type": "string"}]}`
+
+// registry answers every request with the next of its responses, the last one repeating.
+type registry struct {
+	*httptest.Server
+	requests atomic.Int32
+}
+
+type response struct {
+	status int
+	body   interface{}
+}
+
+func newRegistry(t *testing.T, check func(r *http.Request), responses ...response) *registry {
+	t.Helper()
+
+	reg := &registry{}
+	reg.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if check != nil {
+			check(r)
+		}
+		i := int(reg.requests.Add(1)) - 1
+		next := responses[min(i, len(responses)-1)]
+		w.WriteHeader(next.status)
+		json.NewEncoder(w).Encode(next.body)
+	}))
+	t.Cleanup(reg.Close)
+
+	return reg
+}
+
+func testOptions(url string) Options {
+	return Options{URL: url, Timeout: time.Second, MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+}
+
+func newTestClient(t *testing.T, opts Options) *Client {
+	t.Helper()
+
+	client, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestClientRetriesTemporaryFailures(t *testing.T) {
+	latest := Schema{Subject: "events-value", Version: 2, ID: 7, Schema: testSchema}
+	unavailable := map[string]interface{}{"error_code": 50003, "message": "forwarding failed"}
+
+	tests := []struct {
+		name      string
+		responses []response
+		requests  int32
+		err       error
+	}{
+		{name: "success", responses: []response{{http.StatusOK, latest}}, requests: 1},
+		{name: "5xx then success", responses: []response{{http.StatusServiceUnavailable, unavailable}, {http.StatusOK, latest}}, requests: 2},
+		{name: "429 then success", responses: []response{{http.StatusTooManyRequests, nil}, {http.StatusOK, latest}}, requests: 2},
+		{name: "5xx on every attempt", responses: []response{{http.StatusInternalServerError, unavailable}}, requests: 3, err: &Error{}},
+		{name: "not found is not retried", responses: []response{{http.StatusNotFound, map[string]interface{}{"error_code": 40401, "message": "Subject not found"}}}, requests: 1, err: ErrNotFound},
+		{name: "unauthorized is not retried", responses: []response{{http.StatusUnauthorized, nil}}, requests: 1, err: &Error{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := newRegistry(t, nil, tt.responses...)
+			client := newTestClient(t, testOptions(reg.URL))
+
+			schema, err := client.LatestSchema(context.Background(), "events-value")
+			if requests := reg.requests.Load(); requests != tt.requests {
+				t.Fatalf("got %d requests, want %d", requests, tt.requests)
+			}
+
+			switch want := tt.err.(type) {
+			case nil:
+				if err != nil || schema != latest {
+					t.Fatalf("got schema %+v and error %v", schema, err)
+				}
+			case *Error:
+				var registryErr *Error
+				if !errors.As(err, &registryErr) {
+					t.Fatalf("got error %v, want a registry error", err)
+				}
+			default:
+				if !errors.Is(err, want) {
+					t.Fatalf("got error %v, want %v", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestClientParsesRegistryErrors(t *testing.T) {
+	reg := newRegistry(t, nil, response{http.StatusConflict, map[string]interface{}{"error_code": 409, "message": "incompatible schema"}})
+	client := newTestClient(t, testOptions(reg.URL))
+
+	_, err := client.Register(context.Background(), "events-value", testSchema)
+
+	var registryErr *Error
+	if !errors.As(err, &registryErr) || registryErr.StatusCode != http.StatusConflict || registryErr.Code != 409 ||
+		registryErr.Message != "incompatible schema" || registryErr.Method != http.MethodPost || registryErr.Path != "/subjects/events-value/versions" {
+		t.Fatalf("got error %#v", err)
+	}
+}
+
+func TestClientSendsCredentialsAndSchemas(t *testing.T) {
+	var username, password, contentType, schema string
+	reg := newRegistry(t, func(r *http.Request) {
+		username, password, _ = r.BasicAuth()
+		contentType = r.Header.Get("Content-Type")
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		schema = body["schema"]
+	}, response{http.StatusOK, map[string]int{"id": 3}})
+
+	opts := testOptions(reg.URL)
+	opts.Username, opts.Password = "user", "secret"
+	id, err := newTestClient(t, opts).Register(context.Background(), "events-value", testSchema)
+	if err != nil || id != 3 {
+		t.Fatalf("got id %d and error %v", id, err)
+	}
+	if username != "user" || password != "secret" {
+		t.Fatalf("got credentials %q:%q", username, password)
+	}
+	if contentType != "application/vnd.schemaregistry.v1+json" || schema != testSchema {
+		t.Fatalf("got content type %q and schema %q", contentType, schema)
+	}
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) string {
+	t.Helper()
+
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestClientTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(Schema{Subject: "events-value", Version: 1, ID: 1, Schema: testSchema})
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	// the server certificate doubles as the client certificate
+	dir := t.TempDir()
+	cert := server.TLS.Certificates[0]
+	caFile := writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", cert.Certificate[0])
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := writePEM(t, filepath.Join(dir, "key.pem"), "PRIVATE KEY", key)
+
+	opts := testOptions(server.URL)
+	opts.MaxAttempts = 1
+	if _, err := newTestClient(t, opts).LatestSchema(context.Background(), "events-value"); err == nil {
+		t.Fatal("got no error without the registry's CA")
+	}
+
+	opts.CAFile = caFile
+	if _, err := newTestClient(t, opts).LatestSchema(context.Background(), "events-value"); err == nil {
+		t.Fatal("got no error without a client certificate")
+	}
+
+	opts.CertFile, opts.KeyFile = caFile, keyFile
+	if _, err := newTestClient(t, opts).LatestSchema(context.Background(), "events-value"); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, opts := range map[string]Options{
+		"missing ca":           {URL: server.URL, CAFile: filepath.Join(dir, "missing.pem")},
+		"ca without a cert":    {URL: server.URL, CAFile: keyFile},
+		"cert without a key":   {URL: server.URL, CertFile: caFile},
+		"no url and no bundle": {},
+	} {
+		if _, err := New(opts); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+}
+
+func writeBundle(t *testing.T, schemas ...Schema) string {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]interface{}{"schemas": schemas})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "bundle.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestClientFallsBackToTheBundle(t *testing.T) {
+	bundle := writeBundle(t,
+		Schema{Subject: "events-value", Version: 1, ID: 4, Schema: testSchema},
+		Schema{Subject: "events-value", Version: 2, ID: 5, Schema: `{"type": "string"}`},
+	)
+	closed := httptest.NewServer(nil)
+	closed.Close()
+	down := newRegistry(t, nil, response{http.StatusServiceUnavailable, nil})
+	notFound := newRegistry(t, nil, response{http.StatusNotFound, nil})
+
+	tests := []struct {
+		name     string
+		url      string
+		fallback bool
+	}{
+		{name: "unreachable registry", url: closed.URL, fallback: true},
+		{name: "registry failing on every attempt", url: down.URL, fallback: true},
+		{name: "no registry url", url: "", fallback: true},
+		{name: "registry answering not found", url: notFound.URL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testOptions(tt.url)
+			opts.BundleFile = bundle
+			client := newTestClient(t, opts)
+			ctx := context.Background()
+
+			latest, err := client.LatestSchema(ctx, "events-value")
+			if !tt.fallback {
+				if !errors.Is(err, ErrNotFound) {
+					t.Fatalf("got schema %+v and error %v, want the registry's answer", latest, err)
+				}
+				return
+			}
+			if err != nil || latest.ID != 5 {
+				t.Fatalf("got latest schema %+v and error %v, want version 2", latest, err)
+			}
+
+			if schema, err := client.SchemaById(ctx, 4); err != nil || schema != testSchema {
+				t.Fatalf("got schema %q and error %v", schema, err)
+			}
+			formatted := `{"type":"record","name":"Event","fields":[{"type":"string","name":"id"}]}`
+			if id, err := client.Register(ctx, "events-value", formatted); err != nil || id != 4 {
+				t.Fatalf("got id %d and error %v for a bundled schema", id, err)
+			}
+			if _, err := client.Register(ctx, "other-value", testSchema); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("got error %v for a subject the bundle does not have", err)
+			}
+			if _, err := client.SchemaById(ctx, 9); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("got error %v for an id the bundle does not have", err)
+			}
+		})
+	}
+}
+
+func TestLoadBundleRejectsIncompleteEntries(t *testing.T) {
+	for name, schema := range map[string]Schema{
+		"no subject": {ID: 1, Schema: testSchema},
+		"no id":      {Subject: "events-value", Schema: testSchema},
+		"no schema":  {Subject: "events-value", ID: 1},
+	} {
+		if _, err := LoadBundle(writeBundle(t, schema)); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+	if _, err := LoadBundle(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("got no error for a missing bundle")
+	}
+}
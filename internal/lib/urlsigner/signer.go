@@ -0,0 +1,127 @@
+package urlsigner
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrExpired          = errors.New("signed url expired")
+	ErrUnknownKey       = errors.New("unknown signing key")
+	ErrDisabled         = errors.New("download links are disabled")
+)
+
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// Signer issues and verifies expiring HMAC-signed download URLs for an artifact id. The id is visible in
+// the URL, so it has to be opaque: the server resolves it to the storage location after verification.
+// The first key signs new URLs, the rest are kept only for verification so keys can be rotated without
+// breaking issued links. Without keys the signer is disabled: it issues no URLs and verifies none.
+type Signer struct {
+	baseURL     string
+	ttl         time.Duration
+	activeKeyId string
+	keys        map[string][]byte
+	now         func() time.Time
+}
+
+func New(baseURL string, ttl time.Duration, keys []Key) (*Signer, error) {
+	signer := &Signer{
+		baseURL: baseURL,
+		ttl:     ttl,
+		keys:    make(map[string][]byte, len(keys)),
+		now:     time.Now,
+	}
+	if len(keys) != 0 {
+		signer.activeKeyId = keys[0].ID
+	}
+	for _, key := range keys {
+		if key.ID == "" || len(key.Secret) == 0 {
+			return nil, fmt.Errorf("signing key %q is empty", key.ID)
+		}
+		signer.keys[key.ID] = key.Secret
+	}
+
+	return signer, nil
+}
+
+// ParseKeys parses "id1:secret1,id2:secret2" into keys, keeping the order.
+func ParseKeys(raw string) ([]Key, error) {
+	var keys []Key
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		id, secret, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("signing key %q must be in id:secret form", pair)
+		}
+		keys = append(keys, Key{ID: id, Secret: []byte(secret)})
+	}
+
+	return keys, nil
+}
+
+// Enabled reports whether the signer has a key to sign URLs with.
+func (s *Signer) Enabled() bool {
+	return len(s.keys) != 0
+}
+
+func (s *Signer) Sign(artifactId string) (string, time.Time, error) {
+	if !s.Enabled() {
+		return "", time.Time{}, ErrDisabled
+	}
+
+	expiresAt := s.now().Add(s.ttl).Truncate(time.Second)
+	exp := strconv.FormatInt(expiresAt.Unix(), 10)
+
+	query := url.Values{}
+	query.Set("artifact", artifactId)
+	query.Set("exp", exp)
+	query.Set("kid", s.activeKeyId)
+	query.Set("sig", sign(s.keys[s.activeKeyId], artifactId, exp, s.activeKeyId))
+
+	return s.baseURL + "?" + query.Encode(), expiresAt, nil
+}
+
+// Verify checks the query of a signed URL and returns the artifact id it grants access to.
+func (s *Signer) Verify(query url.Values) (string, error) {
+	artifactId, exp, kid, sig := query.Get("artifact"), query.Get("exp"), query.Get("kid"), query.Get("sig")
+
+	secret, ok := s.keys[kid]
+	if !ok {
+		return "", ErrUnknownKey
+	}
+	if artifactId == "" || !hmac.Equal([]byte(sig), []byte(sign(secret, artifactId, exp, kid))) {
+		return "", ErrInvalidSignature
+	}
+
+	expiresAt, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+	if s.now().Unix() > expiresAt {
+		return "", ErrExpired
+	}
+
+	return artifactId, nil
+}
+
+func sign(secret []byte, artifactId, exp, kid string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(kid + "\n" + exp + "\n" + artifactId))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,122 @@
+package urlsigner
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestSigner(t *testing.T, keys ...Key) *Signer {
+	t.Helper()
+
+	signer, err := New("https://example.com/downloads", 15*time.Minute, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer.now = func() time.Time { return testNow }
+
+	return signer
+}
+
+// signedQuery signs artifactId with signer and returns the query of the URL.
+func signedQuery(t *testing.T, signer *Signer, artifactId string) url.Values {
+	t.Helper()
+
+	signed, _, err := signer.Sign(artifactId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return parsed.Query()
+}
+
+func TestVerify(t *testing.T) {
+	current := Key{ID: "current", Secret: []byte("current secret")}
+	old := Key{ID: "old", Secret: []byte("old secret")}
+	signer := newTestSigner(t, current, old)
+	oldSigner := newTestSigner(t, old)
+
+	with := func(query url.Values, name, value string) url.Values {
+		changed := url.Values{}
+		for key, values := range query {
+			changed[key] = append([]string(nil), values...)
+		}
+		changed.Set(name, value)
+		return changed
+	}
+	valid := signedQuery(t, signer, "alice/api/3")
+
+	tests := []struct {
+		name  string
+		query url.Values
+		now   time.Time
+		err   error
+	}{
+		{name: "valid", query: valid, now: testNow},
+		{name: "valid until the expiry", query: valid, now: testNow.Add(15 * time.Minute)},
+		{name: "expired", query: valid, now: testNow.Add(15*time.Minute + time.Second), err: ErrExpired},
+		{name: "signed by the rotated key", query: signedQuery(t, oldSigner, "alice/api/3"), now: testNow},
+		{name: "other artifact", query: with(valid, "artifact", "alice/api/4"), now: testNow, err: ErrInvalidSignature},
+		{name: "extended expiry", query: with(valid, "exp", "9999999999"), now: testNow, err: ErrInvalidSignature},
+		{name: "malformed expiry", query: with(valid, "exp", "tomorrow"), now: testNow, err: ErrInvalidSignature},
+		{name: "other kid", query: with(valid, "kid", "old"), now: testNow, err: ErrInvalidSignature},
+		{name: "unknown kid", query: with(valid, "kid", "retired"), now: testNow, err: ErrUnknownKey},
+		{name: "no signature", query: with(valid, "sig", ""), now: testNow, err: ErrInvalidSignature},
+		{name: "no artifact", query: with(valid, "artifact", ""), now: testNow, err: ErrInvalidSignature},
+		{name: "no query", query: url.Values{}, now: testNow, err: ErrUnknownKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer.now = func() time.Time { return tt.now }
+
+			artifactId, err := signer.Verify(tt.query)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if tt.err == nil && artifactId != "alice/api/3" {
+				t.Fatalf("got artifact %q", artifactId)
+			}
+		})
+	}
+}
+
+func TestSignerWithoutKeysIsDisabled(t *testing.T) {
+	signer := newTestSigner(t)
+	if signer.Enabled() {
+		t.Fatal("got an enabled signer without keys")
+	}
+	if _, _, err := signer.Sign("alice/api/3"); !errors.Is(err, ErrDisabled) {
+		t.Fatalf("got error %v, want %v", err, ErrDisabled)
+	}
+
+	query := signedQuery(t, newTestSigner(t, Key{ID: "key", Secret: []byte("secret")}), "alice/api/3")
+	if _, err := signer.Verify(query); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("got error %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	keys, err := ParseKeys(" new:s1, old:s2:with colon ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].ID != "new" || string(keys[0].Secret) != "s1" ||
+		keys[1].ID != "old" || string(keys[1].Secret) != "s2:with colon" {
+		t.Fatalf("got keys %+v", keys)
+	}
+
+	if _, err := ParseKeys("no-secret"); err == nil {
+		t.Fatal("got no error for a key without a secret")
+	}
+	if _, err := New("", time.Minute, []Key{{ID: "empty"}}); err == nil {
+		t.Fatal("got no error for an empty key")
+	}
+}
@@ -0,0 +1,186 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"project-service/internal/domain/models"
+	"time"
+)
+
+const (
+	sentRecordsTTL = 7 * 24 * time.Hour
+	relayLeaseId   = "relay"
+)
+
+// OutboxRepository keeps the records in collectionName and the relay lease in collectionName_leases.
+type OutboxRepository struct {
+	collection *mongo.Collection
+	leases     *mongo.Collection
+}
+
+func NewOutboxRepository(client *mongo.Client, dbName, collectionName string) *OutboxRepository {
+	database := client.Database(dbName)
+	return &OutboxRepository{
+		collection: database.Collection(collectionName),
+		leases:     database.Collection(collectionName + "_leases"),
+	}
+}
+
+func (r *OutboxRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "sentAt", Value: 1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "sentAt", Value: 1}, {Key: "nextAttemptAt", Value: 1}}},
+		{Keys: bson.D{{Key: "failedAt", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{
+			Keys:    bson.D{{Key: "sentAt", Value: 1}},
+			Options: options.Index().SetName("sentAt_ttl").SetExpireAfterSeconds(int32(sentRecordsTTL.Seconds())),
+		},
+	})
+	return err
+}
+
+func (r *OutboxRepository) Insert(ctx context.Context, record *models.OutboxRecord) error {
+	result, err := r.collection.InsertOne(ctx, record)
+	if err != nil {
+		return err
+	}
+
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		record.ID = id
+	}
+
+	return nil
+}
+
+// pending adds the conditions of records that are neither sent nor failed to filter.
+func pending(filter bson.M) bson.M {
+	filter["sentAt"] = bson.M{"$exists": false}
+	filter["failedAt"] = bson.M{"$exists": false}
+	return filter
+}
+
+// AcquireLease takes or renews the relay lease for owner until now+ttl. It reports false while another
+// owner holds a lease that has not expired.
+func (r *OutboxRepository) AcquireLease(ctx context.Context, owner string, now time.Time, ttl time.Duration) (bool, error) {
+	filter := bson.M{
+		"_id": relayLeaseId,
+		"$or": bson.A{
+			bson.M{"owner": owner},
+			bson.M{"expiresAt": bson.M{"$lte": primitive.NewDateTimeFromTime(now)}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"owner": owner, "expiresAt": primitive.NewDateTimeFromTime(now.Add(ttl))}}
+
+	_, err := r.leases.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// the lease exists and is held by another owner, so the upsert tried to insert it again
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// FetchPending returns pending records due for an attempt at now, in creation order. Keys with a record
+// still waiting for a retry, or marked failed, are left out entirely: that record is older than the
+// pending ones of its key, which must not overtake it. A key with a failed record stays blocked until
+// the record is removed or its failedAt is unset.
+func (r *OutboxRepository) FetchPending(ctx context.Context, now time.Time, limit int64) ([]*models.OutboxRecord, error) {
+	at := primitive.NewDateTimeFromTime(now)
+
+	waitingKeys, err := r.collection.Distinct(ctx, "key", bson.M{
+		"sentAt": bson.M{"$exists": false},
+		"$or": bson.A{
+			bson.M{"failedAt": bson.M{"$exists": true}},
+			bson.M{"nextAttemptAt": bson.M{"$gt": at}},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if waitingKeys == nil {
+		waitingKeys = []interface{}{}
+	}
+
+	filter := pending(bson.M{"nextAttemptAt": bson.M{"$lte": at}, "key": bson.M{"$nin": waitingKeys}})
+	opts := options.Find().
+		SetLimit(limit).
+		SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}})
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		_ = cursor.Close(ctx)
+	}(cursor, ctx)
+
+	var records []*models.OutboxRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func (r *OutboxRepository) MarkSent(ctx context.Context, id primitive.ObjectID, sentAt time.Time) error {
+	update := bson.M{
+		"$set":   bson.M{"sentAt": primitive.NewDateTimeFromTime(sentAt)},
+		"$unset": bson.M{"lastError": ""},
+	}
+	_, err := r.collection.UpdateByID(ctx, id, update)
+	return err
+}
+
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id primitive.ObjectID, nextAttemptAt time.Time, lastError string) error {
+	update := bson.M{
+		"$set": bson.M{
+			"nextAttemptAt": primitive.NewDateTimeFromTime(nextAttemptAt),
+			"lastError":     lastError,
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+	_, err := r.collection.UpdateByID(ctx, id, update)
+	return err
+}
+
+// MarkExhausted records the last failed attempt of a record that is not retried any more.
+func (r *OutboxRepository) MarkExhausted(ctx context.Context, id primitive.ObjectID, failedAt time.Time, lastError string) error {
+	update := bson.M{
+		"$set": bson.M{
+			"failedAt":  primitive.NewDateTimeFromTime(failedAt),
+			"lastError": lastError,
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+	_, err := r.collection.UpdateByID(ctx, id, update)
+	return err
+}
+
+func (r *OutboxRepository) PendingStats(ctx context.Context) (int64, *time.Time, error) {
+	filter := pending(bson.M{})
+
+	count, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var oldest models.OutboxRecord
+	opts := options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	err = r.collection.FindOne(ctx, filter, opts).Decode(&oldest)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return count, nil, nil
+		}
+		return 0, nil, err
+	}
+
+	createdAt := oldest.CreatedAt.Time()
+	return count, &createdAt, nil
+}
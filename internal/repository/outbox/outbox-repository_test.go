@@ -0,0 +1,95 @@
+package outbox
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"testing"
+	"time"
+)
+
+func TestFetchPendingLeavesOutKeysWaitingForARetry(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := primitive.NewDateTimeFromTime(now)
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("fetch", func(mt *mtest.T) {
+		r := NewOutboxRepository(mt.Client, "db", "outbox")
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "values", Value: bson.A{"alice_api"}}},
+			mtest.CreateCursorResponse(0, "db.outbox", mtest.FirstBatch),
+		)
+
+		if _, err := r.FetchPending(context.Background(), now, 10); err != nil {
+			mt.Fatal(err)
+		}
+
+		distinct := startedCommand(mt, "distinct")
+		waiting := distinct["query"].(bson.M)
+		or := waiting["$or"].(bson.A)
+		if len(or) != 2 || or[0].(bson.M)["failedAt"].(bson.M)["$exists"] != true ||
+			or[1].(bson.M)["nextAttemptAt"].(bson.M)["$gt"] != at || waiting["sentAt"].(bson.M)["$exists"] != false {
+			mt.Fatalf("got waiting records query %v, want unsent records that are failed or wait for a retry", waiting)
+		}
+
+		filter := startedCommand(mt, "find")["filter"].(bson.M)
+		if filter["nextAttemptAt"].(bson.M)["$lte"] != at {
+			mt.Fatalf("filter %v does not leave out records waiting for a retry", filter)
+		}
+		if keys := filter["key"].(bson.M)["$nin"].(bson.A); len(keys) != 1 || keys[0] != "alice_api" {
+			mt.Fatalf("filter %v does not leave out the waiting keys", filter)
+		}
+		if filter["failedAt"].(bson.M)["$exists"] != false || filter["sentAt"].(bson.M)["$exists"] != false {
+			mt.Fatalf("filter %v does not leave out sent and failed records", filter)
+		}
+	})
+}
+
+func TestAcquireLease(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("acquire", func(mt *mtest.T) {
+		r := NewOutboxRepository(mt.Client, "db", "outbox")
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Code: 11000, Message: "duplicate key"}),
+		)
+
+		if acquired, err := r.AcquireLease(context.Background(), "a", now, time.Minute); err != nil || !acquired {
+			mt.Fatalf("got acquired %v and error %v", acquired, err)
+		}
+		update := startedCommand(mt, "update")
+		if update["update"] != "outbox_leases" {
+			mt.Fatalf("got update of %v, want outbox_leases", update["update"])
+		}
+		statement := update["updates"].(bson.A)[0].(bson.M)
+		filter := statement["q"].(bson.M)
+		if filter["_id"] != "relay" || len(filter["$or"].(bson.A)) != 2 || statement["upsert"] != true {
+			mt.Fatalf("got lease update %v", statement)
+		}
+		set := statement["u"].(bson.M)["$set"].(bson.M)
+		if set["owner"] != "a" || set["expiresAt"] != primitive.NewDateTimeFromTime(now.Add(time.Minute)) {
+			mt.Fatalf("got lease %v", set)
+		}
+
+		if acquired, err := r.AcquireLease(context.Background(), "b", now, time.Minute); err != nil || acquired {
+			mt.Fatalf("got acquired %v and error %v for a lease held by another owner", acquired, err)
+		}
+	})
+}
+
+// startedCommand returns the next command the repository sent, which has to be name.
+func startedCommand(mt *mtest.T, name string) bson.M {
+	event := mt.GetStartedEvent()
+	if event == nil || event.CommandName != name {
+		mt.Fatalf("got command %v, want %s", event, name)
+	}
+
+	var command bson.M
+	if err := bson.Unmarshal(event.Command, &command); err != nil {
+		mt.Fatal(err)
+	}
+	return command
+}
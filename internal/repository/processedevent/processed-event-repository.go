@@ -0,0 +1,94 @@
+package processedevent
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"project-service/internal/domain/models"
+	"time"
+)
+
+// retention has to outlive any realistic redelivery or replay window
+const retention = 30 * 24 * time.Hour
+
+type ProcessedEventRepository struct {
+	collection *mongo.Collection
+}
+
+func NewProcessedEventRepository(client *mongo.Client, dbName, collectionName string) *ProcessedEventRepository {
+	collection := client.Database(dbName).Collection(collectionName)
+	return &ProcessedEventRepository{collection: collection}
+}
+
+func (r *ProcessedEventRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "processedAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(retention.Seconds())),
+	})
+	return err
+}
+
+// MarkProcessed records the event id and fails with models.ErrEventAlreadyProcessed if it is already known.
+// Called inside the mutation transaction it makes the mutation and the record atomic.
+func (r *ProcessedEventRepository) MarkProcessed(ctx context.Context, eventId string) error {
+	_, err := r.collection.InsertOne(ctx, bson.M{
+		"_id":         eventId,
+		"processedAt": primitive.NewDateTimeFromTime(time.Now()),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return models.ErrEventAlreadyProcessed
+	}
+
+	return err
+}
+
+// FindProcessed returns those of eventIds that are already recorded.
+func (r *ProcessedEventRepository) FindProcessed(ctx context.Context, eventIds []string) (map[string]bool, error) {
+	opts := options.Find().SetProjection(bson.M{"_id": 1})
+	cursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$in": eventIds}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		_ = cursor.Close(ctx)
+	}(cursor, ctx)
+
+	var records []struct {
+		Id string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	processed := make(map[string]bool, len(records))
+	for _, record := range records {
+		processed[record.Id] = true
+	}
+
+	return processed, nil
+}
+
+// MarkAllProcessed records the event ids like MarkProcessed does, with a single insert.
+func (r *ProcessedEventRepository) MarkAllProcessed(ctx context.Context, eventIds []string) error {
+	now := primitive.NewDateTimeFromTime(time.Now())
+	records := make([]interface{}, 0, len(eventIds))
+	for _, eventId := range eventIds {
+		records = append(records, bson.M{"_id": eventId, "processedAt": now})
+	}
+
+	_, err := r.collection.InsertMany(ctx, records)
+	if mongo.IsDuplicateKeyError(err) {
+		return models.ErrEventAlreadyProcessed
+	}
+
+	return err
+}
+
+// ForgetProcessed removes the records of eventIds, so that the events are applied again when they are
+// consumed next, e.g. by a replay that reprocesses them.
+func (r *ProcessedEventRepository) ForgetProcessed(ctx context.Context, eventIds []string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": eventIds}})
+	return err
+}
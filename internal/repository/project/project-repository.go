@@ -3,6 +3,7 @@ package project
 import (
 	"context"
 	"errors"
+	"fmt"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -78,21 +79,29 @@ func (r *ProjectRepository) GetFilteredProjects(ctx context.Context, owner, stat
 	return projects, nil
 }
 
-func (r *ProjectRepository) DeleteProject(ctx context.Context, composeId string) error {
-	result, err := r.collection.DeleteOne(ctx, bson.M{"composeId": composeId})
+// ownedBy matches the project with composeId of owner. The compose id alone is ambiguous when names contain
+// "_": alice's "smith_api" and alice_smith's "api" are both alice_smith_api.
+func ownedBy(composeId, owner string) bson.M {
+	return bson.M{"composeId": composeId, "owner": owner}
+}
+
+func (r *ProjectRepository) DeleteProject(ctx context.Context, composeId, owner string) error {
+	result, err := r.collection.DeleteOne(ctx, ownedBy(composeId, owner))
 	if err != nil {
 		return err
 	}
 
 	if result.DeletedCount == 0 {
-		return errors.New("проект не найден")
+		return models.ErrProjectNotFound
 	}
 
 	return nil
 }
 
+// InitProject refuses a compose id that is taken, also by a project of another owner, so that consumed
+// events, which only carry the compose id, always name a single project.
 func (r *ProjectRepository) InitProject(ctx context.Context, composeId, owner, name string) (*models.Project, error) {
-	existingProject, err := r.getProjectByComposeId(ctx, composeId)
+	existingProject, err := r.findProject(ctx, bson.M{"composeId": composeId})
 	if err != nil {
 		return nil, err
 	}
@@ -100,16 +109,16 @@ func (r *ProjectRepository) InitProject(ctx context.Context, composeId, owner, n
 		return nil, errors.New("проект с таким названием уже существует для данного пользователя")
 	}
 
+	now := primitive.NewDateTimeFromTime(time.Now())
 	project := &models.Project{
-		ComposeId: composeId,
-		Owner:     owner,
-		Name:      name,
-		Data:      "",
-		Status:    "NEW",
-		UrlZip:    "",
-		UrlDeploy: "",
-		UpdatedAt: primitive.NewDateTimeFromTime(time.Now()),
-		CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+		ComposeId:       composeId,
+		Owner:           owner,
+		Name:            name,
+		Data:            "",
+		Status:          models.StatusNew,
+		StatusUpdatedAt: now,
+		UpdatedAt:       now,
+		CreatedAt:       now,
 	}
 
 	_, err = r.collection.InsertOne(ctx, project)
@@ -120,46 +129,210 @@ func (r *ProjectRepository) InitProject(ctx context.Context, composeId, owner, n
 	return project, nil
 }
 
-func (r *ProjectRepository) UpdateProject(ctx context.Context, composeId string, data string) (*models.Project, error) {
-	existingProject, err := r.getProjectByComposeId(ctx, composeId)
+func (r *ProjectRepository) UpdateProject(ctx context.Context, composeId, owner string, data string) (*models.Project, error) {
+	existingProject, err := r.findProject(ctx, ownedBy(composeId, owner))
 	if err != nil {
 		return nil, err
 	}
 	if existingProject == nil {
-		return nil, errors.New("проект не найден")
+		return nil, models.ErrProjectNotFound
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"data":      data,
+			"updatedAt": primitive.NewDateTimeFromTime(time.Now()),
+		},
+		"$inc": bson.M{"revision": 1},
+	}
+	_, err = r.collection.UpdateOne(ctx, ownedBy(composeId, owner), update)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.findProject(ctx, ownedBy(composeId, owner))
+}
+
+func (r *ProjectRepository) GetProject(ctx context.Context, composeId, owner string) (*models.Project, error) {
+	return r.getProject(ctx, ownedBy(composeId, owner))
+}
+
+func (r *ProjectRepository) getProject(ctx context.Context, filter bson.M) (*models.Project, error) {
+	project, err := r.findProject(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if project == nil {
+		return nil, models.ErrProjectNotFound
 	}
 
-	update := bson.M{"$set": bson.M{
-		"data":      data,
-		"updatedAt": primitive.NewDateTimeFromTime(time.Now()),
-	}}
-	_, err = r.collection.UpdateOne(ctx, bson.M{"composeId": composeId}, update)
+	return project, nil
+}
+
+// GetProjects returns the projects with the given compose ids by compose id, unknown ids are left out.
+func (r *ProjectRepository) GetProjects(ctx context.Context, composeIds []string) (map[string]*models.Project, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"composeId": bson.M{"$in": composeIds}})
 	if err != nil {
 		return nil, err
 	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		_ = cursor.Close(ctx)
+	}(cursor, ctx)
+
+	var projects []*models.Project
+	if err := cursor.All(ctx, &projects); err != nil {
+		return nil, err
+	}
+
+	byComposeId := make(map[string]*models.Project, len(projects))
+	for _, project := range projects {
+		byComposeId[project.ComposeId] = project
+	}
+
+	return byComposeId, nil
+}
+
+// SaveConsumedState writes the fields consumed events change, status, artifacts and environments,
+// of all projects with a single bulk write. The projects have to be read in the same transaction.
+func (r *ProjectRepository) SaveConsumedState(ctx context.Context, projects []*models.Project) error {
+	writes := make([]mongo.WriteModel, 0, len(projects))
+	for _, project := range projects {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(ownedBy(project.ComposeId, project.Owner)).
+			SetUpdate(bson.M{"$set": bson.M{
+				"status":          project.Status,
+				"statusUpdatedAt": project.StatusUpdatedAt,
+				"artifacts":       project.Artifacts,
+				"environments":    project.Environments,
+			}}))
+	}
+
+	_, err := r.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// UpdateProjectStatus applies the status only if the state machine allows it from the current one.
+// When jobId is set, updates for other generation jobs fail with ErrStaleStatusUpdate. An update to the
+// status the project is already in, as a repeated progress event, fails with ErrStatusUnchanged. Status
+// events only carry the compose id, which InitProject keeps unique.
+func (r *ProjectRepository) UpdateProjectStatus(ctx context.Context, composeId string, status string, jobId string) (*models.Project, error) {
+	filter := bson.M{}
+	if jobId != "" {
+		filter["generationJobId"] = jobId
+	}
+
+	updatedProject, err := r.transitionStatus(ctx, bson.M{"composeId": composeId}, status, filter, bson.M{})
+	if errors.Is(err, models.ErrInvalidStatusTransition) {
+		current, getErr := r.getProject(ctx, bson.M{"composeId": composeId})
+		switch {
+		case getErr != nil:
+		case jobId != "" && current.GenerationJobId != jobId:
+			return nil, models.ErrStaleStatusUpdate
+		case current.Status == status:
+			return nil, models.ErrStatusUnchanged
+		}
+	}
+
+	return updatedProject, err
+}
 
-	return r.getProjectByComposeId(ctx, composeId)
+// MarkStuck moves a project to status unless its status has changed since statusUpdatedAt.
+func (r *ProjectRepository) MarkStuck(ctx context.Context, composeId, owner string, status string, statusUpdatedAt primitive.DateTime) (*models.Project, error) {
+	return r.transitionStatus(ctx, ownedBy(composeId, owner), status, bson.M{"statusUpdatedAt": statusUpdatedAt}, bson.M{})
 }
 
-func (r *ProjectRepository) UpdateProjectStatus(ctx context.Context, composeId string, status string) (*models.Project, error) {
+// FindStuckProjects returns up to limit projects that have been in status since before, longest first.
+// An empty owner matches all owners.
+func (r *ProjectRepository) FindStuckProjects(ctx context.Context, status string, before time.Time, owner string, limit int64) ([]*models.Project, error) {
+	filter := bson.M{
+		"status":          status,
+		"statusUpdatedAt": bson.M{"$lt": primitive.NewDateTimeFromTime(before)},
+	}
+	if owner != "" {
+		filter["owner"] = owner
+	}
+
+	opts := options.Find().
+		SetLimit(limit).
+		SetSort(bson.M{"statusUpdatedAt": 1})
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		_ = cursor.Close(ctx)
+	}(cursor, ctx)
+
+	var projects []*models.Project
+	if err := cursor.All(ctx, &projects); err != nil {
+		return nil, err
+	}
+
+	return projects, nil
+}
+
+func (r *ProjectRepository) QueueGeneration(ctx context.Context, composeId, owner string, jobId string, revision int64) (*models.Project, error) {
+	filter := bson.M{"revision": revision}
+	if revision == 0 {
+		// documents created before revisions were tracked have no such field
+		filter["revision"] = bson.M{"$in": bson.A{0, nil}}
+	}
+	set := bson.M{
+		"generationJobId":    jobId,
+		"generationRevision": revision,
+		"updatedAt":          primitive.NewDateTimeFromTime(time.Now()),
+	}
+
+	return r.transitionStatus(ctx, ownedBy(composeId, owner), models.StatusQueued, filter, set)
+}
+
+// transitionStatus moves the project matching project to status if filter matches it as well.
+// Projects with a status outside the state machine move like new ones, see models.CanTransition.
+func (r *ProjectRepository) transitionStatus(ctx context.Context, project bson.M, status string, filter, set bson.M) (*models.Project, error) {
+	for field, value := range project {
+		filter[field] = value
+	}
+	allowed := bson.A{bson.M{"status": bson.M{"$in": models.StatusesAllowedBefore(status)}}}
+	if models.CanTransition(models.StatusNew, status) {
+		// missing statuses match $nin as well
+		allowed = append(allowed, bson.M{"status": bson.M{"$nin": models.KnownStatuses()}})
+	}
+	filter["$or"] = allowed
+	set["status"] = status
+	set["statusUpdatedAt"] = primitive.NewDateTimeFromTime(time.Now())
+
 	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
-	update := bson.M{"$set": bson.M{"status": status}}
 
 	var updatedProject models.Project
-	err := r.collection.FindOneAndUpdate(ctx, bson.M{"composeId": composeId}, update, opts).Decode(&updatedProject)
+	err := r.collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": set}, opts).Decode(&updatedProject)
+	if err == nil {
+		return &updatedProject, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+
+	current, err := r.getProject(ctx, project)
 	if err != nil {
 		return nil, err
 	}
 
-	return &updatedProject, nil
+	return nil, fmt.Errorf("%w: %s -> %s", models.ErrInvalidStatusTransition, current.Status, status)
 }
 
-func (r *ProjectRepository) UpdateProjectUrlZip(ctx context.Context, composeId string, url string) (*models.Project, error) {
+func (r *ProjectRepository) AddProjectArtifact(ctx context.Context, composeId, owner string, artifact models.ZipArtifact) (*models.Project, error) {
 	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
-	update := bson.M{"$set": bson.M{"urlZip": url}}
+	update := bson.M{"$push": bson.M{"artifacts": bson.M{
+		"$each":  bson.A{artifact},
+		"$slice": -models.MaxStoredArtifacts,
+	}}}
 
 	var updatedProject models.Project
-	err := r.collection.FindOneAndUpdate(ctx, bson.M{"composeId": composeId}, update, opts).Decode(&updatedProject)
+	err := r.collection.FindOneAndUpdate(ctx, ownedBy(composeId, owner), update, opts).Decode(&updatedProject)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, models.ErrProjectNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -167,12 +340,15 @@ func (r *ProjectRepository) UpdateProjectUrlZip(ctx context.Context, composeId s
 	return &updatedProject, nil
 }
 
-func (r *ProjectRepository) UpdateProjectUrlDeploy(ctx context.Context, composeId string, url string) (*models.Project, error) {
+func (r *ProjectRepository) UpdateEnvironment(ctx context.Context, composeId, owner string, name string, environment models.DeployEnvironment) (*models.Project, error) {
 	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
-	update := bson.M{"$set": bson.M{"urlDeploy": url}}
+	update := bson.M{"$set": bson.M{"environments." + name: environment}}
 
 	var updatedProject models.Project
-	err := r.collection.FindOneAndUpdate(ctx, bson.M{"composeId": composeId}, update, opts).Decode(&updatedProject)
+	err := r.collection.FindOneAndUpdate(ctx, ownedBy(composeId, owner), update, opts).Decode(&updatedProject)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, models.ErrProjectNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -180,9 +356,82 @@ func (r *ProjectRepository) UpdateProjectUrlDeploy(ctx context.Context, composeI
 	return &updatedProject, nil
 }
 
-func (r *ProjectRepository) getProjectByComposeId(ctx context.Context, composeId string) (*models.Project, error) {
+// MigrateLegacyFields moves the single urlZip and urlDeploy fields of old documents
+// into the artifacts list and the default environment, and dates their status by their last update.
+func (r *ProjectRepository) MigrateLegacyFields(ctx context.Context) error {
+	if err := r.migrateLegacyUrlZip(ctx); err != nil {
+		return err
+	}
+	if err := r.migrateLegacyUrlDeploy(ctx); err != nil {
+		return err
+	}
+
+	return r.migrateStatusUpdatedAt(ctx)
+}
+
+func (r *ProjectRepository) migrateLegacyUrlZip(ctx context.Context) error {
+	filter := bson.M{
+		"urlZip":    bson.M{"$exists": true},
+		"artifacts": bson.M{"$exists": false},
+	}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"artifacts": bson.M{"$cond": bson.A{
+				bson.M{"$in": bson.A{"$urlZip", bson.A{"", nil}}},
+				bson.A{},
+				bson.A{bson.M{
+					"url":       "$urlZip",
+					"revision":  "$revision",
+					"createdAt": "$updatedAt",
+				}},
+			}},
+		}}},
+		{{Key: "$unset", Value: "urlZip"}},
+	}
+
+	_, err := r.collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+func (r *ProjectRepository) migrateLegacyUrlDeploy(ctx context.Context) error {
+	filter := bson.M{
+		"urlDeploy":    bson.M{"$exists": true},
+		"environments": bson.M{"$exists": false},
+	}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"environments": bson.M{"$cond": bson.A{
+				bson.M{"$in": bson.A{"$urlDeploy", bson.A{"", nil}}},
+				bson.M{},
+				bson.M{models.DefaultEnvironment: bson.M{
+					"urlDeploy":         "$urlDeploy",
+					"status":            models.DeployStatusDeployed,
+					"revision":          "$revision",
+					"requestedRevision": "$revision",
+					"updatedAt":         "$updatedAt",
+				}},
+			}},
+		}}},
+		{{Key: "$unset", Value: "urlDeploy"}},
+	}
+
+	_, err := r.collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+func (r *ProjectRepository) migrateStatusUpdatedAt(ctx context.Context) error {
+	filter := bson.M{"statusUpdatedAt": bson.M{"$exists": false}}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"statusUpdatedAt": "$updatedAt"}}},
+	}
+
+	_, err := r.collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+func (r *ProjectRepository) findProject(ctx context.Context, filter bson.M) (*models.Project, error) {
 	var project *models.Project
-	err := r.collection.FindOne(ctx, bson.M{"composeId": composeId}).Decode(&project)
+	err := r.collection.FindOne(ctx, filter).Decode(&project)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, nil
@@ -0,0 +1,184 @@
+package project
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"project-service/internal/domain/models"
+	"strings"
+	"testing"
+)
+
+// alice's "smith_api" and alice_smith's "api" share the compose id alice_smith_api.
+var foreignProject = bson.M{"composeId": "alice_smith_api", "owner": "alice_smith", "name": "api"}
+
+func TestOwnerScopedCallsDoNotReachProjectsOfOtherOwners(t *testing.T) {
+	ctx := context.Background()
+	empty := mtest.CreateCursorResponse(0, "db.projects", mtest.FirstBatch)
+	notModified := bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}}
+	missing := bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}}
+
+	tests := []struct {
+		name      string
+		responses []bson.D
+		call      func(r *ProjectRepository, owner string) error
+	}{
+		{
+			name:      "get",
+			responses: []bson.D{empty},
+			call: func(r *ProjectRepository, owner string) error {
+				_, err := r.GetProject(ctx, "alice_smith_api", owner)
+				return err
+			},
+		},
+		{
+			name:      "update",
+			responses: []bson.D{empty},
+			call: func(r *ProjectRepository, owner string) error {
+				_, err := r.UpdateProject(ctx, "alice_smith_api", owner, "{}")
+				return err
+			},
+		},
+		{
+			name:      "delete",
+			responses: []bson.D{missing},
+			call: func(r *ProjectRepository, owner string) error {
+				return r.DeleteProject(ctx, "alice_smith_api", owner)
+			},
+		},
+		{
+			name:      "queue generation",
+			responses: []bson.D{notModified, empty},
+			call: func(r *ProjectRepository, owner string) error {
+				_, err := r.QueueGeneration(ctx, "alice_smith_api", owner, "job", 1)
+				return err
+			},
+		},
+		{
+			name:      "mark stuck",
+			responses: []bson.D{notModified, empty},
+			call: func(r *ProjectRepository, owner string) error {
+				_, err := r.MarkStuck(ctx, "alice_smith_api", owner, models.StatusStale, primitive.DateTime(0))
+				return err
+			},
+		},
+		{
+			name:      "add artifact",
+			responses: []bson.D{notModified},
+			call: func(r *ProjectRepository, owner string) error {
+				_, err := r.AddProjectArtifact(ctx, "alice_smith_api", owner, models.ZipArtifact{Revision: 1})
+				return err
+			},
+		},
+		{
+			name:      "update environment",
+			responses: []bson.D{notModified},
+			call: func(r *ProjectRepository, owner string) error {
+				_, err := r.UpdateEnvironment(ctx, "alice_smith_api", owner, models.DefaultEnvironment, models.DeployEnvironment{})
+				return err
+			},
+		},
+	}
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			r := NewProjectRepository(mt.Client, "db", "projects")
+			mt.AddMockResponses(tt.responses...)
+
+			err := tt.call(r, "alice")
+			if !errors.Is(err, models.ErrProjectNotFound) {
+				mt.Fatalf("got error %v, want %v", err, models.ErrProjectNotFound)
+			}
+
+			filter := sentFilter(mt)
+			if matches(filter, foreignProject) {
+				mt.Fatalf("filter %v of alice matches the project of alice_smith", filter)
+			}
+			if filter["owner"] != "alice" || filter["composeId"] != "alice_smith_api" {
+				mt.Fatalf("filter %v does not name the project of alice", filter)
+			}
+		})
+	}
+}
+
+// sentFilter returns the filter of the first command the repository sent.
+func sentFilter(mt *mtest.T) bson.M {
+	event := mt.GetStartedEvent()
+	if event == nil {
+		mt.Fatal("no command was sent")
+	}
+
+	var command bson.M
+	if err := bson.Unmarshal(event.Command, &command); err != nil {
+		mt.Fatal(err)
+	}
+
+	var filter interface{}
+	switch event.CommandName {
+	case "find":
+		filter = command["filter"]
+	case "findAndModify":
+		filter = command["query"]
+	case "delete":
+		filter = command["deletes"].(bson.A)[0].(bson.M)["q"]
+	case "update":
+		filter = command["updates"].(bson.A)[0].(bson.M)["q"]
+	default:
+		mt.Fatalf("unexpected command %s", event.CommandName)
+	}
+
+	return filter.(bson.M)
+}
+
+// matches reports whether the plain equality conditions of filter hold for document.
+func matches(filter bson.M, document bson.M) bool {
+	for field, value := range filter {
+		if _, isOperator := value.(bson.M); isOperator || strings.HasPrefix(field, "$") {
+			continue
+		}
+		if document[field] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func TestUpdateProjectStatusExplainsRejectedUpdates(t *testing.T) {
+	notModified := bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}}
+	current := func(status, jobId string) bson.D {
+		return mtest.CreateCursorResponse(0, "db.projects", mtest.FirstBatch, bson.D{
+			{Key: "composeId", Value: "alice_api"}, {Key: "owner", Value: "alice"}, {Key: "name", Value: "api"},
+			{Key: "status", Value: status}, {Key: "generationJobId", Value: jobId},
+		})
+	}
+
+	tests := []struct {
+		name    string
+		current bson.D
+		status  string
+		jobId   string
+		err     error
+	}{
+		{name: "same status", current: current(models.StatusGenerating, "job"), status: models.StatusGenerating, err: models.ErrStatusUnchanged},
+		{name: "same status of the job", current: current(models.StatusGenerated, "job"), status: models.StatusGenerated, jobId: "job", err: models.ErrStatusUnchanged},
+		{name: "superseded job", current: current(models.StatusGenerating, "new-job"), status: models.StatusGenerating, jobId: "job", err: models.ErrStaleStatusUpdate},
+		{name: "regression", current: current(models.StatusGenerated, "job"), status: models.StatusGenerating, err: models.ErrInvalidStatusTransition},
+	}
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			r := NewProjectRepository(mt.Client, "db", "projects")
+			// transitionStatus and UpdateProjectStatus both look the project up after the rejected update
+			mt.AddMockResponses(notModified, tt.current, tt.current)
+
+			_, err := r.UpdateProjectStatus(context.Background(), "alice_api", tt.status, tt.jobId)
+			if !errors.Is(err, tt.err) {
+				mt.Fatalf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
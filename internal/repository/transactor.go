@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type Transactor struct {
+	client *mongo.Client
+}
+
+func NewTransactor(client *mongo.Client) *Transactor {
+	return &Transactor{client: client}
+}
+
+// WithTransaction runs fn inside a Mongo transaction. Repository calls made with the ctx passed
+// to fn join the transaction; fn may be retried by the driver on transient errors.
+func (t *Transactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	session, err := t.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+
+	return err
+}
@@ -0,0 +1,199 @@
+package outboxservice
+
+import (
+	"context"
+	"expvar"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log/slog"
+	"os"
+	"project-service/internal/config"
+	"project-service/internal/domain/models"
+	"project-service/internal/lib/backoff"
+	"time"
+)
+
+var (
+	outboxPending    = expvar.NewInt("outbox_pending")
+	outboxLagSeconds = expvar.NewFloat("outbox_lag_seconds")
+	outboxSent       = expvar.NewInt("outbox_sent_total")
+	outboxFailed     = expvar.NewInt("outbox_failed_total")
+	outboxExhausted  = expvar.NewInt("outbox_exhausted_total")
+)
+
+type Producer interface {
+	Produce(ctx context.Context, topic, key string, value []byte) error
+}
+
+type OutboxRepository interface {
+	// AcquireLease takes or renews the relay lease for owner until now+ttl, false while another owner holds it.
+	AcquireLease(ctx context.Context, owner string, now time.Time, ttl time.Duration) (bool, error)
+	// FetchPending returns pending records due at now in creation order, leaving out keys with a record
+	// that waits for a retry or is marked failed.
+	FetchPending(ctx context.Context, now time.Time, limit int64) ([]*models.OutboxRecord, error)
+	MarkSent(ctx context.Context, id primitive.ObjectID, sentAt time.Time) error
+	MarkFailed(ctx context.Context, id primitive.ObjectID, nextAttemptAt time.Time, lastError string) error
+	MarkExhausted(ctx context.Context, id primitive.ObjectID, failedAt time.Time, lastError string) error
+	PendingStats(ctx context.Context) (int64, *time.Time, error)
+}
+
+type Relay struct {
+	log              *slog.Logger
+	outboxRepository OutboxRepository
+	producer         Producer
+	pollInterval     time.Duration
+	batchSize        int64
+	maxAttempts      int
+	initialBackoff   time.Duration
+	maxBackoff       time.Duration
+	leaseOwner       string
+	leaseTTL         time.Duration
+	leader           bool
+	now              func() time.Time
+}
+
+func NewRelay(
+	log *slog.Logger,
+	cfg config.OutboxConfig,
+	outboxRepository OutboxRepository,
+	producer Producer,
+) *Relay {
+	return &Relay{
+		log:              log,
+		outboxRepository: outboxRepository,
+		producer:         producer,
+		pollInterval:     cfg.PollInterval,
+		batchSize:        cfg.BatchSize,
+		maxAttempts:      max(cfg.MaxAttempts, 1),
+		initialBackoff:   cfg.InitialBackoff,
+		maxBackoff:       cfg.MaxBackoff,
+		leaseOwner:       leaseOwner(),
+		leaseTTL:         cfg.LeaseTTL,
+		now:              time.Now,
+	}
+}
+
+// leaseOwner identifies this relay among the replicas.
+func leaseOwner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "relay"
+	}
+	return host + "-" + primitive.NewObjectID().Hex()
+}
+
+func (r *Relay) Run(ctx context.Context) {
+	r.log.Info("outbox relay started")
+
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if r.acquireLease(ctx) {
+			if _, err := r.RelayPending(ctx); err != nil {
+				r.log.Error("ошибка при отправке событий из outbox", "error", err)
+			}
+		}
+		r.reportLag(ctx)
+
+		select {
+		case <-ctx.Done():
+			r.log.Info("outbox relay stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// acquireLease reports whether this relay holds the lease and may publish. Only one replica publishes
+// at a time, so records are neither sent twice nor out of order across replicas.
+func (r *Relay) acquireLease(ctx context.Context) bool {
+	leader, err := r.outboxRepository.AcquireLease(ctx, r.leaseOwner, r.now(), r.leaseTTL)
+	if err != nil {
+		r.log.Error("ошибка при получении аренды outbox", "error", err)
+		leader = false
+	}
+
+	if leader != r.leader {
+		if leader {
+			r.log.Info("outbox relay took the lease", "owner", r.leaseOwner)
+		} else {
+			r.log.Info("outbox relay gave up the lease", "owner", r.leaseOwner)
+		}
+		r.leader = leader
+	}
+
+	return leader
+}
+
+// RelayPending publishes one batch of records due for an attempt and returns how many were sent.
+// Once a record of some key fails, later records of that key are held back until it is sent. After
+// maxAttempts attempts it is marked failed, which blocks its key until an operator resolves it.
+func (r *Relay) RelayPending(ctx context.Context) (int, error) {
+	records, err := r.outboxRepository.FetchPending(ctx, r.now(), r.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	blockedKeys := make(map[string]struct{})
+	for _, record := range records {
+		if _, blocked := blockedKeys[record.Key]; blocked {
+			continue
+		}
+
+		if err := r.producer.Produce(ctx, record.Topic, record.Key, record.Value); err != nil {
+			blockedKeys[record.Key] = struct{}{}
+			outboxFailed.Add(1)
+			r.log.Warn("не удалось отправить событие из outbox",
+				"topic", record.Topic,
+				"key", record.Key,
+				"attempts", record.Attempts+1,
+				"error", err,
+			)
+
+			if record.Attempts+1 >= r.maxAttempts {
+				outboxExhausted.Add(1)
+				r.log.Error("событие из outbox не отправлено за все попытки и помечено как неотправленное",
+					"id", record.ID.Hex(),
+					"topic", record.Topic,
+					"key", record.Key,
+					"attempts", record.Attempts+1,
+					"error", err,
+				)
+				if err := r.outboxRepository.MarkExhausted(ctx, record.ID, r.now(), err.Error()); err != nil {
+					return sent, err
+				}
+				continue
+			}
+
+			nextAttemptAt := r.now().Add(backoff.Exponential(r.initialBackoff, r.maxBackoff, record.Attempts+1))
+			if err := r.outboxRepository.MarkFailed(ctx, record.ID, nextAttemptAt, err.Error()); err != nil {
+				return sent, err
+			}
+			continue
+		}
+
+		if err := r.outboxRepository.MarkSent(ctx, record.ID, r.now()); err != nil {
+			return sent, err
+		}
+		outboxSent.Add(1)
+		sent++
+	}
+
+	return sent, nil
+}
+
+func (r *Relay) reportLag(ctx context.Context) {
+	pending, oldest, err := r.outboxRepository.PendingStats(ctx)
+	if err != nil {
+		r.log.Error("ошибка при получении статистики outbox", "error", err)
+		return
+	}
+
+	outboxPending.Set(pending)
+	if oldest == nil {
+		outboxLagSeconds.Set(0)
+		return
+	}
+	outboxLagSeconds.Set(r.now().Sub(*oldest).Seconds())
+}
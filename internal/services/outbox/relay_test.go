@@ -0,0 +1,244 @@
+package outboxservice
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"log/slog"
+	"project-service/internal/config"
+	"project-service/internal/domain/models"
+	"sort"
+	"testing"
+	"time"
+)
+
+// memoryOutbox keeps the records in creation order and fetches them like OutboxRepository does.
+type memoryOutbox struct {
+	records         []*models.OutboxRecord
+	leaseOwner      string
+	leaseExpiration time.Time
+}
+
+func (o *memoryOutbox) AcquireLease(_ context.Context, owner string, now time.Time, ttl time.Duration) (bool, error) {
+	if o.leaseOwner != owner && now.Before(o.leaseExpiration) {
+		return false, nil
+	}
+	o.leaseOwner, o.leaseExpiration = owner, now.Add(ttl)
+	return true, nil
+}
+
+func (o *memoryOutbox) add(key, value string, createdAt time.Time) {
+	o.records = append(o.records, &models.OutboxRecord{
+		ID:            primitive.NewObjectID(),
+		Topic:         "events",
+		Key:           key,
+		Value:         []byte(value),
+		NextAttemptAt: primitive.NewDateTimeFromTime(createdAt),
+		CreatedAt:     primitive.NewDateTimeFromTime(createdAt),
+	})
+}
+
+func (o *memoryOutbox) pending() []*models.OutboxRecord {
+	var pending []*models.OutboxRecord
+	for _, record := range o.records {
+		if record.SentAt == nil && record.FailedAt == nil {
+			pending = append(pending, record)
+		}
+	}
+	return pending
+}
+
+func (o *memoryOutbox) FetchPending(_ context.Context, now time.Time, limit int64) ([]*models.OutboxRecord, error) {
+	waiting := make(map[string]bool)
+	for _, record := range o.records {
+		if record.SentAt == nil && (record.FailedAt != nil || record.NextAttemptAt.Time().After(now)) {
+			waiting[record.Key] = true
+		}
+	}
+
+	var due []*models.OutboxRecord
+	for _, record := range o.pending() {
+		if !waiting[record.Key] && int64(len(due)) < limit {
+			copied := *record
+			due = append(due, &copied)
+		}
+	}
+	return due, nil
+}
+
+func (o *memoryOutbox) find(id primitive.ObjectID) *models.OutboxRecord {
+	for _, record := range o.records {
+		if record.ID == id {
+			return record
+		}
+	}
+	return nil
+}
+
+func (o *memoryOutbox) MarkSent(_ context.Context, id primitive.ObjectID, sentAt time.Time) error {
+	at := primitive.NewDateTimeFromTime(sentAt)
+	o.find(id).SentAt = &at
+	return nil
+}
+
+func (o *memoryOutbox) MarkFailed(_ context.Context, id primitive.ObjectID, nextAttemptAt time.Time, lastError string) error {
+	record := o.find(id)
+	record.Attempts++
+	record.NextAttemptAt = primitive.NewDateTimeFromTime(nextAttemptAt)
+	record.LastError = lastError
+	return nil
+}
+
+func (o *memoryOutbox) MarkExhausted(_ context.Context, id primitive.ObjectID, failedAt time.Time, lastError string) error {
+	record := o.find(id)
+	record.Attempts++
+	at := primitive.NewDateTimeFromTime(failedAt)
+	record.FailedAt = &at
+	record.LastError = lastError
+	return nil
+}
+
+func (o *memoryOutbox) PendingStats(context.Context) (int64, *time.Time, error) {
+	pending := o.pending()
+	if len(pending) == 0 {
+		return 0, nil, nil
+	}
+	oldest := pending[0].CreatedAt.Time()
+	return int64(len(pending)), &oldest, nil
+}
+
+// memoryProducer fails the values in failing and records the others.
+type memoryProducer struct {
+	failing  map[string]bool
+	produced []string
+}
+
+func (p *memoryProducer) Produce(_ context.Context, _, _ string, value []byte) error {
+	if p.failing[string(value)] {
+		return errors.New("broker is unavailable")
+	}
+	p.produced = append(p.produced, string(value))
+	return nil
+}
+
+func newTestRelay(outbox *memoryOutbox, producer *memoryProducer, now *time.Time) *Relay {
+	relay := NewRelay(slog.New(slog.NewTextHandler(io.Discard, nil)), config.OutboxConfig{
+		BatchSize:      2,
+		MaxAttempts:    2,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Second,
+		LeaseTTL:       time.Minute,
+	}, outbox, producer)
+	relay.now = func() time.Time { return *now }
+	return relay
+}
+
+func TestRelayPendingDoesNotLetAFailingKeyHoldUpOthers(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	outbox := &memoryOutbox{}
+	for _, value := range []string{"a1", "a2", "a3"} {
+		outbox.add("a", value, now)
+	}
+	outbox.add("b", "b1", now)
+	outbox.add("a", "a4", now)
+	producer := &memoryProducer{failing: map[string]bool{"a1": true}}
+	relay := newTestRelay(outbox, producer, &now)
+
+	for range 2 {
+		if _, err := relay.RelayPending(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(producer.produced) != 1 || producer.produced[0] != "b1" {
+		t.Fatalf("got produced %v, want b1 while a1 waits for its retry", producer.produced)
+	}
+}
+
+func TestRelayPendingMarksRecordsFailedAfterMaxAttempts(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	outbox := &memoryOutbox{}
+	outbox.add("a", "a1", now)
+	outbox.add("a", "a2", now)
+	outbox.add("b", "b1", now)
+	producer := &memoryProducer{failing: map[string]bool{"a1": true}}
+	relay := newTestRelay(outbox, producer, &now)
+
+	for range 3 {
+		if _, err := relay.RelayPending(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Minute)
+	}
+
+	failed := outbox.records[0]
+	if failed.FailedAt == nil || failed.Attempts != 2 || failed.LastError == "" {
+		t.Fatalf("got a1 %+v, want it failed after 2 attempts", failed)
+	}
+	if len(producer.produced) != 1 || producer.produced[0] != "b1" {
+		t.Fatalf("got produced %v, want only the other key while a1 is failed", producer.produced)
+	}
+	if pending, _, _ := outbox.PendingStats(context.Background()); pending != 1 {
+		t.Fatalf("got %d pending records, want a2 held back", pending)
+	}
+}
+
+func TestOnlyTheLeaseHolderRelays(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	outbox := &memoryOutbox{}
+	first := newTestRelay(outbox, &memoryProducer{}, &now)
+	second := newTestRelay(outbox, &memoryProducer{}, &now)
+	ctx := context.Background()
+
+	if !first.acquireLease(ctx) || second.acquireLease(ctx) {
+		t.Fatal("got both relays or none holding the lease")
+	}
+
+	// the holder renews its lease, the other takes it over once it stops renewing
+	now = now.Add(59 * time.Second)
+	if !first.acquireLease(ctx) {
+		t.Fatal("the holder could not renew its lease")
+	}
+	now = now.Add(59 * time.Second)
+	if second.acquireLease(ctx) {
+		t.Fatal("took over a renewed lease")
+	}
+	now = now.Add(time.Minute)
+	if !second.acquireLease(ctx) || first.acquireLease(ctx) {
+		t.Fatal("the expired lease was not taken over")
+	}
+}
+
+func TestRelayPendingKeepsTheOrderOfAKey(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	outbox := &memoryOutbox{}
+	for _, value := range []string{"a1", "b1", "a2", "b2", "a3"} {
+		outbox.add(value[:1], value, now)
+	}
+	producer := &memoryProducer{failing: map[string]bool{"a1": true}}
+	relay := newTestRelay(outbox, producer, &now)
+
+	for range 4 {
+		if _, err := relay.RelayPending(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	delete(producer.failing, "a1")
+	now = now.Add(time.Minute)
+	for range 2 {
+		if _, err := relay.RelayPending(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var a []string
+	for _, value := range producer.produced {
+		if value[0] == 'a' {
+			a = append(a, value)
+		}
+	}
+	if !sort.StringsAreSorted(a) || len(a) != 3 || len(producer.produced) != 5 {
+		t.Fatalf("got produced %v, want all records with those of a in order", producer.produced)
+	}
+}
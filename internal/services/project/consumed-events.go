@@ -0,0 +1,388 @@
+package projectservice
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"project-service/internal/domain/models"
+	"project-service/internal/dto"
+	"time"
+)
+
+// ApplyProjectEvents applies a batch of consumed events with one transaction and one bulk write.
+// The events of a project are folded in order through the state machine, so of a burst of status
+// updates only the last valid one is written, and every changed project is published once.
+// It returns an error per event: nil for events that are applied or skipped, as duplicates, updates
+// of superseded generation jobs and repeated statuses are, ErrProjectNotFound for all events of unknown
+// projects, ErrInvalidStatusTransition for status updates the state machine rejects and
+// ErrInvalidEnvironment for deploy payloads with an invalid environment name. If the batch cannot be
+// written at all, every event gets the error.
+func (s *ProjectService) ApplyProjectEvents(ctx context.Context, events []dto.ConsumedProjectEvent) []error {
+	errs := make([]error, len(events))
+
+	previews, err := s.applyProjectEvents(ctx, events, false)
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+	for i, preview := range previews {
+		switch preview.Outcome {
+		case dto.ConsumedEventProjectNotFound:
+			errs[i] = models.ErrProjectNotFound
+		case dto.ConsumedEventRejected:
+			errs[i] = models.ErrInvalidStatusTransition
+		case dto.ConsumedEventInvalid:
+			errs[i] = models.ErrInvalidEnvironment
+		}
+	}
+
+	return errs
+}
+
+// ReplayProjectEvents applies replayed events like ApplyProjectEvents does and returns the outcome of each.
+// With reprocess, events that were processed before are applied again instead of being skipped as
+// duplicates; their records are replaced in the same transaction. An event id that occurs twice among
+// the events is still applied once.
+func (s *ProjectService) ReplayProjectEvents(
+	ctx context.Context,
+	events []dto.ConsumedProjectEvent,
+	reprocess bool,
+) ([]dto.ConsumedEventPreview, error) {
+	return s.applyProjectEvents(ctx, events, reprocess)
+}
+
+func (s *ProjectService) applyProjectEvents(
+	ctx context.Context,
+	events []dto.ConsumedProjectEvent,
+	reprocess bool,
+) ([]dto.ConsumedEventPreview, error) {
+	var previews []dto.ConsumedEventPreview
+	var changed []*models.Project
+	err := s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		processed, err := s.eventRepository.FindProcessed(ctx, consumedEventIds(events))
+		if err != nil {
+			return err
+		}
+		projects, err := s.projectRepository.GetProjects(ctx, consumedComposeIds(events))
+		if err != nil {
+			return err
+		}
+
+		var appliedIds []string
+		processed, processedBefore := splitProcessed(processed, reprocess)
+		previews, changed, appliedIds = s.foldEvents(events, processed, processedBefore, projects)
+
+		var reappliedIds []string
+		for i, preview := range previews {
+			if preview.Outcome == dto.ConsumedEventReapplied {
+				reappliedIds = append(reappliedIds, consumedEventId(events[i]))
+			}
+		}
+		if len(reappliedIds) > 0 {
+			if err := s.eventRepository.ForgetProcessed(ctx, reappliedIds); err != nil {
+				return err
+			}
+		}
+		if len(appliedIds) > 0 {
+			if err := s.eventRepository.MarkAllProcessed(ctx, appliedIds); err != nil {
+				return err
+			}
+		}
+		if len(changed) == 0 {
+			return nil
+		}
+
+		return s.projectRepository.SaveConsumedState(ctx, changed)
+	})
+	if err != nil {
+		s.log.Error("ошибка при применении пакета событий", "events", len(events), "error", err)
+		return nil, err
+	}
+
+	for _, projectEntity := range changed {
+		s.projectUpdater.Publish(projectEntity)
+	}
+
+	return previews, nil
+}
+
+// PreviewProjectEvents starts a dry run of a replay. The returned function reports what ReplayProjectEvents
+// would do with the events passed to it without changing anything. A dry run passes its events in chunks:
+// they are folded the same way, so later events see the changes of earlier ones, also of earlier chunks.
+// Only the folded projects and the seen event ids are kept between the chunks.
+func (s *ProjectService) PreviewProjectEvents() func(
+	ctx context.Context,
+	events []dto.ConsumedProjectEvent,
+	reprocess bool,
+) ([]dto.ConsumedEventPreview, error) {
+	projects := make(map[string]*models.Project)
+	processed := make(map[string]bool)
+	processedBefore := make(map[string]bool)
+
+	return func(ctx context.Context, events []dto.ConsumedProjectEvent, reprocess bool) ([]dto.ConsumedEventPreview, error) {
+		stored, err := s.eventRepository.FindProcessed(ctx, consumedEventIds(events))
+		if err != nil {
+			s.log.Error("ошибка при проверке обработанных событий", "error", err)
+			return nil, err
+		}
+		for eventId := range stored {
+			if reprocess {
+				processedBefore[eventId] = true
+			} else {
+				processed[eventId] = true
+			}
+		}
+
+		var missing []string
+		for _, composeId := range consumedComposeIds(events) {
+			if _, ok := projects[composeId]; !ok {
+				missing = append(missing, composeId)
+			}
+		}
+		if len(missing) > 0 {
+			loaded, err := s.projectRepository.GetProjects(ctx, missing)
+			if err != nil {
+				s.log.Error("ошибка при получении проектов", "error", err)
+				return nil, err
+			}
+			for composeId, projectEntity := range loaded {
+				projects[composeId] = projectEntity
+			}
+		}
+
+		previews, _, _ := s.foldEvents(events, processed, processedBefore, projects)
+		return previews, nil
+	}
+}
+
+// splitProcessed returns the processed maps foldEvents takes for the stored processed event ids:
+// reprocessing folds them again, otherwise they are duplicates.
+func splitProcessed(stored map[string]bool, reprocess bool) (processed, processedBefore map[string]bool) {
+	if reprocess {
+		return make(map[string]bool), stored
+	}
+	return stored, nil
+}
+
+// foldEvents applies the events in order to the loaded projects and records the applied event ids
+// in processed. It returns the outcome of every event, the changed projects and the applied event ids.
+// Events in processed are duplicates, those in processedBefore were processed before a reprocessing
+// replay and are folded again and reported as reapplied.
+func (s *ProjectService) foldEvents(
+	events []dto.ConsumedProjectEvent,
+	processed map[string]bool,
+	processedBefore map[string]bool,
+	projects map[string]*models.Project,
+) ([]dto.ConsumedEventPreview, []*models.Project, []string) {
+	previews := make([]dto.ConsumedEventPreview, len(events))
+
+	var changed []*models.Project
+	changedIds := make(map[string]bool)
+	var appliedIds []string
+	for i, event := range events {
+		composeId := consumedComposeId(event)
+		previews[i].ProjectId = composeId
+
+		eventId := consumedEventId(event)
+		if eventId != "" && processed[eventId] {
+			s.log.Debug("повторное событие пропущено", "eventId", eventId)
+			previews[i].Outcome = dto.ConsumedEventDuplicate
+			continue
+		}
+
+		projectEntity, ok := projects[composeId]
+		if owner := consumedOwner(event); ok && owner != "" && projectEntity.Owner != owner {
+			ok = false
+		}
+		if !ok {
+			previews[i].Outcome = dto.ConsumedEventProjectNotFound
+			continue
+		}
+		statusBefore := projectEntity.Status
+		previews[i].Outcome = s.foldEvent(projectEntity, event)
+		switch previews[i].Outcome {
+		case dto.ConsumedEventRejected:
+			previews[i].Change = fmt.Sprintf("status %s -> %s", statusBefore, event.Status.Status)
+		case dto.ConsumedEventInvalid:
+			previews[i].Change = fmt.Sprintf("environment %q", event.Deploy.Environment)
+		}
+		if previews[i].Outcome != dto.ConsumedEventApplied {
+			continue
+		}
+		if processedBefore[eventId] {
+			previews[i].Outcome = dto.ConsumedEventReapplied
+		}
+		previews[i].Change = describeFolded(projectEntity, event, statusBefore)
+
+		if !changedIds[projectEntity.ComposeId] {
+			changedIds[projectEntity.ComposeId] = true
+			changed = append(changed, projectEntity)
+		}
+		if eventId != "" {
+			processed[eventId] = true
+			appliedIds = append(appliedIds, eventId)
+		}
+	}
+
+	return previews, changed, appliedIds
+}
+
+// foldEvent applies the event to the project in memory the way the single event handlers apply it
+// to the stored project and returns its outcome: applied, skipped, rejected for status updates
+// the state machine does not allow or invalid for deploy payloads with an invalid environment name.
+func (s *ProjectService) foldEvent(projectEntity *models.Project, event dto.ConsumedProjectEvent) string {
+	switch {
+	case event.Status != nil:
+		status := event.Status
+		if status.JobId != "" && status.JobId != projectEntity.GenerationJobId {
+			s.log.Info("пропущено обновление статуса устаревшей генерации", "id", status.Id, "status", status.Status, "jobId", status.JobId)
+			return dto.ConsumedEventSkipped
+		}
+		if status.Status == projectEntity.Status {
+			s.log.Debug("пропущено повторное обновление статуса", "id", status.Id, "status", status.Status)
+			return dto.ConsumedEventSkipped
+		}
+		if !models.CanTransition(projectEntity.Status, status.Status) {
+			s.log.Warn("отклонено обновление статуса проекта", "id", status.Id, "from", projectEntity.Status, "status", status.Status)
+			return dto.ConsumedEventRejected
+		}
+		projectEntity.Status = status.Status
+		projectEntity.StatusUpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+
+	case event.Zip != nil:
+		artifact := newZipArtifact(*event.Zip)
+		if artifact.Revision == 0 {
+			artifact.Revision = projectEntity.GenerationRevision
+		}
+		projectEntity.Artifacts = append(projectEntity.Artifacts, artifact)
+		if len(projectEntity.Artifacts) > models.MaxStoredArtifacts {
+			projectEntity.Artifacts = projectEntity.Artifacts[len(projectEntity.Artifacts)-models.MaxStoredArtifacts:]
+		}
+
+	case event.Deploy != nil:
+		environment, err := normalizeEnvironment(event.Deploy.Environment)
+		if err != nil {
+			s.log.Warn("отклонён деплой с некорректным окружением", "id", projectEntity.ComposeId, "environment", event.Deploy.Environment)
+			return dto.ConsumedEventInvalid
+		}
+		if projectEntity.Environments == nil {
+			projectEntity.Environments = make(map[string]models.DeployEnvironment)
+		}
+		projectEntity.Environments[environment] = applyDeployPayload(projectEntity.Environments[environment], *event.Deploy)
+
+	default:
+		return dto.ConsumedEventSkipped
+	}
+
+	return dto.ConsumedEventApplied
+}
+
+// describeFolded describes the change event made to projectEntity, statusBefore is its status before.
+func describeFolded(projectEntity *models.Project, event dto.ConsumedProjectEvent, statusBefore string) string {
+	switch {
+	case event.Status != nil:
+		return fmt.Sprintf("status %s -> %s", statusBefore, projectEntity.Status)
+	case event.Zip != nil:
+		artifact := projectEntity.Artifacts[len(projectEntity.Artifacts)-1]
+		return fmt.Sprintf("artifact revision %d %s", artifact.Revision, artifact.Url)
+	case event.Deploy != nil:
+		name, _ := normalizeEnvironment(event.Deploy.Environment)
+		environment := projectEntity.Environments[name]
+		return fmt.Sprintf("environment %s %s %s", name, environment.Status, environment.UrlDeploy)
+	default:
+		return ""
+	}
+}
+
+func newZipArtifact(event dto.NewZipDTO) models.ZipArtifact {
+	artifact := models.ZipArtifact{
+		Url:              event.Url,
+		Checksum:         event.Checksum,
+		Size:             event.Size,
+		GeneratorVersion: event.GeneratorVersion,
+		Revision:         event.Revision,
+		CreatedAt:        primitive.NewDateTimeFromTime(time.Now()),
+	}
+	if event.CreatedAt != 0 {
+		artifact.CreatedAt = primitive.DateTime(event.CreatedAt)
+	}
+
+	return artifact
+}
+
+func applyDeployPayload(env models.DeployEnvironment, event dto.DeployPayloadDTO) models.DeployEnvironment {
+	if event.Status == models.DeployStatusUndeployed {
+		env.Status = models.DeployStatusUndeployed
+		env.UrlDeploy = ""
+	} else {
+		env.Status = models.DeployStatusDeployed
+		env.UrlDeploy = event.Url
+		env.Revision = env.RequestedRevision
+		if event.Revision != 0 {
+			env.Revision = event.Revision
+		}
+	}
+	env.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+
+	return env
+}
+
+func consumedEventId(event dto.ConsumedProjectEvent) string {
+	switch {
+	case event.Status != nil:
+		return event.Status.EventId
+	case event.Zip != nil:
+		return event.Zip.EventId
+	case event.Deploy != nil:
+		return event.Deploy.EventId
+	default:
+		return ""
+	}
+}
+
+func consumedComposeId(event dto.ConsumedProjectEvent) string {
+	switch {
+	case event.Status != nil:
+		return event.Status.Id
+	case event.Zip != nil:
+		return toComposeId(event.Zip.Owner, event.Zip.Name)
+	case event.Deploy != nil:
+		return toComposeId(event.Deploy.Owner, event.Deploy.Name)
+	default:
+		return ""
+	}
+}
+
+// consumedOwner returns the owner named by events that carry one, status events only have the compose id.
+func consumedOwner(event dto.ConsumedProjectEvent) string {
+	switch {
+	case event.Zip != nil:
+		return event.Zip.Owner
+	case event.Deploy != nil:
+		return event.Deploy.Owner
+	default:
+		return ""
+	}
+}
+
+func consumedEventIds(events []dto.ConsumedProjectEvent) []string {
+	eventIds := make([]string, 0, len(events))
+	for _, event := range events {
+		if eventId := consumedEventId(event); eventId != "" {
+			eventIds = append(eventIds, eventId)
+		}
+	}
+
+	return eventIds
+}
+
+func consumedComposeIds(events []dto.ConsumedProjectEvent) []string {
+	composeIds := make([]string, 0, len(events))
+	for _, event := range events {
+		composeIds = append(composeIds, consumedComposeId(event))
+	}
+
+	return composeIds
+}
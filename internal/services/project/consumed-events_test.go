@@ -0,0 +1,189 @@
+package projectservice
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"project-service/internal/domain/models"
+	"project-service/internal/dto"
+	"testing"
+)
+
+func TestFoldEventsReprocessesEventsProcessedBefore(t *testing.T) {
+	s := &ProjectService{log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	zip := func(eventId string, revision int64) dto.ConsumedProjectEvent {
+		return dto.ConsumedProjectEvent{Zip: &dto.NewZipDTO{Owner: "alice", Name: "api", Revision: revision, EventId: eventId}}
+	}
+	// e1 was processed before the replay and occurs twice in it, e2 is new
+	events := []dto.ConsumedProjectEvent{zip("e1", 1), zip("e1", 1), zip("e2", 2)}
+
+	tests := []struct {
+		name      string
+		reprocess bool
+		outcomes  []string
+		applied   []string
+		artifacts int
+	}{
+		{
+			name:      "duplicates are skipped",
+			outcomes:  []string{dto.ConsumedEventDuplicate, dto.ConsumedEventDuplicate, dto.ConsumedEventApplied},
+			applied:   []string{"e2"},
+			artifacts: 1,
+		},
+		{
+			name:      "reprocess applies them once more",
+			reprocess: true,
+			outcomes:  []string{dto.ConsumedEventReapplied, dto.ConsumedEventDuplicate, dto.ConsumedEventApplied},
+			applied:   []string{"e1", "e2"},
+			artifacts: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projects := map[string]*models.Project{"alice_api": {ComposeId: "alice_api", Owner: "alice", Name: "api"}}
+
+			processed, processedBefore := splitProcessed(map[string]bool{"e1": true}, tt.reprocess)
+			previews, changed, applied := s.foldEvents(events, processed, processedBefore, projects)
+
+			for i, preview := range previews {
+				if preview.Outcome != tt.outcomes[i] {
+					t.Errorf("event %d: got outcome %s, want %s", i, preview.Outcome, tt.outcomes[i])
+				}
+			}
+			if len(applied) != len(tt.applied) {
+				t.Fatalf("got applied ids %v, want %v", applied, tt.applied)
+			}
+			for i := range applied {
+				if applied[i] != tt.applied[i] {
+					t.Fatalf("got applied ids %v, want %v", applied, tt.applied)
+				}
+			}
+			if len(changed) != 1 || len(changed[0].Artifacts) != tt.artifacts {
+				t.Fatalf("got %d changed projects, want one with %d artifacts", len(changed), tt.artifacts)
+			}
+		})
+	}
+}
+
+func TestFoldEventsRejectsTransitionsTheStateMachineDoesNotAllow(t *testing.T) {
+	s := &ProjectService{log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	status := func(eventId, status, jobId string) dto.ConsumedProjectEvent {
+		return dto.ConsumedProjectEvent{Status: &dto.ProjectStatusDTO{Id: "alice_api", Status: status, JobId: jobId, EventId: eventId}}
+	}
+	events := []dto.ConsumedProjectEvent{
+		status("e1", models.StatusGenerating, ""),
+		status("e2", models.StatusNew, ""),
+		status("e3", models.StatusFailed, "old-job"),
+		status("e4", models.StatusGenerated, ""),
+		status("e5", models.StatusGenerated, ""),
+	}
+	projects := map[string]*models.Project{"alice_api": {ComposeId: "alice_api", Owner: "alice", Name: "api", Status: "READY"}}
+
+	previews, changed, _ := s.foldEvents(events, map[string]bool{}, nil, projects)
+
+	want := []string{dto.ConsumedEventApplied, dto.ConsumedEventRejected, dto.ConsumedEventSkipped, dto.ConsumedEventApplied, dto.ConsumedEventSkipped}
+	for i, preview := range previews {
+		if preview.Outcome != want[i] {
+			t.Errorf("event %d: got outcome %s, want %s", i, preview.Outcome, want[i])
+		}
+	}
+	if previews[1].Change != "status GENERATING -> NEW" {
+		t.Errorf("got change %q of the rejected event", previews[1].Change)
+	}
+	if len(changed) != 1 || changed[0].Status != models.StatusGenerated {
+		t.Fatalf("got changed projects %v, want alice_api in GENERATED", changed)
+	}
+}
+
+// storedProjects is a ProjectRepository over the projects of a test that counts the calls loading them;
+// other methods are not implemented.
+type storedProjects struct {
+	ProjectRepository
+	projects map[string]models.Project
+	loads    int
+}
+
+func (r *storedProjects) GetProjects(_ context.Context, composeIds []string) (map[string]*models.Project, error) {
+	r.loads++
+	projects := make(map[string]*models.Project)
+	for _, composeId := range composeIds {
+		if projectEntity, ok := r.projects[composeId]; ok {
+			projects[composeId] = &projectEntity
+		}
+	}
+	return projects, nil
+}
+
+type storedEvents struct {
+	ProcessedEventRepository
+	processed map[string]bool
+}
+
+func (r storedEvents) FindProcessed(_ context.Context, eventIds []string) (map[string]bool, error) {
+	found := make(map[string]bool)
+	for _, eventId := range eventIds {
+		if r.processed[eventId] {
+			found[eventId] = true
+		}
+	}
+	return found, nil
+}
+
+func TestPreviewProjectEventsFoldsChunksOnTopOfEachOther(t *testing.T) {
+	repository := &storedProjects{projects: map[string]models.Project{
+		"alice_api": {ComposeId: "alice_api", Owner: "alice", Name: "api", Status: models.StatusQueued},
+	}}
+	s := &ProjectService{
+		log:               slog.New(slog.NewTextHandler(io.Discard, nil)),
+		projectRepository: repository,
+		eventRepository:   storedEvents{processed: map[string]bool{"e0": true}},
+	}
+	status := func(eventId, status string) dto.ConsumedProjectEvent {
+		return dto.ConsumedProjectEvent{Status: &dto.ProjectStatusDTO{Id: "alice_api", Status: status, EventId: eventId}}
+	}
+
+	preview := s.PreviewProjectEvents()
+	chunks := [][]dto.ConsumedProjectEvent{
+		{status("e0", models.StatusGenerating), status("e1", models.StatusGenerating)},
+		{status("e1", models.StatusGenerating), status("e2", models.StatusGenerated)},
+	}
+	want := [][]string{
+		{dto.ConsumedEventDuplicate, dto.ConsumedEventApplied},
+		{dto.ConsumedEventDuplicate, dto.ConsumedEventApplied},
+	}
+	for i, chunk := range chunks {
+		previews, err := preview(context.Background(), chunk, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, p := range previews {
+			if p.Outcome != want[i][j] {
+				t.Errorf("chunk %d, event %d: got outcome %s, want %s", i, j, p.Outcome, want[i][j])
+			}
+		}
+	}
+	if repository.loads != 1 {
+		t.Fatalf("got %d loads of the projects, want 1", repository.loads)
+	}
+	if repository.projects["alice_api"].Status != models.StatusQueued {
+		t.Fatal("the preview changed the stored project")
+	}
+}
+
+func TestFoldEventsMarksDeploysToInvalidEnvironments(t *testing.T) {
+	s := &ProjectService{log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	deploy := func(environment string) dto.ConsumedProjectEvent {
+		return dto.ConsumedProjectEvent{Deploy: &dto.DeployPayloadDTO{Owner: "alice", Name: "api", Url: "https://api.example.com", Environment: environment}}
+	}
+	projects := map[string]*models.Project{"alice_api": {ComposeId: "alice_api", Owner: "alice", Name: "api"}}
+
+	previews, changed, _ := s.foldEvents([]dto.ConsumedProjectEvent{deploy("Prod!"), deploy("preview")}, map[string]bool{}, nil, projects)
+
+	if previews[0].Outcome != dto.ConsumedEventInvalid || previews[1].Outcome != dto.ConsumedEventApplied {
+		t.Fatalf("got outcomes %s and %s", previews[0].Outcome, previews[1].Outcome)
+	}
+	if len(changed) != 1 || len(changed[0].Environments) != 1 {
+		t.Fatalf("got changed projects %v, want alice_api with the preview environment", changed)
+	}
+}
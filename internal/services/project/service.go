@@ -2,38 +2,94 @@ package projectservice
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"log/slog"
 	"project-service/internal/domain/models"
 	"project-service/internal/dto"
+	"project-service/internal/repository"
+	"project-service/internal/repository/outbox"
+	"project-service/internal/repository/processedevent"
 	"project-service/internal/repository/project"
+	"regexp"
+	"strings"
+	"time"
 )
 
+var environmentNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
 type ProjectRepository interface {
 	GetAllUserProjects(ctx context.Context, owner string, page, limit int64) ([]*models.Project, error)
 	GetFilteredProjects(ctx context.Context, owner, status, namePrefix string, page, limit int64) ([]*models.Project, error)
 	InitProject(ctx context.Context, composeId, owner, name string) (*models.Project, error)
-	UpdateProject(ctx context.Context, composeId string, data string) (*models.Project, error)
-	UpdateProjectStatus(ctx context.Context, string, status string) (*models.Project, error)
-	UpdateProjectUrlZip(ctx context.Context, composeId string, url string) (*models.Project, error)
-	UpdateProjectUrlDeploy(ctx context.Context, composeId string, url string) (*models.Project, error)
-	DeleteProject(ctx context.Context, composeId string) error
+	UpdateProject(ctx context.Context, composeId, owner string, data string) (*models.Project, error)
+	GetProject(ctx context.Context, composeId, owner string) (*models.Project, error)
+	UpdateProjectStatus(ctx context.Context, composeId string, status string, jobId string) (*models.Project, error)
+	QueueGeneration(ctx context.Context, composeId, owner string, jobId string, revision int64) (*models.Project, error)
+	AddProjectArtifact(ctx context.Context, composeId, owner string, artifact models.ZipArtifact) (*models.Project, error)
+	UpdateEnvironment(ctx context.Context, composeId, owner string, name string, environment models.DeployEnvironment) (*models.Project, error)
+	DeleteProject(ctx context.Context, composeId, owner string) error
+	GetProjects(ctx context.Context, composeIds []string) (map[string]*models.Project, error)
+	SaveConsumedState(ctx context.Context, projects []*models.Project) error
+	MarkStuck(ctx context.Context, composeId, owner string, status string, statusUpdatedAt primitive.DateTime) (*models.Project, error)
+	FindStuckProjects(ctx context.Context, status string, before time.Time, owner string, limit int64) ([]*models.Project, error)
+}
+
+type OutboxRepository interface {
+	Insert(ctx context.Context, record *models.OutboxRecord) error
+}
+
+type ProcessedEventRepository interface {
+	MarkProcessed(ctx context.Context, eventId string) error
+	FindProcessed(ctx context.Context, eventIds []string) (map[string]bool, error)
+	MarkAllProcessed(ctx context.Context, eventIds []string) error
+	ForgetProcessed(ctx context.Context, eventIds []string) error
+}
+
+type Transactor interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type EventEncoder interface {
+	Encode(topic string, native interface{}) ([]byte, error)
+}
+
+type UrlSigner interface {
+	Sign(artifactId string) (string, time.Time, error)
 }
 
 type ProjectService struct {
 	log               *slog.Logger
 	projectRepository ProjectRepository
+	outboxRepository  OutboxRepository
+	eventRepository   ProcessedEventRepository
+	transactor        Transactor
+	eventEncoder      EventEncoder
+	urlSigner         UrlSigner
 	projectUpdater    *ProjectUpdater
 }
 
 func NewProjectService(
 	log *slog.Logger,
 	projectRepository *project.ProjectRepository,
+	outboxRepository *outbox.OutboxRepository,
+	eventRepository *processedevent.ProcessedEventRepository,
+	transactor *repository.Transactor,
+	eventEncoder EventEncoder,
+	urlSigner UrlSigner,
 	projectUpdater *ProjectUpdater,
 ) *ProjectService {
 	return &ProjectService{
 		log:               log,
 		projectRepository: projectRepository,
+		outboxRepository:  outboxRepository,
+		eventRepository:   eventRepository,
+		transactor:        transactor,
+		eventEncoder:      eventEncoder,
+		urlSigner:         urlSigner,
 		projectUpdater:    projectUpdater,
 	}
 }
@@ -72,7 +128,17 @@ func (s *ProjectService) InitProject(
 	name string,
 ) (*models.Project, error) {
 	composeId := toComposeId(owner, name)
-	projectEntity, err := s.projectRepository.InitProject(ctx, composeId, owner, name)
+
+	var projectEntity *models.Project
+	err := s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		projectEntity, err = s.projectRepository.InitProject(ctx, composeId, owner, name)
+		if err != nil {
+			return err
+		}
+
+		return s.enqueueProjectEvent(ctx, dto.ProjectCreatedTopic, projectEntity)
+	})
 	if err != nil {
 		s.log.Error("ошибка при инициализации проекта", "error", err)
 		return nil, err
@@ -88,7 +154,17 @@ func (s *ProjectService) UpdateProject(
 	data string,
 ) (*models.Project, error) {
 	composeId := toComposeId(owner, name)
-	projectEntity, err := s.projectRepository.UpdateProject(ctx, composeId, data)
+
+	var projectEntity *models.Project
+	err := s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		projectEntity, err = s.projectRepository.UpdateProject(ctx, composeId, owner, data)
+		if err != nil {
+			return err
+		}
+
+		return s.enqueueProjectEvent(ctx, dto.ProjectUpdatedTopic, projectEntity)
+	})
 	if err != nil {
 		s.log.Error("ошибка при обновлении проекта", "error", err)
 		return nil, err
@@ -103,7 +179,18 @@ func (s *ProjectService) DeleteProject(
 	name string,
 ) error {
 	composeId := toComposeId(owner, name)
-	err := s.projectRepository.DeleteProject(ctx, composeId)
+	err := s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.projectRepository.DeleteProject(ctx, composeId, owner); err != nil {
+			return err
+		}
+
+		return s.enqueueProjectEvent(ctx, dto.ProjectDeletedTopic, &models.Project{
+			ComposeId: composeId,
+			Owner:     owner,
+			Name:      name,
+			UpdatedAt: primitive.NewDateTimeFromTime(time.Now()),
+		})
+	})
 	if err != nil {
 		s.log.Error("ошибка при удалении проекта", "error", err)
 		return err
@@ -112,18 +199,144 @@ func (s *ProjectService) DeleteProject(
 	return nil
 }
 
+// GenerateProject validates the current definition, moves the project to the queued status and
+// requests generation of its current revision. The returned job id is echoed in status updates.
+func (s *ProjectService) GenerateProject(
+	ctx context.Context,
+	owner string,
+	name string,
+) (*models.Project, string, error) {
+	composeId := toComposeId(owner, name)
+	jobId, err := newId()
+	if err != nil {
+		s.log.Error("ошибка при создании задачи генерации", "error", err)
+		return nil, "", err
+	}
+
+	var projectEntity *models.Project
+	err = s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		current, err := s.projectRepository.GetProject(ctx, composeId, owner)
+		if err != nil {
+			return err
+		}
+		if err := validateDefinition(current.Data); err != nil {
+			return err
+		}
+
+		projectEntity, err = s.projectRepository.QueueGeneration(ctx, composeId, owner, jobId, current.Revision)
+		if err != nil {
+			return err
+		}
+
+		request := dto.GenerationRequestDTO{
+			JobId:       jobId,
+			Owner:       projectEntity.Owner,
+			Name:        projectEntity.Name,
+			Data:        projectEntity.Data,
+			Revision:    projectEntity.Revision,
+			RequestedAt: time.Now().UnixMilli(),
+		}
+		return s.enqueueEvent(ctx, dto.GenerationRequestedTopic, composeId, request.ToNative())
+	})
+	if err != nil {
+		s.log.Error("ошибка при запуске генерации проекта", "error", err)
+		return nil, "", err
+	}
+
+	s.projectUpdater.Publish(projectEntity)
+
+	return projectEntity, jobId, nil
+}
+
+// FindStuckProjects returns up to limit projects of owner, or of all owners when it is empty,
+// that have been in status since before.
+func (s *ProjectService) FindStuckProjects(
+	ctx context.Context,
+	status string,
+	before time.Time,
+	owner string,
+	limit int64,
+) ([]*models.Project, error) {
+	projects, err := s.projectRepository.FindStuckProjects(ctx, status, before, owner, limit)
+	if err != nil {
+		s.log.Error("ошибка при поиске зависших проектов", "status", status, "error", err)
+		return nil, err
+	}
+
+	return projects, nil
+}
+
+// MarkProjectStuck moves a project found by FindStuckProjects to status through the state machine.
+// It fails with ErrInvalidStatusTransition when the project has moved on in the meantime. With rerequest
+// the generation request of its job is sent again, unless the definition has changed since.
+func (s *ProjectService) MarkProjectStuck(
+	ctx context.Context,
+	stuck *models.Project,
+	status string,
+	rerequest bool,
+) (*models.Project, error) {
+	var projectEntity *models.Project
+	err := s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		projectEntity, err = s.projectRepository.MarkStuck(ctx, stuck.ComposeId, stuck.Owner, status, stuck.StatusUpdatedAt)
+		if err != nil {
+			return err
+		}
+		if !rerequest || projectEntity.GenerationJobId == "" {
+			return nil
+		}
+		if projectEntity.Revision != projectEntity.GenerationRevision {
+			s.log.Warn("определение проекта изменилось, повторный запрос генерации не отправлен",
+				"id", projectEntity.ComposeId, "jobId", projectEntity.GenerationJobId)
+			return nil
+		}
+
+		request := dto.GenerationRequestDTO{
+			JobId:       projectEntity.GenerationJobId,
+			Owner:       projectEntity.Owner,
+			Name:        projectEntity.Name,
+			Data:        projectEntity.Data,
+			Revision:    projectEntity.GenerationRevision,
+			RequestedAt: time.Now().UnixMilli(),
+		}
+		return s.enqueueEvent(ctx, dto.GenerationRequestedTopic, projectEntity.ComposeId, request.ToNative())
+	})
+	if err != nil {
+		if !errors.Is(err, models.ErrInvalidStatusTransition) {
+			s.log.Error("ошибка при пометке зависшего проекта", "id", stuck.ComposeId, "status", status, "error", err)
+		}
+		return nil, err
+	}
+
+	s.projectUpdater.Publish(projectEntity)
+
+	return projectEntity, nil
+}
+
 func (s *ProjectService) UpdateProjectStatus(
 	ctx context.Context,
 	dto dto.ProjectStatusDTO,
 ) (bool, error) {
-	updProject, err := s.projectRepository.UpdateProjectStatus(ctx, dto.Id, dto.Status)
+	err := s.applyEvent(ctx, dto.EventId, func(ctx context.Context) (*models.Project, error) {
+		return s.projectRepository.UpdateProjectStatus(ctx, dto.Id, dto.Status, dto.JobId)
+	})
+	if errors.Is(err, models.ErrStaleStatusUpdate) {
+		s.log.Info("пропущено обновление статуса устаревшей генерации", "id", dto.Id, "status", dto.Status, "jobId", dto.JobId)
+		return true, nil
+	}
+	if errors.Is(err, models.ErrStatusUnchanged) {
+		s.log.Debug("пропущено повторное обновление статуса", "id", dto.Id, "status", dto.Status, "jobId", dto.JobId)
+		return true, nil
+	}
+	if errors.Is(err, models.ErrInvalidStatusTransition) {
+		s.log.Warn("отклонено обновление статуса проекта", "id", dto.Id, "jobId", dto.JobId, "error", err)
+		return true, err
+	}
 	if err != nil {
 		s.log.Error("ошибка при обновлении статуса проекта", "error", err)
 		return false, err
 	}
 
-	s.projectUpdater.Publish(updProject)
-
 	return true, nil
 }
 
@@ -132,31 +345,320 @@ func (s *ProjectService) UpdateProjectUrlZip(
 	dto dto.NewZipDTO,
 ) (bool, error) {
 	composeId := toComposeId(dto.Owner, dto.Name)
-	updProject, err := s.projectRepository.UpdateProjectUrlZip(ctx, composeId, dto.Url)
+	artifact := newZipArtifact(dto)
+
+	err := s.applyEvent(ctx, dto.EventId, func(ctx context.Context) (*models.Project, error) {
+		if artifact.Revision == 0 {
+			current, err := s.projectRepository.GetProject(ctx, composeId, dto.Owner)
+			if err != nil {
+				return nil, err
+			}
+			artifact.Revision = current.GenerationRevision
+		}
+
+		return s.projectRepository.AddProjectArtifact(ctx, composeId, dto.Owner, artifact)
+	})
 	if err != nil {
 		s.log.Error("ошибка при обновлении url zip проекта", "error", err)
 		return false, err
 	}
 
-	s.projectUpdater.Publish(updProject)
-
 	return true, nil
 }
 
+// ListArtifacts returns the stored zip artifacts of the project, newest first.
+func (s *ProjectService) ListArtifacts(
+	ctx context.Context,
+	owner string,
+	name string,
+) ([]models.ZipArtifact, error) {
+	projectEntity, err := s.projectRepository.GetProject(ctx, toComposeId(owner, name), owner)
+	if err != nil {
+		s.log.Error("ошибка при получении артефактов проекта", "error", err)
+		return nil, err
+	}
+
+	artifacts := make([]models.ZipArtifact, 0, len(projectEntity.Artifacts))
+	for i := len(projectEntity.Artifacts) - 1; i >= 0; i-- {
+		artifacts = append(artifacts, projectEntity.Artifacts[i])
+	}
+
+	return artifacts, nil
+}
+
+func (s *ProjectService) GetLatestArtifact(
+	ctx context.Context,
+	owner string,
+	name string,
+) (*models.ZipArtifact, error) {
+	projectEntity, err := s.projectRepository.GetProject(ctx, toComposeId(owner, name), owner)
+	if err != nil {
+		s.log.Error("ошибка при получении артефакта проекта", "error", err)
+		return nil, err
+	}
+
+	artifact := projectEntity.LatestArtifact()
+	if artifact == nil {
+		return nil, models.ErrArtifactNotFound
+	}
+
+	return artifact, nil
+}
+
+// GetDownloadUrl issues an expiring signed URL for the artifact built from revision,
+// or for the latest artifact when revision is 0.
+func (s *ProjectService) GetDownloadUrl(
+	ctx context.Context,
+	owner string,
+	name string,
+	revision int64,
+) (string, time.Time, error) {
+	projectEntity, err := s.projectRepository.GetProject(ctx, toComposeId(owner, name), owner)
+	if err != nil {
+		s.log.Error("ошибка при получении ссылки на скачивание", "error", err)
+		return "", time.Time{}, err
+	}
+
+	artifact := projectEntity.LatestArtifact()
+	if revision != 0 {
+		artifact = projectEntity.Artifact(revision)
+	}
+	if artifact == nil {
+		return "", time.Time{}, models.ErrArtifactNotFound
+	}
+
+	return s.urlSigner.Sign(models.ArtifactId{Owner: owner, Name: name, Revision: artifact.Revision}.String())
+}
+
+// ArtifactLocation resolves the artifact id of a verified download link to the location of the artifact.
+func (s *ProjectService) ArtifactLocation(ctx context.Context, artifactId string) (string, error) {
+	id, err := models.ParseArtifactId(artifactId)
+	if err != nil {
+		return "", err
+	}
+
+	projectEntity, err := s.projectRepository.GetProject(ctx, toComposeId(id.Owner, id.Name), id.Owner)
+	if err != nil {
+		return "", err
+	}
+
+	artifact := projectEntity.Artifact(id.Revision)
+	if artifact == nil {
+		return "", models.ErrArtifactNotFound
+	}
+
+	return artifact.Url, nil
+}
+
+// DeployProject requests a deploy of the last generated revision to the environment.
+func (s *ProjectService) DeployProject(
+	ctx context.Context,
+	owner string,
+	name string,
+	environment string,
+) (*models.Project, string, error) {
+	return s.requestDeployCommand(ctx, owner, name, environment, dto.DeployRequestedTopic,
+		func(project *models.Project, env models.DeployEnvironment, exists bool) (models.DeployEnvironment, error) {
+			if project.Status != models.StatusGenerated {
+				return env, models.ErrProjectNotGenerated
+			}
+
+			env.Status = models.DeployStatusQueued
+			env.RequestedRevision = project.GenerationRevision
+			return env, nil
+		},
+	)
+}
+
+// UndeployProject requests removal of the environment deploy.
+func (s *ProjectService) UndeployProject(
+	ctx context.Context,
+	owner string,
+	name string,
+	environment string,
+) (*models.Project, string, error) {
+	return s.requestDeployCommand(ctx, owner, name, environment, dto.UndeployRequestedTopic,
+		func(project *models.Project, env models.DeployEnvironment, exists bool) (models.DeployEnvironment, error) {
+			if !exists {
+				return env, models.ErrEnvironmentNotFound
+			}
+			if env.Status == models.DeployStatusUndeployed {
+				return env, models.ErrInvalidStatusTransition
+			}
+
+			env.Status = models.DeployStatusUndeployQueued
+			env.RequestedRevision = env.Revision
+			return env, nil
+		},
+	)
+}
+
+func (s *ProjectService) requestDeployCommand(
+	ctx context.Context,
+	owner string,
+	name string,
+	environment string,
+	topic string,
+	apply func(project *models.Project, env models.DeployEnvironment, exists bool) (models.DeployEnvironment, error),
+) (*models.Project, string, error) {
+	composeId := toComposeId(owner, name)
+	environment, err := normalizeEnvironment(environment)
+	if err != nil {
+		return nil, "", err
+	}
+	commandId, err := newId()
+	if err != nil {
+		s.log.Error("ошибка при создании команды деплоя", "error", err)
+		return nil, "", err
+	}
+
+	var projectEntity *models.Project
+	err = s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		current, err := s.projectRepository.GetProject(ctx, composeId, owner)
+		if err != nil {
+			return err
+		}
+
+		env, exists := current.Environments[environment]
+		env, err = apply(current, env, exists)
+		if err != nil {
+			return err
+		}
+		env.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+
+		projectEntity, err = s.projectRepository.UpdateEnvironment(ctx, composeId, owner, environment, env)
+		if err != nil {
+			return err
+		}
+
+		command := dto.DeployCommandDTO{
+			CommandId:   commandId,
+			Owner:       projectEntity.Owner,
+			Name:        projectEntity.Name,
+			Environment: environment,
+			Revision:    env.RequestedRevision,
+			RequestedAt: time.Now().UnixMilli(),
+		}
+		return s.enqueueEvent(ctx, topic, composeId, command.ToNative())
+	})
+	if err != nil {
+		s.log.Error("ошибка при отправке команды деплоя", "topic", topic, "error", err)
+		return nil, "", err
+	}
+
+	s.projectUpdater.Publish(projectEntity)
+
+	return projectEntity, commandId, nil
+}
+
 func (s *ProjectService) UpdateProjectUrlDeploy(
 	ctx context.Context,
 	dto dto.DeployPayloadDTO,
 ) (bool, error) {
 	composeId := toComposeId(dto.Owner, dto.Name)
-	updProject, err := s.projectRepository.UpdateProjectUrlDeploy(ctx, composeId, dto.Url)
+	environment, err := normalizeEnvironment(dto.Environment)
 	if err != nil {
-		s.log.Error("ошибка при обновлении url zip проекта", "error", err)
+		s.log.Warn("отклонён деплой с некорректным окружением", "id", composeId, "environment", dto.Environment)
+		return true, fmt.Errorf("%w: %q", err, dto.Environment)
+	}
+
+	err = s.applyEvent(ctx, dto.EventId, func(ctx context.Context) (*models.Project, error) {
+		current, err := s.projectRepository.GetProject(ctx, composeId, dto.Owner)
+		if err != nil {
+			return nil, err
+		}
+
+		env := applyDeployPayload(current.Environments[environment], dto)
+		return s.projectRepository.UpdateEnvironment(ctx, composeId, dto.Owner, environment, env)
+	})
+	if err != nil {
+		s.log.Error("ошибка при обновлении деплоя проекта", "error", err)
 		return false, err
 	}
 
+	return true, nil
+}
+
+// applyEvent runs mutate in one transaction with recording eventId and publishes the result.
+// A redelivered event is acknowledged as a no-op: nothing is changed or published again.
+func (s *ProjectService) applyEvent(
+	ctx context.Context,
+	eventId string,
+	mutate func(ctx context.Context) (*models.Project, error),
+) error {
+	var updProject *models.Project
+	err := s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		if eventId != "" {
+			if err := s.eventRepository.MarkProcessed(ctx, eventId); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		updProject, err = mutate(ctx)
+		return err
+	})
+	if errors.Is(err, models.ErrEventAlreadyProcessed) {
+		s.log.Debug("повторное событие пропущено", "eventId", eventId)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
 	s.projectUpdater.Publish(updProject)
 
-	return true, nil
+	return nil
+}
+
+// enqueueProjectEvent stores the event in the outbox; it must be called inside the mutation transaction.
+func (s *ProjectService) enqueueProjectEvent(ctx context.Context, topic string, project *models.Project) error {
+	event := dto.MapProjectToProjectEventDTO(project)
+	return s.enqueueEvent(ctx, topic, project.ComposeId, event.ToNative())
+}
+
+func (s *ProjectService) enqueueEvent(ctx context.Context, topic, key string, native interface{}) error {
+	value, err := s.eventEncoder.Encode(topic, native)
+	if err != nil {
+		return fmt.Errorf("ошибка при кодировании события %s: %w", topic, err)
+	}
+
+	now := primitive.NewDateTimeFromTime(time.Now())
+	return s.outboxRepository.Insert(ctx, &models.OutboxRecord{
+		Topic:         topic,
+		Key:           key,
+		Value:         value,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+}
+
+func validateDefinition(data string) error {
+	if strings.TrimSpace(data) == "" || !json.Valid([]byte(data)) {
+		return models.ErrInvalidProjectDefinition
+	}
+	return nil
+}
+
+func normalizeEnvironment(environment string) (string, error) {
+	if environment == "" {
+		return models.DefaultEnvironment, nil
+	}
+	if !environmentNamePattern.MatchString(environment) {
+		return "", models.ErrInvalidEnvironment
+	}
+	return environment, nil
+}
+
+func newId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
 }
 
 func toComposeId(owner, name string) string {
@@ -0,0 +1,81 @@
+package projectservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"project-service/internal/domain/models"
+	"project-service/internal/dto"
+	"testing"
+)
+
+// statusRepository answers status updates with err; other methods are not implemented.
+type statusRepository struct {
+	ProjectRepository
+	err error
+}
+
+func (r statusRepository) UpdateProjectStatus(_ context.Context, composeId, status, _ string) (*models.Project, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &models.Project{ComposeId: composeId, Status: status}, nil
+}
+
+type processedEvents struct {
+	ProcessedEventRepository
+}
+
+func (processedEvents) MarkProcessed(context.Context, string) error {
+	return nil
+}
+
+type inlineTransactor struct{}
+
+func (inlineTransactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func TestUpdateProjectStatusAcknowledgesRepeatedStatuses(t *testing.T) {
+	unavailable := errors.New("connection refused")
+	tests := []struct {
+		name      string
+		err       error
+		canCommit bool
+		wantErr   error
+	}{
+		{name: "applied", canCommit: true},
+		{name: "same status", err: models.ErrStatusUnchanged, canCommit: true},
+		{name: "superseded job", err: models.ErrStaleStatusUpdate, canCommit: true},
+		{name: "regression", err: fmt.Errorf("%w: GENERATED -> GENERATING", models.ErrInvalidStatusTransition), canCommit: true, wantErr: models.ErrInvalidStatusTransition},
+		{name: "mongo unavailable", err: unavailable, wantErr: unavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &ProjectService{
+				log:               slog.New(slog.NewTextHandler(io.Discard, nil)),
+				projectRepository: statusRepository{err: tt.err},
+				eventRepository:   processedEvents{},
+				transactor:        inlineTransactor{},
+				projectUpdater:    NewProjectUpdater(),
+			}
+
+			canCommit, err := s.UpdateProjectStatus(context.Background(), dto.ProjectStatusDTO{Id: "alice_api", Status: models.StatusGenerating, EventId: "e1"})
+			if canCommit != tt.canCommit || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %t and error %v, want %t and %v", canCommit, err, tt.canCommit, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdateProjectUrlDeployRejectsInvalidEnvironments(t *testing.T) {
+	s := &ProjectService{log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	canCommit, err := s.UpdateProjectUrlDeploy(context.Background(), dto.DeployPayloadDTO{Owner: "alice", Name: "api", Environment: "Prod!"})
+	if !canCommit || !errors.Is(err, models.ErrInvalidEnvironment) {
+		t.Fatalf("got %t and error %v, want true and %v", canCommit, err, models.ErrInvalidEnvironment)
+	}
+}
@@ -1,41 +1,84 @@
 package projectservice
 
 import (
+	"expvar"
 	"project-service/internal/domain/models"
 	"sync"
 )
 
+// subscriberBuffer is how many updates a subscriber may fall behind before it misses some.
+const subscriberBuffer = 100
+
+var (
+	updateSubscribers = expvar.NewInt("project_update_subscribers")
+	updatesDropped    = expvar.NewInt("project_updates_dropped_total")
+)
+
+// ProjectUpdater fans changed projects out to the open update streams. Every subscriber gets each
+// update on a buffered channel of its own. Publish never blocks: a subscriber whose buffer is full
+// misses the update instead of holding up the publisher.
 type ProjectUpdater struct {
-	updates chan *models.Project
-	mu      sync.Mutex
-	closed  bool
+	mu          sync.Mutex
+	subscribers map[chan *models.Project]struct{}
+	closed      bool
 }
 
 func NewProjectUpdater() *ProjectUpdater {
 	return &ProjectUpdater{
-		updates: make(chan *models.Project, 100),
-		closed:  false,
+		subscribers: make(map[chan *models.Project]struct{}),
 	}
 }
 
 func (pu *ProjectUpdater) Publish(project *models.Project) {
 	pu.mu.Lock()
 	defer pu.mu.Unlock()
-	if pu.closed {
-		return
+
+	for updates := range pu.subscribers {
+		select {
+		case updates <- project:
+		default:
+			updatesDropped.Add(1)
+		}
 	}
-	pu.updates <- project
 }
 
-func (pu *ProjectUpdater) Subscribe() <-chan *models.Project {
-	return pu.updates
+// Subscribe returns a channel that receives every update published from now on and a function that
+// ends the subscription. The channel is closed when the updater is closed.
+func (pu *ProjectUpdater) Subscribe() (<-chan *models.Project, func()) {
+	pu.mu.Lock()
+	defer pu.mu.Unlock()
+
+	updates := make(chan *models.Project, subscriberBuffer)
+	if pu.closed {
+		close(updates)
+		return updates, func() {}
+	}
+	pu.subscribers[updates] = struct{}{}
+	updateSubscribers.Add(1)
+
+	return updates, func() {
+		pu.mu.Lock()
+		defer pu.mu.Unlock()
+
+		if _, ok := pu.subscribers[updates]; ok {
+			delete(pu.subscribers, updates)
+			updateSubscribers.Add(-1)
+		}
+	}
 }
 
+// Close ends all subscriptions, later updates are dropped.
 func (pu *ProjectUpdater) Close() {
 	pu.mu.Lock()
 	defer pu.mu.Unlock()
-	if !pu.closed {
-		close(pu.updates)
-		pu.closed = true
+
+	if pu.closed {
+		return
+	}
+	pu.closed = true
+	for updates := range pu.subscribers {
+		close(updates)
+		delete(pu.subscribers, updates)
+		updateSubscribers.Add(-1)
 	}
 }
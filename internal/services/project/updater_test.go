@@ -0,0 +1,66 @@
+package projectservice
+
+import (
+	"project-service/internal/domain/models"
+	"testing"
+	"time"
+)
+
+func TestPublishDoesNotBlockOnSlowSubscribers(t *testing.T) {
+	pu := NewProjectUpdater()
+	slow, _ := pu.Subscribe()
+	reader, unsubscribe := pu.Subscribe()
+	defer unsubscribe()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2*subscriberBuffer; i++ {
+			pu.Publish(&models.Project{Name: "api"})
+			<-reader
+		}
+		pu.Close()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a subscriber that does not read")
+	}
+
+	if len(slow) != subscriberBuffer {
+		t.Fatalf("got %d buffered updates, want %d", len(slow), subscriberBuffer)
+	}
+	for range slow {
+	}
+}
+
+func TestEverySubscriberGetsEveryUpdate(t *testing.T) {
+	pu := NewProjectUpdater()
+	first, _ := pu.Subscribe()
+	second, _ := pu.Subscribe()
+	unsubscribed, unsubscribe := pu.Subscribe()
+	unsubscribe()
+
+	pu.Publish(&models.Project{Name: "a"})
+	pu.Publish(&models.Project{Name: "b"})
+	pu.Close()
+
+	for _, updates := range []<-chan *models.Project{first, second} {
+		var names []string
+		for project := range updates {
+			names = append(names, project.Name)
+		}
+		if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+			t.Fatalf("got updates %v, want [a b]", names)
+		}
+	}
+	if len(unsubscribed) != 0 {
+		t.Fatal("an ended subscription got updates")
+	}
+
+	closed, _ := pu.Subscribe()
+	if _, ok := <-closed; ok {
+		t.Fatal("a subscription after Close is open")
+	}
+}
@@ -0,0 +1,229 @@
+package reconcilerservice
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"project-service/internal/config"
+	"project-service/internal/domain/models"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	reconcilerStuckProjects = expvar.NewMap("reconciler_stuck_projects")
+	reconcilerMarked        = expvar.NewMap("reconciler_marked_total")
+)
+
+// SLA is how long a project may stay in Status before it is moved to MarkAs.
+type SLA struct {
+	Status string
+	After  time.Duration
+	MarkAs string
+}
+
+// StuckProject is a project that has been in its status for StuckFor, longer than its SLA.
+type StuckProject struct {
+	Project  *models.Project
+	StuckFor time.Duration
+	SLA      time.Duration
+}
+
+type ProjectService interface {
+	FindStuckProjects(ctx context.Context, status string, before time.Time, owner string, limit int64) ([]*models.Project, error)
+	MarkProjectStuck(ctx context.Context, stuck *models.Project, status string, rerequest bool) (*models.Project, error)
+}
+
+// Reconciler finds projects whose status has not changed within the SLA of the status, e.g. because
+// the events that would have moved them on were lost, and marks them STALE or FAILED.
+type Reconciler struct {
+	log            *slog.Logger
+	projectService ProjectService
+	slas           []SLA
+	interval       time.Duration
+	batchSize      int64
+	rerequest      bool
+	now            func() time.Time
+}
+
+func NewReconciler(
+	log *slog.Logger,
+	cfg config.ReconcilerConfig,
+	projectService ProjectService,
+) (*Reconciler, error) {
+	slas, err := ParseSLAs(cfg.SLAs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reconciler{
+		log:            log,
+		projectService: projectService,
+		slas:           slas,
+		interval:       cfg.Interval,
+		batchSize:      cfg.BatchSize,
+		rerequest:      cfg.Rerequest,
+		now:            time.Now,
+	}, nil
+}
+
+// ParseSLAs parses "STATUS:duration[:MARK_AS],..." into SLAs, MARK_AS defaults to STALE.
+// The state machine has to allow moving from every status to its MARK_AS.
+func ParseSLAs(raw string) ([]SLA, error) {
+	var slas []SLA
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("sla %q must be in STATUS:duration[:MARK_AS] form", entry)
+		}
+		after, err := time.ParseDuration(parts[1])
+		if err != nil || after <= 0 {
+			return nil, fmt.Errorf("sla %q has an invalid duration", entry)
+		}
+
+		sla := SLA{Status: parts[0], After: after, MarkAs: models.StatusStale}
+		if len(parts) == 3 {
+			sla.MarkAs = parts[2]
+		}
+		if !models.CanTransition(sla.Status, sla.MarkAs) {
+			return nil, fmt.Errorf("sla %q: %s cannot be marked %s", entry, sla.Status, sla.MarkAs)
+		}
+		if seen[sla.Status] {
+			return nil, fmt.Errorf("sla for %s is given twice", sla.Status)
+		}
+		seen[sla.Status] = true
+
+		slas = append(slas, sla)
+	}
+
+	return slas, nil
+}
+
+func (r *Reconciler) Run(ctx context.Context) {
+	if len(r.slas) == 0 || r.interval <= 0 {
+		r.log.Info("reconciler disabled")
+		return
+	}
+	r.log.Info("reconciler started", "slas", r.slas)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Reconcile(ctx); err != nil {
+			r.log.Error("ошибка при поиске зависших проектов", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			r.log.Info("reconciler stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile marks up to the batch size of projects per watched status and returns how many it marked.
+// Projects that move on while they are marked are left alone, projects that fail to be marked are
+// logged and left for the next run.
+func (r *Reconciler) Reconcile(ctx context.Context) (int, error) {
+	marked := 0
+	for _, sla := range r.slas {
+		stuck, err := r.projectService.FindStuckProjects(ctx, sla.Status, r.now().Add(-sla.After), "", r.batchSize)
+		if err != nil {
+			return marked, err
+		}
+		intVar(reconcilerStuckProjects, sla.Status).Set(int64(len(stuck)))
+
+		for _, project := range stuck {
+			rerequest := r.rerequest && sla.MarkAs == models.StatusStale
+			_, err := r.projectService.MarkProjectStuck(ctx, project, sla.MarkAs, rerequest)
+			if errors.Is(err, models.ErrInvalidStatusTransition) {
+				continue
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return marked, ctx.Err()
+				}
+				r.log.Error("ошибка при пометке зависшего проекта", "id", project.ComposeId, "markAs", sla.MarkAs, "error", err)
+				continue
+			}
+
+			reconcilerMarked.Add(sla.MarkAs, 1)
+			marked++
+			r.log.Warn("проект завис и помечен",
+				"id", project.ComposeId,
+				"status", sla.Status,
+				"markedAs", sla.MarkAs,
+				"since", project.StatusUpdatedAt.Time(),
+				"rerequested", rerequest,
+			)
+		}
+	}
+
+	return marked, nil
+}
+
+// StuckProjects returns up to limit projects per watched status that are past their SLA, together with
+// the STALE projects when STALE has no SLA of its own, sorted by owner and longest stuck first.
+// An empty owner reports all owners.
+func (r *Reconciler) StuckProjects(ctx context.Context, owner string, limit int64) ([]StuckProject, error) {
+	now := r.now()
+
+	slas := r.slas
+	if !r.watches(models.StatusStale) {
+		slas = append(slas[:len(slas):len(slas)], SLA{Status: models.StatusStale})
+	}
+
+	var stuck []StuckProject
+	for _, sla := range slas {
+		projects, err := r.projectService.FindStuckProjects(ctx, sla.Status, now.Add(-sla.After), owner, limit)
+		if err != nil {
+			return nil, err
+		}
+		for _, project := range projects {
+			stuck = append(stuck, StuckProject{
+				Project:  project,
+				StuckFor: now.Sub(project.StatusUpdatedAt.Time()),
+				SLA:      sla.After,
+			})
+		}
+	}
+
+	sort.Slice(stuck, func(i, j int) bool {
+		if stuck[i].Project.Owner != stuck[j].Project.Owner {
+			return stuck[i].Project.Owner < stuck[j].Project.Owner
+		}
+		return stuck[i].StuckFor > stuck[j].StuckFor
+	})
+
+	return stuck, nil
+}
+
+func (r *Reconciler) watches(status string) bool {
+	for _, sla := range r.slas {
+		if sla.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// intVar returns the expvar.Int of key in m, which it adds on first use.
+func intVar(m *expvar.Map, key string) *expvar.Int {
+	if v, ok := m.Get(key).(*expvar.Int); ok {
+		return v
+	}
+	v := new(expvar.Int)
+	m.Set(key, v)
+	return v
+}
@@ -0,0 +1,179 @@
+package reconcilerservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"log/slog"
+	"project-service/internal/domain/models"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+// stuckProjects is a ProjectService over the projects of a test. Marking a project in failing fails,
+// marking one in movedOn fails like a project that moved on meanwhile.
+type stuckProjects struct {
+	projects []*models.Project
+	failing  map[string]bool
+	movedOn  map[string]bool
+	marked   map[string]string
+}
+
+func (s *stuckProjects) FindStuckProjects(_ context.Context, status string, before time.Time, owner string, limit int64) ([]*models.Project, error) {
+	var found []*models.Project
+	for _, project := range s.projects {
+		if project.Status == status && project.StatusUpdatedAt.Time().Before(before) &&
+			(owner == "" || project.Owner == owner) && int64(len(found)) < limit {
+			found = append(found, project)
+		}
+	}
+	return found, nil
+}
+
+func (s *stuckProjects) MarkProjectStuck(_ context.Context, stuck *models.Project, status string, _ bool) (*models.Project, error) {
+	switch {
+	case s.failing[stuck.ComposeId]:
+		return nil, errors.New("mongo is down")
+	case s.movedOn[stuck.ComposeId]:
+		return nil, fmt.Errorf("%w: %s -> %s", models.ErrInvalidStatusTransition, models.StatusGenerated, status)
+	}
+	s.marked[stuck.ComposeId] = status
+	return stuck, nil
+}
+
+func project(owner, name, status string, since time.Duration) *models.Project {
+	return &models.Project{
+		ComposeId:       owner + "_" + name,
+		Owner:           owner,
+		Name:            name,
+		Status:          status,
+		StatusUpdatedAt: primitive.NewDateTimeFromTime(now.Add(-since)),
+	}
+}
+
+func newTestReconciler(t *testing.T, slas string, service ProjectService) *Reconciler {
+	t.Helper()
+
+	parsed, err := ParseSLAs(slas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Reconciler{
+		log:            slog.New(slog.NewTextHandler(io.Discard, nil)),
+		projectService: service,
+		slas:           parsed,
+		batchSize:      10,
+		now:            func() time.Time { return now },
+	}
+}
+
+func TestParseSLAs(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []SLA
+		ok   bool
+	}{
+		{raw: "", ok: true},
+		{raw: "QUEUED:30m", want: []SLA{{Status: models.StatusQueued, After: 30 * time.Minute, MarkAs: models.StatusStale}}, ok: true},
+		{
+			raw: " QUEUED:30m , STALE:24h:FAILED,",
+			want: []SLA{
+				{Status: models.StatusQueued, After: 30 * time.Minute, MarkAs: models.StatusStale},
+				{Status: models.StatusStale, After: 24 * time.Hour, MarkAs: models.StatusFailed},
+			},
+			ok: true,
+		},
+		{raw: "QUEUED"},
+		{raw: "QUEUED:30m:FAILED:STALE"},
+		{raw: "QUEUED:soon"},
+		{raw: "QUEUED:-1h"},
+		{raw: "GENERATED:1h"},
+		{raw: "QUEUED:30m,QUEUED:1h"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			slas, err := ParseSLAs(tt.raw)
+			if (err == nil) != tt.ok {
+				t.Fatalf("got error %v", err)
+			}
+			if fmt.Sprint(slas) != fmt.Sprint(tt.want) {
+				t.Fatalf("got %v, want %v", slas, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileMarksTheOtherProjectsWhenOneFails(t *testing.T) {
+	service := &stuckProjects{
+		projects: []*models.Project{
+			project("alice", "failing", models.StatusQueued, time.Hour),
+			project("alice", "moved", models.StatusQueued, time.Hour),
+			project("alice", "api", models.StatusQueued, time.Hour),
+			project("alice", "recent", models.StatusQueued, time.Minute),
+			project("bob", "api", models.StatusStale, 48*time.Hour),
+		},
+		failing: map[string]bool{"alice_failing": true},
+		movedOn: map[string]bool{"alice_moved": true},
+		marked:  make(map[string]string),
+	}
+
+	marked, err := newTestReconciler(t, "QUEUED:30m,STALE:24h:FAILED", service).Reconcile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"alice_api": models.StatusStale, "bob_api": models.StatusFailed}
+	if marked != len(want) || fmt.Sprint(service.marked) != fmt.Sprint(want) {
+		t.Fatalf("got %d marked projects %v, want %v", marked, service.marked, want)
+	}
+	if got := intVar(reconcilerStuckProjects, models.StatusQueued).Value(); got != 3 {
+		t.Fatalf("got %d stuck QUEUED projects, want 3", got)
+	}
+}
+
+func TestStuckProjects(t *testing.T) {
+	service := &stuckProjects{projects: []*models.Project{
+		project("bob", "api", models.StatusQueued, time.Hour),
+		project("alice", "api", models.StatusQueued, time.Hour),
+		project("alice", "web", models.StatusGenerating, 3*time.Hour),
+		project("alice", "cli", models.StatusGenerating, time.Hour),
+		project("alice", "old", models.StatusStale, time.Minute),
+	}}
+	reconciler := newTestReconciler(t, "QUEUED:30m,GENERATING:2h", service)
+
+	tests := []struct {
+		owner string
+		want  []string
+	}{
+		// STALE has no SLA, its projects are reported however long they have been STALE
+		{owner: "", want: []string{"alice_web", "alice_api", "alice_old", "bob_api"}},
+		{owner: "alice", want: []string{"alice_web", "alice_api", "alice_old"}},
+		{owner: "carol"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.owner, func(t *testing.T) {
+			stuck, err := reconciler.StuckProjects(context.Background(), tt.owner, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, project := range stuck {
+				got = append(got, project.Project.ComposeId)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	stuck, _ := reconciler.StuckProjects(context.Background(), "alice", 10)
+	if stuck[0].StuckFor != 3*time.Hour || stuck[0].SLA != 2*time.Hour {
+		t.Fatalf("got %+v, want 3h stuck with a 2h SLA", stuck[0])
+	}
+}
@@ -0,0 +1,581 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: project/project.proto
+
+package project
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ProjectService_GetAllUserProjects_FullMethodName        = "/project.ProjectService/GetAllUserProjects"
+	ProjectService_GetFilteredProjects_FullMethodName       = "/project.ProjectService/GetFilteredProjects"
+	ProjectService_StreamUserProjectsUpdates_FullMethodName = "/project.ProjectService/StreamUserProjectsUpdates"
+	ProjectService_InitProject_FullMethodName               = "/project.ProjectService/InitProject"
+	ProjectService_UpdateProject_FullMethodName             = "/project.ProjectService/UpdateProject"
+	ProjectService_DeleteProject_FullMethodName             = "/project.ProjectService/DeleteProject"
+	ProjectService_GenerateProject_FullMethodName           = "/project.ProjectService/GenerateProject"
+	ProjectService_DeployProject_FullMethodName             = "/project.ProjectService/DeployProject"
+	ProjectService_UndeployProject_FullMethodName           = "/project.ProjectService/UndeployProject"
+	ProjectService_ListArtifacts_FullMethodName             = "/project.ProjectService/ListArtifacts"
+	ProjectService_GetLatestArtifact_FullMethodName         = "/project.ProjectService/GetLatestArtifact"
+	ProjectService_GetDownloadUrl_FullMethodName            = "/project.ProjectService/GetDownloadUrl"
+	ProjectService_ListStuckProjects_FullMethodName         = "/project.ProjectService/ListStuckProjects"
+)
+
+// ProjectServiceClient is the client API for ProjectService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ProjectServiceClient interface {
+	GetAllUserProjects(ctx context.Context, in *GetAllUserProjectsRequest, opts ...grpc.CallOption) (*ListOfProjectsResponse, error)
+	GetFilteredProjects(ctx context.Context, in *GetFilteredProjectsRequest, opts ...grpc.CallOption) (*ListOfProjectsResponse, error)
+	StreamUserProjectsUpdates(ctx context.Context, in *Owner, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ProjectResponse], error)
+	InitProject(ctx context.Context, in *InitProjectRequest, opts ...grpc.CallOption) (*ProjectResponse, error)
+	UpdateProject(ctx context.Context, in *UpdateProjectRequest, opts ...grpc.CallOption) (*ProjectResponse, error)
+	DeleteProject(ctx context.Context, in *ProjectUniqueIdentifier, opts ...grpc.CallOption) (*DeleteProjectResponse, error)
+	GenerateProject(ctx context.Context, in *ProjectUniqueIdentifier, opts ...grpc.CallOption) (*GenerateProjectResponse, error)
+	DeployProject(ctx context.Context, in *DeployProjectRequest, opts ...grpc.CallOption) (*DeployCommandResponse, error)
+	UndeployProject(ctx context.Context, in *DeployProjectRequest, opts ...grpc.CallOption) (*DeployCommandResponse, error)
+	ListArtifacts(ctx context.Context, in *ProjectUniqueIdentifier, opts ...grpc.CallOption) (*ListArtifactsResponse, error)
+	GetLatestArtifact(ctx context.Context, in *ProjectUniqueIdentifier, opts ...grpc.CallOption) (*Artifact, error)
+	GetDownloadUrl(ctx context.Context, in *GetDownloadUrlRequest, opts ...grpc.CallOption) (*DownloadUrlResponse, error)
+	ListStuckProjects(ctx context.Context, in *ListStuckProjectsRequest, opts ...grpc.CallOption) (*ListStuckProjectsResponse, error)
+}
+
+type projectServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewProjectServiceClient(cc grpc.ClientConnInterface) ProjectServiceClient {
+	return &projectServiceClient{cc}
+}
+
+func (c *projectServiceClient) GetAllUserProjects(ctx context.Context, in *GetAllUserProjectsRequest, opts ...grpc.CallOption) (*ListOfProjectsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListOfProjectsResponse)
+	err := c.cc.Invoke(ctx, ProjectService_GetAllUserProjects_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *projectServiceClient) GetFilteredProjects(ctx context.Context, in *GetFilteredProjectsRequest, opts ...grpc.CallOption) (*ListOfProjectsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListOfProjectsResponse)
+	err := c.cc.Invoke(ctx, ProjectService_GetFilteredProjects_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *projectServiceClient) StreamUserProjectsUpdates(ctx context.Context, in *Owner, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ProjectResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ProjectService_ServiceDesc.Streams[0], ProjectService_StreamUserProjectsUpdates_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[Owner, ProjectResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ProjectService_StreamUserProjectsUpdatesClient = grpc.ServerStreamingClient[ProjectResponse]
+
+func (c *projectServiceClient) InitProject(ctx context.Context, in *InitProjectRequest, opts ...grpc.CallOption) (*ProjectResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ProjectResponse)
+	err := c.cc.Invoke(ctx, ProjectService_InitProject_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *projectServiceClient) UpdateProject(ctx context.Context, in *UpdateProjectRequest, opts ...grpc.CallOption) (*ProjectResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ProjectResponse)
+	err := c.cc.Invoke(ctx, ProjectService_UpdateProject_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *projectServiceClient) DeleteProject(ctx context.Context, in *ProjectUniqueIdentifier, opts ...grpc.CallOption) (*DeleteProjectResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteProjectResponse)
+	err := c.cc.Invoke(ctx, ProjectService_DeleteProject_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *projectServiceClient) GenerateProject(ctx context.Context, in *ProjectUniqueIdentifier, opts ...grpc.CallOption) (*GenerateProjectResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GenerateProjectResponse)
+	err := c.cc.Invoke(ctx, ProjectService_GenerateProject_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *projectServiceClient) DeployProject(ctx context.Context, in *DeployProjectRequest, opts ...grpc.CallOption) (*DeployCommandResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeployCommandResponse)
+	err := c.cc.Invoke(ctx, ProjectService_DeployProject_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *projectServiceClient) UndeployProject(ctx context.Context, in *DeployProjectRequest, opts ...grpc.CallOption) (*DeployCommandResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeployCommandResponse)
+	err := c.cc.Invoke(ctx, ProjectService_UndeployProject_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *projectServiceClient) ListArtifacts(ctx context.Context, in *ProjectUniqueIdentifier, opts ...grpc.CallOption) (*ListArtifactsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListArtifactsResponse)
+	err := c.cc.Invoke(ctx, ProjectService_ListArtifacts_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *projectServiceClient) GetLatestArtifact(ctx context.Context, in *ProjectUniqueIdentifier, opts ...grpc.CallOption) (*Artifact, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Artifact)
+	err := c.cc.Invoke(ctx, ProjectService_GetLatestArtifact_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *projectServiceClient) GetDownloadUrl(ctx context.Context, in *GetDownloadUrlRequest, opts ...grpc.CallOption) (*DownloadUrlResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DownloadUrlResponse)
+	err := c.cc.Invoke(ctx, ProjectService_GetDownloadUrl_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *projectServiceClient) ListStuckProjects(ctx context.Context, in *ListStuckProjectsRequest, opts ...grpc.CallOption) (*ListStuckProjectsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListStuckProjectsResponse)
+	err := c.cc.Invoke(ctx, ProjectService_ListStuckProjects_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ProjectServiceServer is the server API for ProjectService service.
+// All implementations must embed UnimplementedProjectServiceServer
+// for forward compatibility.
+type ProjectServiceServer interface {
+	GetAllUserProjects(context.Context, *GetAllUserProjectsRequest) (*ListOfProjectsResponse, error)
+	GetFilteredProjects(context.Context, *GetFilteredProjectsRequest) (*ListOfProjectsResponse, error)
+	StreamUserProjectsUpdates(*Owner, grpc.ServerStreamingServer[ProjectResponse]) error
+	InitProject(context.Context, *InitProjectRequest) (*ProjectResponse, error)
+	UpdateProject(context.Context, *UpdateProjectRequest) (*ProjectResponse, error)
+	DeleteProject(context.Context, *ProjectUniqueIdentifier) (*DeleteProjectResponse, error)
+	GenerateProject(context.Context, *ProjectUniqueIdentifier) (*GenerateProjectResponse, error)
+	DeployProject(context.Context, *DeployProjectRequest) (*DeployCommandResponse, error)
+	UndeployProject(context.Context, *DeployProjectRequest) (*DeployCommandResponse, error)
+	ListArtifacts(context.Context, *ProjectUniqueIdentifier) (*ListArtifactsResponse, error)
+	GetLatestArtifact(context.Context, *ProjectUniqueIdentifier) (*Artifact, error)
+	GetDownloadUrl(context.Context, *GetDownloadUrlRequest) (*DownloadUrlResponse, error)
+	ListStuckProjects(context.Context, *ListStuckProjectsRequest) (*ListStuckProjectsResponse, error)
+	mustEmbedUnimplementedProjectServiceServer()
+}
+
+// UnimplementedProjectServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedProjectServiceServer struct{}
+
+func (UnimplementedProjectServiceServer) GetAllUserProjects(context.Context, *GetAllUserProjectsRequest) (*ListOfProjectsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAllUserProjects not implemented")
+}
+func (UnimplementedProjectServiceServer) GetFilteredProjects(context.Context, *GetFilteredProjectsRequest) (*ListOfProjectsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFilteredProjects not implemented")
+}
+func (UnimplementedProjectServiceServer) StreamUserProjectsUpdates(*Owner, grpc.ServerStreamingServer[ProjectResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamUserProjectsUpdates not implemented")
+}
+func (UnimplementedProjectServiceServer) InitProject(context.Context, *InitProjectRequest) (*ProjectResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method InitProject not implemented")
+}
+func (UnimplementedProjectServiceServer) UpdateProject(context.Context, *UpdateProjectRequest) (*ProjectResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateProject not implemented")
+}
+func (UnimplementedProjectServiceServer) DeleteProject(context.Context, *ProjectUniqueIdentifier) (*DeleteProjectResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteProject not implemented")
+}
+func (UnimplementedProjectServiceServer) GenerateProject(context.Context, *ProjectUniqueIdentifier) (*GenerateProjectResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GenerateProject not implemented")
+}
+func (UnimplementedProjectServiceServer) DeployProject(context.Context, *DeployProjectRequest) (*DeployCommandResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeployProject not implemented")
+}
+func (UnimplementedProjectServiceServer) UndeployProject(context.Context, *DeployProjectRequest) (*DeployCommandResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UndeployProject not implemented")
+}
+func (UnimplementedProjectServiceServer) ListArtifacts(context.Context, *ProjectUniqueIdentifier) (*ListArtifactsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListArtifacts not implemented")
+}
+func (UnimplementedProjectServiceServer) GetLatestArtifact(context.Context, *ProjectUniqueIdentifier) (*Artifact, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLatestArtifact not implemented")
+}
+func (UnimplementedProjectServiceServer) GetDownloadUrl(context.Context, *GetDownloadUrlRequest) (*DownloadUrlResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDownloadUrl not implemented")
+}
+func (UnimplementedProjectServiceServer) ListStuckProjects(context.Context, *ListStuckProjectsRequest) (*ListStuckProjectsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListStuckProjects not implemented")
+}
+func (UnimplementedProjectServiceServer) mustEmbedUnimplementedProjectServiceServer() {}
+func (UnimplementedProjectServiceServer) testEmbeddedByValue()                        {}
+
+// UnsafeProjectServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ProjectServiceServer will
+// result in compilation errors.
+type UnsafeProjectServiceServer interface {
+	mustEmbedUnimplementedProjectServiceServer()
+}
+
+func RegisterProjectServiceServer(s grpc.ServiceRegistrar, srv ProjectServiceServer) {
+	// If the following call pancis, it indicates UnimplementedProjectServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ProjectService_ServiceDesc, srv)
+}
+
+func _ProjectService_GetAllUserProjects_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAllUserProjectsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProjectServiceServer).GetAllUserProjects(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProjectService_GetAllUserProjects_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProjectServiceServer).GetAllUserProjects(ctx, req.(*GetAllUserProjectsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProjectService_GetFilteredProjects_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetFilteredProjectsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProjectServiceServer).GetFilteredProjects(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProjectService_GetFilteredProjects_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProjectServiceServer).GetFilteredProjects(ctx, req.(*GetFilteredProjectsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProjectService_StreamUserProjectsUpdates_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Owner)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ProjectServiceServer).StreamUserProjectsUpdates(m, &grpc.GenericServerStream[Owner, ProjectResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ProjectService_StreamUserProjectsUpdatesServer = grpc.ServerStreamingServer[ProjectResponse]
+
+func _ProjectService_InitProject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InitProjectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProjectServiceServer).InitProject(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProjectService_InitProject_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProjectServiceServer).InitProject(ctx, req.(*InitProjectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProjectService_UpdateProject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateProjectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProjectServiceServer).UpdateProject(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProjectService_UpdateProject_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProjectServiceServer).UpdateProject(ctx, req.(*UpdateProjectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProjectService_DeleteProject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProjectUniqueIdentifier)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProjectServiceServer).DeleteProject(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProjectService_DeleteProject_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProjectServiceServer).DeleteProject(ctx, req.(*ProjectUniqueIdentifier))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProjectService_GenerateProject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProjectUniqueIdentifier)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProjectServiceServer).GenerateProject(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProjectService_GenerateProject_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProjectServiceServer).GenerateProject(ctx, req.(*ProjectUniqueIdentifier))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProjectService_DeployProject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeployProjectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProjectServiceServer).DeployProject(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProjectService_DeployProject_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProjectServiceServer).DeployProject(ctx, req.(*DeployProjectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProjectService_UndeployProject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeployProjectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProjectServiceServer).UndeployProject(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProjectService_UndeployProject_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProjectServiceServer).UndeployProject(ctx, req.(*DeployProjectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProjectService_ListArtifacts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProjectUniqueIdentifier)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProjectServiceServer).ListArtifacts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProjectService_ListArtifacts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProjectServiceServer).ListArtifacts(ctx, req.(*ProjectUniqueIdentifier))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProjectService_GetLatestArtifact_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProjectUniqueIdentifier)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProjectServiceServer).GetLatestArtifact(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProjectService_GetLatestArtifact_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProjectServiceServer).GetLatestArtifact(ctx, req.(*ProjectUniqueIdentifier))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProjectService_GetDownloadUrl_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDownloadUrlRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProjectServiceServer).GetDownloadUrl(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProjectService_GetDownloadUrl_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProjectServiceServer).GetDownloadUrl(ctx, req.(*GetDownloadUrlRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProjectService_ListStuckProjects_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListStuckProjectsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProjectServiceServer).ListStuckProjects(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProjectService_ListStuckProjects_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProjectServiceServer).ListStuckProjects(ctx, req.(*ListStuckProjectsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ProjectService_ServiceDesc is the grpc.ServiceDesc for ProjectService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ProjectService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "project.ProjectService",
+	HandlerType: (*ProjectServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetAllUserProjects",
+			Handler:    _ProjectService_GetAllUserProjects_Handler,
+		},
+		{
+			MethodName: "GetFilteredProjects",
+			Handler:    _ProjectService_GetFilteredProjects_Handler,
+		},
+		{
+			MethodName: "InitProject",
+			Handler:    _ProjectService_InitProject_Handler,
+		},
+		{
+			MethodName: "UpdateProject",
+			Handler:    _ProjectService_UpdateProject_Handler,
+		},
+		{
+			MethodName: "DeleteProject",
+			Handler:    _ProjectService_DeleteProject_Handler,
+		},
+		{
+			MethodName: "GenerateProject",
+			Handler:    _ProjectService_GenerateProject_Handler,
+		},
+		{
+			MethodName: "DeployProject",
+			Handler:    _ProjectService_DeployProject_Handler,
+		},
+		{
+			MethodName: "UndeployProject",
+			Handler:    _ProjectService_UndeployProject_Handler,
+		},
+		{
+			MethodName: "ListArtifacts",
+			Handler:    _ProjectService_ListArtifacts_Handler,
+		},
+		{
+			MethodName: "GetLatestArtifact",
+			Handler:    _ProjectService_GetLatestArtifact_Handler,
+		},
+		{
+			MethodName: "GetDownloadUrl",
+			Handler:    _ProjectService_GetDownloadUrl_Handler,
+		},
+		{
+			MethodName: "ListStuckProjects",
+			Handler:    _ProjectService_ListStuckProjects_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamUserProjectsUpdates",
+			Handler:       _ProjectService_StreamUserProjectsUpdates_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "project/project.proto",
+}
@@ -0,0 +1,8 @@
+module github.com/SmartAPIForge/protos
+
+go 1.23.4
+
+require (
+	google.golang.org/grpc v1.69.4
+	google.golang.org/protobuf v1.36.4
+)